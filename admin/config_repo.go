@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/zxyao/req-log-mid"
 )
 
 // DBConfig 数据库配置模型
@@ -22,13 +24,23 @@ type DBConfig struct {
 // ConfigRepository 配置仓储
 type ConfigRepository struct {
 	db        *sql.DB
+	dialect   reqlogmid.Dialect
 	tableName string
 }
 
-// NewConfigRepository 创建配置仓储
+// NewConfigRepository 创建配置仓储（PostgreSQL）
 func NewConfigRepository(db *sql.DB) *ConfigRepository {
+	return NewConfigRepositoryWithDialect(db, reqlogmid.PostgresDialect{})
+}
+
+// NewConfigRepositoryWithDialect 使用指定 SQL 方言创建配置仓储
+func NewConfigRepositoryWithDialect(db *sql.DB, dialect reqlogmid.Dialect) *ConfigRepository {
+	if dialect == nil {
+		dialect = reqlogmid.PostgresDialect{}
+	}
 	return &ConfigRepository{
 		db:        db,
+		dialect:   dialect,
 		tableName: "log_config",
 	}
 }
@@ -70,38 +82,20 @@ func (r *ConfigRepository) LoadConfig() (*DBConfig, error) {
 
 // SaveConfig 保存配置到数据库
 func (r *ConfigRepository) SaveConfig(cfg *DBConfig) error {
-	// 使用 DELETE + INSERT 确保数据正确更新
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
+	// 使用 upsert 保证只存在 id = 1 的一行配置
+	query := reqlogmid.Rebind(r.dialect, r.dialect.Upsert(r.tableName, "id",
+		[]string{"id", "enabled", "async_mode", "buffer_size", "skip_paths", "custom_fields", "updated_at"}))
 
-	// 删除旧配置
-	_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = 1", r.tableName))
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	// 插入新配置
-	query := fmt.Sprintf(`
-		INSERT INTO %s (id, enabled, async_mode, buffer_size, skip_paths, custom_fields, updated_at)
-		VALUES (1, $1, $2, $3, $4, $5, NOW())
-	`, r.tableName)
-
-	_, err = tx.Exec(query,
+	_, err := r.db.Exec(query,
+		1,
 		cfg.Enabled,
 		cfg.AsyncMode,
 		cfg.BufferSize,
 		cfg.SkipPaths,
 		cfg.CustomFields,
+		time.Now(),
 	)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	return tx.Commit()
+	return err
 }
 
 // ResetConfig 重置配置为默认值
@@ -130,10 +124,10 @@ func (r *ConfigRepository) InitConfigTable() error {
 			async_mode BOOLEAN NOT NULL DEFAULT TRUE,
 			buffer_size INT NOT NULL DEFAULT 1000,
 			skip_paths TEXT,
-			custom_fields JSONB,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			custom_fields %s,
+			updated_at %s DEFAULT %s
 		)
-	`, r.tableName)
+	`, r.tableName, r.dialect.JSONType(), r.dialect.TimestampType(), r.dialect.CurrentTimestamp())
 
 	_, err := r.db.Exec(query)
 	if err != nil {
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zxyao/req-log-mid"
+	_ "modernc.org/sqlite"
+)
+
+// newTestConfigRepository 创建基于临时 SQLite 数据库的配置仓储并建表
+func newTestConfigRepository(t *testing.T) *ConfigRepository {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewConfigRepositoryWithDialect(db, reqlogmid.SQLiteDialect{})
+	if err := repo.InitConfigTable(); err != nil {
+		t.Fatalf("InitConfigTable: %v", err)
+	}
+	return repo
+}
+
+func TestConfigRepositoryInitCreatesDefault(t *testing.T) {
+	repo := newTestConfigRepository(t)
+	if err := repo.InitConfigTable(); err != nil {
+		t.Fatalf("second InitConfigTable: %v", err)
+	}
+
+	cfg, err := repo.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := repo.getDefaultConfig()
+	if cfg.Enabled != want.Enabled || cfg.AsyncMode != want.AsyncMode || cfg.BufferSize != want.BufferSize ||
+		cfg.SkipPaths != want.SkipPaths || cfg.CustomFields != want.CustomFields {
+		t.Errorf("LoadConfig = %+v, want defaults %+v", cfg, want)
+	}
+	if cfg.UpdatedAt.IsZero() {
+		t.Error("updated_at not set")
+	}
+}
+
+func TestConfigRepositorySaveAndReset(t *testing.T) {
+	repo := newTestConfigRepository(t)
+
+	tests := []struct {
+		name string
+		cfg  DBConfig
+	}{
+		{"disabled sync", DBConfig{Enabled: false, AsyncMode: false, BufferSize: 10, SkipPaths: "/ping", CustomFields: `{"env":"test"}`}},
+		{"overwrite", DBConfig{Enabled: true, AsyncMode: true, BufferSize: 5000, SkipPaths: "", CustomFields: "{}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if err := repo.SaveConfig(&cfg); err != nil {
+				t.Fatalf("SaveConfig: %v", err)
+			}
+			got, err := repo.LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if got.ID != 1 || got.Enabled != cfg.Enabled || got.AsyncMode != cfg.AsyncMode || got.BufferSize != cfg.BufferSize ||
+				got.SkipPaths != cfg.SkipPaths || got.CustomFields != cfg.CustomFields {
+				t.Errorf("LoadConfig = %+v, want %+v", got, cfg)
+			}
+
+			var rows int
+			if err := repo.db.QueryRow("SELECT COUNT(*) FROM " + repo.tableName).Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows != 1 {
+				t.Errorf("config table has %d rows, want 1", rows)
+			}
+		})
+	}
+
+	if err := repo.ResetConfig(); err != nil {
+		t.Fatalf("ResetConfig: %v", err)
+	}
+	got, err := repo.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BufferSize != 1000 || got.SkipPaths != "/health,/metrics" {
+		t.Errorf("after reset got %+v", got)
+	}
+}
+
+func TestParseSkipPaths(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"/health", []string{"/health"}},
+		{" /health , /metrics ,,", []string{"/health", "/metrics"}},
+	}
+	for _, tt := range tests {
+		if got := ParseSkipPaths(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSkipPaths(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
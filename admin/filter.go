@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zxyao/req-log-mid"
+)
+
+// fieldParamPrefix 自定义字段查询参数前缀，如 field.user_id=42
+const fieldParamPrefix = "field."
+
+// ParseLogFilter 将 GET /admin/logs 的查询参数解析为 LogFilter
+//
+//	method=GET,POST              请求方法
+//	path=/users                  路径包含
+//	path_prefix=/api/            路径前缀
+//	path_regex=^/api/v[12]/      路径正则
+//	route=/users/:id             路由模板，逗号分隔
+//	status_code=404,500          状态码，逗号分隔
+//	status_class=4xx,5xx         状态码类别
+//	min_status=400&max_status=499
+//	min_duration=100&max_duration=2000   耗时范围（毫秒）
+//	client_ip=1.2.3.4            客户端 IP，逗号分隔
+//	client_cidr=10.0.0.0/8       客户端网段
+//	start_time=2026-02-14 10:00:00&end_time=...
+//	field.user_id=42             自定义字段相等，可重复
+//	field.tenant[in]=acme,globex 自定义字段取值之一
+//	field.amount[gte]=100        自定义字段数值比较：gt、gte、lt、lte
+//	field.trace_id[exists]=1     自定义字段存在
+//	q=keyword                    自由文本，检索路径、查询字符串、User-Agent、错误信息和自定义字段
+//	search=fulltext              检索方式：substring（默认）、fulltext
+func ParseLogFilter(values url.Values) (reqlogmid.LogFilter, error) {
+	return ParseLogFilterIn(values, time.Local)
+}
+
+// ParseLogFilterIn 与 ParseLogFilter 相同，不带时区的时间参数按 loc 解析
+func ParseLogFilterIn(values url.Values, loc *time.Location) (reqlogmid.LogFilter, error) {
+	var f reqlogmid.LogFilter
+	var err error
+
+	f.Methods = splitList(values.Get("method"), strings.ToUpper)
+	f.PathContains = values.Get("path")
+	f.PathPrefix = values.Get("path_prefix")
+	f.PathRegex = values.Get("path_regex")
+	f.Routes = splitList(values.Get("route"), nil)
+	f.ClientIPs = splitList(values.Get("client_ip"), nil)
+	f.ClientCIDR = values.Get("client_cidr")
+	f.Query = strings.TrimSpace(values.Get("q"))
+	f.SearchMode = reqlogmid.SearchMode(strings.ToLower(values.Get("search")))
+
+	for _, s := range splitList(values.Get("status_code"), nil) {
+		code, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("status_code: %q 不是有效的状态码", s)
+		}
+		// 兼容旧版界面：0 或负数表示不限
+		if code > 0 {
+			f.StatusCodes = append(f.StatusCodes, code)
+		}
+	}
+	for _, s := range splitList(values.Get("status_class"), strings.ToLower) {
+		class, err := strconv.Atoi(strings.TrimSuffix(s, "xx"))
+		if err != nil {
+			return f, fmt.Errorf("status_class: %q 不是有效的状态码类别", s)
+		}
+		f.StatusClasses = append(f.StatusClasses, class)
+	}
+
+	if f.MinStatus, err = intParam(values, "min_status"); err != nil {
+		return f, err
+	}
+	if f.MaxStatus, err = intParam(values, "max_status"); err != nil {
+		return f, err
+	}
+	if f.MinDuration, err = floatParam(values, "min_duration"); err != nil {
+		return f, err
+	}
+	if f.MaxDuration, err = floatParam(values, "max_duration"); err != nil {
+		return f, err
+	}
+	if f.StartTime, err = timeParam(values, "start_time", loc); err != nil {
+		return f, err
+	}
+	if f.EndTime, err = timeParam(values, "end_time", loc); err != nil {
+		return f, err
+	}
+
+	var fieldKeys []string
+	for key := range values {
+		if strings.HasPrefix(key, fieldParamPrefix) {
+			fieldKeys = append(fieldKeys, key)
+		}
+	}
+	sort.Strings(fieldKeys)
+	for _, param := range fieldKeys {
+		key, op := parseFieldParam(strings.TrimPrefix(param, fieldParamPrefix))
+		for _, v := range values[param] {
+			p := reqlogmid.FieldPredicate{Key: key, Op: op, Value: v}
+			if op == reqlogmid.FieldIn {
+				p.Values = splitList(v, nil)
+			}
+			f.Fields = append(f.Fields, p)
+		}
+	}
+
+	return f, f.Validate()
+}
+
+// parseFieldParam 拆分 key[op] 形式的自定义字段参数名，无 [op] 时为相等比较
+func parseFieldParam(name string) (string, reqlogmid.FieldOp) {
+	if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") {
+		return name[:i], reqlogmid.FieldOp(name[i+1 : len(name)-1])
+	}
+	return name, reqlogmid.FieldEq
+}
+
+// splitList 按逗号拆分并去除空白，transform 不为 nil 时对每一项做转换
+func splitList(s string, transform func(string) string) []string {
+	if s == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if transform != nil {
+			item = transform(item)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func intParam(values url.Values, key string) (int, error) {
+	s := values.Get(key)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q 不是有效的整数", key, s)
+	}
+	return n, nil
+}
+
+func floatParam(values url.Values, key string) (float64, error) {
+	s := values.Get(key)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %q 不是有效的数字", key, s)
+	}
+	return n, nil
+}
+
+// timeParam 解析时间参数，支持 RFC3339 和常见的本地时间格式（按 loc 解析）
+func timeParam(values url.Values, key string, loc *time.Location) (time.Time, error) {
+	s := values.Get(key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: %q 不是有效的时间", key, s)
+}
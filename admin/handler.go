@@ -1,6 +1,9 @@
 package admin
 
 import (
+	"bytes"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,23 +14,26 @@ import (
 
 // LogAdminHandler 日志管理处理器
 type LogAdminHandler struct {
-	logger *reqlogmid.DBLogger
+	logger    reqlogmid.LogStore
+	storeName string // 指标中的输出器名称
+	metrics   *reqlogmid.RequestMetrics
 }
 
-// NewLogAdminHandler 创建日志管理处理器
-func NewLogAdminHandler(logger *reqlogmid.DBLogger) *LogAdminHandler {
-	return &LogAdminHandler{logger: logger}
+// NewLogAdminHandler 创建日志管理处理器，store 可以是 DBLogger、MemoryLogger 或任意 LogStore 实现
+func NewLogAdminHandler(store reqlogmid.LogStore) *LogAdminHandler {
+	name := "store"
+	switch store.(type) {
+	case *reqlogmid.DBLogger:
+		name = "db"
+	case *reqlogmid.MemoryLogger:
+		name = "memory"
+	}
+	return &LogAdminHandler{logger: store, storeName: name}
 }
 
-// LogQueryParams 日志查询参数
-type LogQueryParams struct {
-	Page       int    `json:"page"`
-	PageSize   int    `json:"page_size"`
-	Method     string `json:"method"`
-	Path       string `json:"path"`
-	StatusCode int    `json:"status_code"`
-	StartTime  string `json:"start_time"`
-	EndTime    string `json:"end_time"`
+// SetRequestMetrics 设置中间件请求指标，用于 /admin/metrics 输出
+func (h *LogAdminHandler) SetRequestMetrics(metrics *reqlogmid.RequestMetrics) {
+	h.metrics = metrics
 }
 
 // LogListResponse 日志列表响应
@@ -39,22 +45,40 @@ type LogListResponse struct {
 		Page     int                    `json:"page"`
 		PageSize int                    `json:"page_size"`
 		Logs     []reqlogmid.DBLogEntry `json:"logs"`
+		// NextCursor 页码模式下为当前页最后一条的游标，可切换到游标模式继续翻页
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
 	} `json:"data"`
 }
 
 // GetLogs 获取日志列表
 // @Summary 获取请求日志列表
-// @Description 分页查询请求日志
+// @Description 分页查询请求日志，列表和总数使用相同的过滤条件
+// @Description 带 cursor 参数（首页传空值）时使用游标分页，不返回总数，适合大数据量和实时翻页
 // @Tags 日志管理
 // @Accept json
 // @Produce json
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(20)
-// @Param method query string false "HTTP方法"
-// @Param path query string false "路径模糊搜索"
-// @Param status_code query int false "状态码"
+// @Param cursor query string false "游标，取自上次响应的 next_cursor 或 prev_cursor"
+// @Param method query string false "HTTP方法，逗号分隔"
+// @Param path query string false "路径包含"
+// @Param path_prefix query string false "路径前缀"
+// @Param path_regex query string false "路径正则"
+// @Param route query string false "路由模板，逗号分隔"
+// @Param status_code query string false "状态码，逗号分隔"
+// @Param status_class query string false "状态码类别，如 4xx,5xx"
+// @Param min_status query int false "最小状态码"
+// @Param max_status query int false "最大状态码"
+// @Param min_duration query number false "最小耗时（毫秒）"
+// @Param max_duration query number false "最大耗时（毫秒）"
+// @Param client_ip query string false "客户端IP，逗号分隔"
+// @Param client_cidr query string false "客户端网段"
 // @Param start_time query string false "开始时间"
 // @Param end_time query string false "结束时间"
+// @Param field.{key} query string false "自定义字段相等，field.{key}[op] 指定比较方式：in、exists、gt、gte、lt、lte"
+// @Param q query string false "自由文本，检索路径、查询字符串、User-Agent、错误信息和自定义字段"
+// @Param search query string false "检索方式：substring（默认）或 fulltext"
 // @Success 200 {object} LogListResponse
 // @Router /admin/logs [get]
 func (h *LogAdminHandler) GetLogs(c *gin.Context) {
@@ -69,37 +93,22 @@ func (h *LogAdminHandler) GetLogs(c *gin.Context) {
 		pageSize = 20
 	}
 
-	params := LogQueryParams{
-		Page:       page,
-		PageSize:   pageSize,
-		Method:     c.Query("method"),
-		Path:       c.Query("path"),
-		StatusCode: -1,
-		StartTime:  c.Query("start_time"),
-		EndTime:    c.Query("end_time"),
-	}
-	if sc, err := strconv.Atoi(c.Query("status_code")); err == nil {
-		params.StatusCode = sc
+	filter, err := ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的查询参数: " + err.Error(),
+		})
+		return
 	}
 
-	// 构建查询条件
-	conditions := map[string]interface{}{}
-	if params.Method != "" {
-		conditions["method"] = params.Method
-	}
-	if params.StatusCode > 0 {
-		conditions["status_code"] = params.StatusCode
-	}
-	if params.StartTime != "" {
-		conditions["start_time"] = params.StartTime
-	}
-	if params.EndTime != "" {
-		conditions["end_time"] = params.EndTime
+	if cursorParam, ok := c.GetQuery("cursor"); ok {
+		h.getLogsByCursor(c, cursorParam, pageSize, filter)
+		return
 	}
-	// path 使用 LIKE 查询，在 DBLogger 中处理
 
 	// 查询总数
-	total, err := h.logger.CountLogs(conditions)
+	total, err := h.logger.CountLogs(filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -110,7 +119,48 @@ func (h *LogAdminHandler) GetLogs(c *gin.Context) {
 
 	// 查询数据
 	offset := (page - 1) * pageSize
-	logs, err := h.logger.QueryLogs(offset, pageSize, conditions)
+	logs, err := h.logger.QueryLogs(offset, pageSize, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	data := gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"logs":      logs,
+	}
+	if len(logs) > 0 && int64(offset+len(logs)) < total {
+		data["next_cursor"] = reqlogmid.CursorAt(&logs[len(logs)-1], false).Encode()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    data,
+	})
+}
+
+// getLogsByCursor 游标分页：按 (created_at, id) 键集翻页，空游标表示第一页
+func (h *LogAdminHandler) getLogsByCursor(c *gin.Context, cursorParam string, pageSize int, filter reqlogmid.LogFilter) {
+	var cursor *reqlogmid.LogCursor
+	if cursorParam != "" {
+		parsed, err := reqlogmid.ParseLogCursor(cursorParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "无效的游标",
+			})
+			return
+		}
+		cursor = &parsed
+	}
+
+	page, err := h.logger.QueryLogsByCursor(cursor, pageSize, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -123,10 +173,10 @@ func (h *LogAdminHandler) GetLogs(c *gin.Context) {
 		"code":    0,
 		"message": "success",
 		"data": gin.H{
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-			"logs":      logs,
+			"page_size":   pageSize,
+			"logs":        page.Logs,
+			"next_cursor": page.NextCursor,
+			"prev_cursor": page.PrevCursor,
 		},
 	})
 }
@@ -215,6 +265,353 @@ func (h *LogAdminHandler) GetStats(c *gin.Context) {
 	})
 }
 
+// GetFieldKeys 列出最近日志中出现过的自定义字段，sample 为采样条数
+func (h *LogAdminHandler) GetFieldKeys(c *gin.Context) {
+	lister, ok := h.logger.(reqlogmid.FieldKeyLister)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    501,
+			"message": "当前日志存储不支持字段发现",
+		})
+		return
+	}
+
+	sample, _ := strconv.Atoi(c.DefaultQuery("sample", strconv.Itoa(reqlogmid.DefaultFieldKeySample)))
+	if sample < 1 || sample > 100000 {
+		sample = reqlogmid.DefaultFieldKeySample
+	}
+
+	keys, err := lister.FieldKeys(sample)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取自定义字段失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"sample": sample,
+			"fields": keys,
+		},
+	})
+}
+
+// maxHistogramBuckets 直方图接口允许的最大分桶数
+const maxHistogramBuckets = 100
+
+// latencyStats 返回支持耗时统计的存储，不支持时写入 501 响应
+func (h *LogAdminHandler) latencyStats(c *gin.Context) (reqlogmid.LatencyStatsProvider, bool) {
+	provider, ok := h.logger.(reqlogmid.LatencyStatsProvider)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    501,
+			"message": "当前日志存储不支持耗时统计",
+		})
+	}
+	return provider, ok
+}
+
+// parseStatsFilter 解析统计接口的过滤条件（与日志列表相同），失败时写入 400 响应
+func parseStatsFilter(c *gin.Context) (reqlogmid.LogFilter, bool) {
+	filter, err := ParseLogFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的查询参数: " + err.Error(),
+		})
+		return filter, false
+	}
+	return filter, true
+}
+
+// GetLatencyStats 获取耗时分位数
+// @Summary 获取响应耗时 p50/p90/p95/p99/max
+// @Description 过滤参数与 /admin/logs 相同，如 route、method、start_time、end_time；
+// @Description PostgreSQL 精确计算，其余数据库由直方图估算（approximate 为 true）
+// @Tags 统计
+// @Produce json
+// @Router /admin/stats/latency [get]
+func (h *LogAdminHandler) GetLatencyStats(c *gin.Context) {
+	provider, ok := h.latencyStats(c)
+	if !ok {
+		return
+	}
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	percentiles, err := provider.LatencyPercentiles(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取耗时分位数失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    percentiles,
+	})
+}
+
+// GetLatencyHistogram 获取耗时直方图
+// @Summary 获取响应耗时分布
+// @Description buckets 为逗号分隔的分桶上界（毫秒），默认 1,2.5,5,...,10000；counts 为累计计数
+// @Tags 统计
+// @Produce json
+// @Param buckets query string false "分桶上界（毫秒），逗号分隔"
+// @Router /admin/stats/histogram [get]
+func (h *LogAdminHandler) GetLatencyHistogram(c *gin.Context) {
+	provider, ok := h.latencyStats(c)
+	if !ok {
+		return
+	}
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	var buckets []float64
+	for _, s := range splitList(c.Query("buckets"), nil) {
+		// ParseFloat 接受 NaN 和 Inf，NaN 与任何值比较都为 false，需单独排除
+		b, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(b) || math.IsInf(b, 0) || b <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "无效的分桶上界: " + s,
+			})
+			return
+		}
+		buckets = append(buckets, b)
+	}
+	if len(buckets) > maxHistogramBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "分桶数不能超过 " + strconv.Itoa(maxHistogramBuckets),
+		})
+		return
+	}
+
+	snap, err := provider.LatencyHistogram(filter, buckets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取耗时分布失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    snap,
+	})
+}
+
+// defaultSeriesWindow 未指定 start_time 时各粒度默认查询的时间范围
+var defaultSeriesWindow = map[reqlogmid.SeriesInterval]time.Duration{
+	reqlogmid.SeriesMinute: time.Hour,
+	reqlogmid.SeriesHour:   24 * time.Hour,
+	reqlogmid.SeriesDay:    30 * 24 * time.Hour,
+}
+
+// GetTimeSeries 获取按时间分桶的请求数、错误数、状态码分布和耗时分位数
+// @Summary 获取时间序列统计
+// @Description 过滤参数与 /admin/logs 相同；未指定 start_time 时按粒度取最近 1 小时 / 24 小时 / 30 天
+// @Tags 统计
+// @Produce json
+// @Param interval query string false "分桶粒度：minute、hour、day" default(hour)
+// @Param tz query string false "时区，如 Asia/Shanghai，默认服务器时区"
+// @Router /admin/stats/timeseries [get]
+func (h *LogAdminHandler) GetTimeSeries(c *gin.Context) {
+	provider, ok := h.logger.(reqlogmid.TimeSeriesProvider)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    501,
+			"message": "当前日志存储不支持时间序列统计",
+		})
+		return
+	}
+
+	interval := reqlogmid.SeriesInterval(c.DefaultQuery("interval", string(reqlogmid.SeriesHour)))
+	if err := interval.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的分桶粒度: " + string(interval),
+		})
+		return
+	}
+	loc := time.Local
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "无效的时区: " + tz,
+			})
+			return
+		}
+	}
+
+	filter, err := ParseLogFilterIn(c.Request.URL.Query(), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+	if filter.EndTime.IsZero() {
+		filter.EndTime = time.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-defaultSeriesWindow[interval])
+	}
+
+	points, err := provider.TimeSeries(filter, interval, loc)
+	if errors.Is(err, reqlogmid.ErrSeriesRangeTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "时间范围过大，请缩小范围或使用更粗的粒度",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取时间序列失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"interval":   interval,
+			"timezone":   loc.String(),
+			"start_time": filter.StartTime.In(loc),
+			"end_time":   filter.EndTime.In(loc),
+			"points":     points,
+		},
+	})
+}
+
+// defaultTopWindow 排行未指定 start_time 时的默认时间范围
+const defaultTopWindow = 24 * time.Hour
+
+// maxTopLimit 排行返回条数上限
+const maxTopLimit = 100
+
+// GetTop 按维度分组排行
+// @Summary 获取 Top N 排行
+// @Description 按 route、path、client_ip、method、status、user_agent 或 field.<key> 分组，
+// @Description 按请求数、错误数、错误率、总耗时或 p95 排行；过滤参数与 /admin/logs 相同，未指定 start_time 时取最近 24 小时
+// @Tags 统计
+// @Produce json
+// @Param by query string false "分组维度" default(route)
+// @Param order query string false "排行依据：count、errors、error_rate、total_duration、p95" default(count)
+// @Param limit query int false "返回条数，最多 100" default(10)
+// @Param min_count query int false "最少请求数"
+// @Router /admin/stats/top [get]
+func (h *LogAdminHandler) GetTop(c *gin.Context) {
+	provider, ok := h.logger.(reqlogmid.TopProvider)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"code":    501,
+			"message": "当前日志存储不支持排行统计",
+		})
+		return
+	}
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+	if filter.EndTime.IsZero() {
+		filter.EndTime = time.Now()
+	}
+	if filter.StartTime.IsZero() {
+		filter.StartTime = filter.EndTime.Add(-defaultTopWindow)
+	}
+
+	// 超过上限时按上限返回，而不是退回默认值
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 {
+		limit = 10
+	}
+	limit = min(limit, maxTopLimit)
+	minCount, _ := strconv.ParseInt(c.Query("min_count"), 10, 64)
+
+	q := reqlogmid.TopQuery{
+		By:       reqlogmid.TopDimension(c.DefaultQuery("by", string(reqlogmid.TopRoute))),
+		OrderBy:  reqlogmid.TopOrder(c.DefaultQuery("order", string(reqlogmid.TopByCount))),
+		Limit:    limit,
+		MinCount: minCount,
+		Filter:   filter,
+	}
+	if err := q.By.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的分组维度: " + string(q.By),
+		})
+		return
+	}
+	if err := q.OrderBy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的排行依据: " + string(q.OrderBy),
+		})
+		return
+	}
+
+	items, err := provider.Top(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取排行失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"by":         q.By,
+			"order":      q.OrderBy,
+			"limit":      q.Limit,
+			"start_time": filter.StartTime,
+			"end_time":   filter.EndTime,
+			"items":      items,
+		},
+	})
+}
+
+// Metrics 以 Prometheus 文本格式输出日志输出器统计和请求指标
+func (h *LogAdminHandler) Metrics(c *gin.Context) {
+	var buf bytes.Buffer
+	loggers := map[string]reqlogmid.LoggerStats{}
+	if p, ok := h.logger.(reqlogmid.StatsProvider); ok {
+		loggers[h.storeName] = p.Stats()
+	}
+	err := reqlogmid.WriteLoggerPrometheus(&buf, loggers)
+	if err == nil && h.metrics != nil {
+		err = h.metrics.WritePrometheus(&buf)
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, "生成指标失败: %v", err)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
+
 // ConfigAdminHandler 配置管理处理器
 type ConfigAdminHandler struct {
 	repo   *ConfigRepository
@@ -364,10 +761,37 @@ func HealthCheck(c *gin.Context) {
 	})
 }
 
+// Health 健康检查，包含日志写入链路状态
+// 数据库写入熔断或有暂存日志待回放时返回 "logging degraded"
+func (h *LogAdminHandler) Health(c *gin.Context) {
+	// 没有写入链路状态的存储（如内存日志）视为正常
+	health := reqlogmid.LoggerHealth{
+		Status:  "ok",
+		Circuit: reqlogmid.CircuitBreakerStats{State: reqlogmid.CircuitClosed.String()},
+	}
+	if p, ok := h.logger.(reqlogmid.HealthReporter); ok {
+		health = p.Health()
+	}
+
+	message := "ok"
+	if health.Status != "ok" {
+		message = "logging degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data": gin.H{
+			"timestamp": time.Now().Format(time.RFC3339),
+			"logging":   health,
+		},
+	})
+}
+
 // RegisterRoutes 注册管理路由
 func RegisterRoutes(r *gin.Engine, logHandler *LogAdminHandler, configHandler *ConfigAdminHandler) {
 	// 健康检查
-	r.GET("/admin/health", HealthCheck)
+	r.GET("/admin/health", logHandler.Health)
 
 	// 日志管理
 	admin := r.Group("/admin")
@@ -388,7 +812,17 @@ func RegisterRoutes(r *gin.Engine, logHandler *LogAdminHandler, configHandler *C
 			config.POST("/reset", configHandler.ResetConfig)
 		}
 
+		// 自定义字段发现
+		admin.GET("/fields", logHandler.GetFieldKeys)
+
 		// 统计
 		admin.GET("/stats", logHandler.GetStats)
+		admin.GET("/stats/latency", logHandler.GetLatencyStats)
+		admin.GET("/stats/histogram", logHandler.GetLatencyHistogram)
+		admin.GET("/stats/timeseries", logHandler.GetTimeSeries)
+		admin.GET("/stats/top", logHandler.GetTop)
+
+		// Prometheus 指标
+		admin.GET("/metrics", logHandler.Metrics)
 	}
 }
@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zxyao/req-log-mid"
+	_ "modernc.org/sqlite"
+)
+
+// newTestRouter 基于 MemoryLogger 注册日志管理路由
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	store := reqlogmid.NewMemoryLogger(100)
+	for _, e := range []reqlogmid.LogEntry{
+		{Method: "GET", Path: "/a", Route: "/a", ClientIP: "10.0.0.1", StatusCode: 200, Duration: 3},
+		{Method: "GET", Path: "/b", Route: "/b", ClientIP: "10.0.0.2", StatusCode: 500, Duration: 30},
+	} {
+		if err := store.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newRouter(store)
+}
+
+// newRouter 基于指定存储注册日志管理路由
+func newRouter(store reqlogmid.LogStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewLogAdminHandler(store)
+	r.GET("/admin/stats/histogram", h.GetLatencyHistogram)
+	r.GET("/admin/stats/top", h.GetTop)
+	return r
+}
+
+// get 发起 GET 请求并解析响应
+func get(t *testing.T, r *gin.Engine, path string, query url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func TestGetLatencyHistogramBuckets(t *testing.T) {
+	r := newTestRouter(t)
+	tests := []struct {
+		buckets string
+		want    int
+	}{
+		{"", http.StatusOK},
+		{"5,50", http.StatusOK},
+		{"NaN", http.StatusBadRequest},
+		{"5,nan", http.StatusBadRequest},
+		{"Inf", http.StatusBadRequest},
+		{"5,+Inf", http.StatusBadRequest},
+		{"-Inf", http.StatusBadRequest},
+		{"0", http.StatusBadRequest},
+		{"abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.buckets, func(t *testing.T) {
+			code, body := get(t, r, "/admin/stats/histogram", url.Values{"buckets": {tt.buckets}})
+			if code != tt.want {
+				t.Errorf("status = %d, want %d: %v", code, tt.want, body)
+			}
+		})
+	}
+}
+
+func TestGetTopLimit(t *testing.T) {
+	r := newTestRouter(t)
+	tests := []struct {
+		limit string
+		want  float64
+	}{
+		{"", 10},
+		{"1", 1},
+		{"100", 100},
+		{"1000", maxTopLimit},
+		{"0", 10},
+		{"abc", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			q := url.Values{}
+			if tt.limit != "" {
+				q.Set("limit", tt.limit)
+			}
+			code, body := get(t, r, "/admin/stats/top", q)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d: %v", code, body)
+			}
+			data := body["data"].(map[string]interface{})
+			if data["limit"] != tt.want {
+				t.Errorf("limit = %v, want %v", data["limit"], tt.want)
+			}
+			if items := data["items"].([]interface{}); len(items) > int(tt.want) {
+				t.Errorf("got %d items, limit %v", len(items), tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTopLimitSQLite(t *testing.T) {
+	store, err := reqlogmid.NewDBLogger("sqlite", filepath.Join(t.TempDir(), "logs.db"), false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	// 超过上限的分组数，/hot 请求最多
+	for i := 0; i < 120; i++ {
+		e := reqlogmid.LogEntry{Method: "GET", Path: fmt.Sprintf("/p/%03d", i), ClientIP: "10.0.0.1", StatusCode: 200, Duration: 1}
+		if err := store.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		e := reqlogmid.LogEntry{Method: "GET", Path: "/hot", ClientIP: "10.0.0.1", StatusCode: 500, Duration: 50}
+		if err := store.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newRouter(store)
+
+	tests := []struct {
+		limit string
+		want  int
+	}{
+		{"", 10},
+		{"1", 1},
+		{"50", 50},
+		{"100", 100},
+		{"1000", maxTopLimit},
+		{"0", 10},
+		{"-5", 10},
+		{"abc", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			q := url.Values{"by": {"path"}}
+			if tt.limit != "" {
+				q.Set("limit", tt.limit)
+			}
+			code, body := get(t, r, "/admin/stats/top", q)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d: %v", code, body)
+			}
+			data := body["data"].(map[string]interface{})
+			if data["limit"] != float64(tt.want) {
+				t.Errorf("limit = %v, want %v", data["limit"], tt.want)
+			}
+			items := data["items"].([]interface{})
+			if len(items) != tt.want {
+				t.Fatalf("got %d items, want %d", len(items), tt.want)
+			}
+			top := items[0].(map[string]interface{})
+			if top["key"] != "/hot" || top["count"] != float64(3) {
+				t.Errorf("first item = %v, want /hot with count 3", top)
+			}
+		})
+	}
+}
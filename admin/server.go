@@ -1,7 +1,9 @@
 package admin
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq" // PostgreSQL 驱动
@@ -22,8 +25,9 @@ var adminHTML embed.FS
 
 // StartOptions 启动选项
 type StartOptions struct {
-	ConfigPath string // 配置文件路径，默认 "config.yaml"
-	Port       int    // 服务端口，默认 8080
+	ConfigPath      string        // 配置文件路径，默认 "config.yaml"
+	Port            int           // 服务端口，默认 8080
+	ShutdownTimeout time.Duration // 收到退出信号后的优雅关闭时限，默认 10s
 }
 
 // getCurrentDir 获取当前可执行文件所在目录
@@ -98,6 +102,9 @@ func Start(opts StartOptions) error {
 	if opts.Port == 0 {
 		opts.Port = 8080
 	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
 
 	exeDir := getCurrentDir()
 	configPath := findConfigFile(opts.ConfigPath)
@@ -148,7 +155,7 @@ func Start(opts StartOptions) error {
 		log.Printf("创建日志表失败: %v", err)
 	}
 
-	configRepo := NewConfigRepository(logger.DB())
+	configRepo := NewConfigRepositoryWithDialect(logger.DB(), logger.Dialect())
 	if err := configRepo.InitConfigTable(); err != nil {
 		log.Printf("初始化配置表失败: %v", err)
 	}
@@ -165,12 +172,15 @@ func Start(opts StartOptions) error {
 	logConfig.BufferSize = dbCfg.BufferSize
 	logConfig.SkipPaths = ParseSkipPaths(dbCfg.SkipPaths)
 	logConfig.CustomFields = ParseCustomFields(dbCfg.CustomFields)
+	logConfig.Metrics = reqlogmid.NewRequestMetrics(nil)
 
 	r := gin.Default()
 
-	r.Use(reqlogmid.RequestLoggerWithConfig(logger, logConfig))
+	mw := reqlogmid.NewMiddleware(logger, logConfig)
+	r.Use(mw.Handler())
 
 	logHandler := NewLogAdminHandler(logger)
+	logHandler.SetRequestMetrics(logConfig.Metrics)
 	configHandler := NewConfigAdminHandler(configRepo, logConfig)
 	RegisterRoutes(r, logHandler, configHandler)
 
@@ -184,19 +194,47 @@ func Start(opts StartOptions) error {
 		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
 	})
 
-	go func() {
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-		<-sigChan
-		logger.Flush()
-		os.Exit(0)
-	}()
-
 	addr := fmt.Sprintf(":%d", opts.Port)
 	fmt.Printf("\n====================================\n")
 	fmt.Printf("服务启动: http://localhost%s\n", addr)
 	fmt.Printf("管理界面: http://localhost%s/admin\n", addr)
 	fmt.Printf("====================================\n\n")
 
-	return r.Run(addr)
+	srv := &http.Server{Addr: addr, Handler: r}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-sigChan:
+	}
+
+	// 先停止接收新请求并等待进行中的请求完成（它们仍会被记录），再关闭日志器
+	ctx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP 服务关闭失败: %v", err)
+	}
+	if err := mw.WaitPendingWrites(ctx); err != nil {
+		log.Printf("等待日志写入超时: %v", err)
+	}
+	lost, err := logger.Shutdown(ctx)
+	if err != nil {
+		log.Printf("日志器关闭失败: %v", err)
+	}
+	if lost > 0 {
+		log.Printf("关闭超时，丢失 %d 条日志", lost)
+	}
+	return nil
 }
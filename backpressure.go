@@ -0,0 +1,152 @@
+package reqlogmid
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BackpressurePolicy 异步缓冲区满时的处理策略
+type BackpressurePolicy int
+
+const (
+	// DropNewest 丢弃新日志（默认）
+	DropNewest BackpressurePolicy = iota
+	// DropOldest 丢弃缓冲区中最旧的日志，为新日志腾出空间
+	DropOldest
+	// BlockWithTimeout 阻塞等待缓冲区空位，超时后丢弃新日志
+	BlockWithTimeout
+	// SpillToFallback 将新日志写入备用 Logger
+	SpillToFallback
+)
+
+// String 返回策略名称
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop_oldest"
+	case BlockWithTimeout:
+		return "block"
+	case SpillToFallback:
+		return "spill"
+	default:
+		return "drop_newest"
+	}
+}
+
+// BackpressureConfig 背压配置
+type BackpressureConfig struct {
+	Policy   BackpressurePolicy
+	Timeout  time.Duration // BlockWithTimeout 的最长等待时间，默认 5ms
+	Fallback Logger        // SpillToFallback 的备用输出，为 nil 时丢弃
+	// Routes 按请求路径前缀覆盖策略，最长前缀优先
+	// 例如审计接口阻塞等待，热点接口快速丢弃
+	Routes map[string]BackpressureConfig
+}
+
+// resolve 返回适用于指定路径的配置
+func (c BackpressureConfig) resolve(path string) BackpressureConfig {
+	best := -1
+	resolved := c
+	for prefix, cfg := range c.Routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best = len(prefix)
+			resolved = cfg
+		}
+	}
+	return resolved
+}
+
+// BackpressureStats 各策略丢弃计数
+type BackpressureStats struct {
+	DroppedNewest uint64 `json:"dropped_newest"` // DropNewest 丢弃数
+	DroppedOldest uint64 `json:"dropped_oldest"` // DropOldest 挤出的旧日志数
+	TimedOut      uint64 `json:"timed_out"`      // BlockWithTimeout 超时丢弃数
+	Spilled       uint64 `json:"spilled"`        // 成功写入备用 Logger 数
+	SpillFailed   uint64 `json:"spill_failed"`   // 写入备用 Logger 失败（或未配置）丢弃数
+}
+
+// Dropped 返回丢弃总数
+func (s BackpressureStats) Dropped() uint64 {
+	return s.DroppedNewest + s.DroppedOldest + s.TimedOut + s.SpillFailed
+}
+
+// backpressure 按策略向缓冲区投递日志并计数
+type backpressure struct {
+	cfg BackpressureConfig
+
+	droppedNewest uint64
+	droppedOldest uint64
+	timedOut      uint64
+	spilled       uint64
+	spillFailed   uint64
+}
+
+// offer 按策略将日志放入缓冲区
+// rescue 不为 nil 时，将被丢弃的日志先交给 rescue 处理（如写入磁盘暂存），返回 true 表示已接管不计为丢弃
+func (b *backpressure) offer(ch chan *LogEntry, entry *LogEntry, rescue func(*LogEntry) bool) {
+	select {
+	case ch <- entry:
+		return
+	default:
+	}
+
+	drop := func(e *LogEntry, counter *uint64) {
+		if rescue != nil && rescue(e) {
+			return
+		}
+		atomic.AddUint64(counter, 1)
+	}
+
+	cfg := b.cfg.resolve(entry.Path)
+	switch cfg.Policy {
+	case DropOldest:
+		for i := 0; i < 3; i++ {
+			select {
+			case oldest := <-ch:
+				drop(oldest, &b.droppedOldest)
+			default:
+			}
+			select {
+			case ch <- entry:
+				return
+			default:
+			}
+		}
+		drop(entry, &b.droppedNewest)
+
+	case BlockWithTimeout:
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 5 * time.Millisecond
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case ch <- entry:
+		case <-timer.C:
+			drop(entry, &b.timedOut)
+		}
+
+	case SpillToFallback:
+		if cfg.Fallback != nil && cfg.Fallback.Write(entry) == nil {
+			atomic.AddUint64(&b.spilled, 1)
+			return
+		}
+		drop(entry, &b.spillFailed)
+
+	default:
+		drop(entry, &b.droppedNewest)
+	}
+}
+
+// stats 返回计数快照
+func (b *backpressure) stats() BackpressureStats {
+	return BackpressureStats{
+		DroppedNewest: atomic.LoadUint64(&b.droppedNewest),
+		DroppedOldest: atomic.LoadUint64(&b.droppedOldest),
+		TimedOut:      atomic.LoadUint64(&b.timedOut),
+		Spilled:       atomic.LoadUint64(&b.spilled),
+		SpillFailed:   atomic.LoadUint64(&b.spillFailed),
+	}
+}
@@ -0,0 +1,162 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newBackpressureLogger 创建 SQLite DBLogger，缓冲区容量为 size 且不启动写入协程
+// 缓冲区在测试调用 Flush 前保持已满，背压策略的行为因此是确定的
+func newBackpressureLogger(t *testing.T, size int, cfg BackpressureConfig) *DBLogger {
+	t.Helper()
+	l := newTestDBLogger(t)
+	l.bufferCh = make(chan *LogEntry, size)
+	l.SetBackpressure(cfg)
+	return l
+}
+
+// persistedPaths 返回已写入数据库的日志路径（排序后）
+func persistedPaths(t *testing.T, l *DBLogger) []string {
+	t.Helper()
+	logs, err := l.QueryLogs(0, 100, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, len(logs))
+	for i, log := range logs {
+		paths[i] = log.Path
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestBackpressurePolicies(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   BackpressureConfig
+		paths []string
+		want  []string
+		stats BackpressureStats
+	}{
+		{
+			name:  "drop newest",
+			cfg:   BackpressureConfig{Policy: DropNewest},
+			paths: []string{"/1", "/2", "/3", "/4", "/5"},
+			want:  []string{"/1", "/2"},
+			stats: BackpressureStats{DroppedNewest: 3},
+		},
+		{
+			name:  "drop oldest",
+			cfg:   BackpressureConfig{Policy: DropOldest},
+			paths: []string{"/1", "/2", "/3", "/4", "/5"},
+			want:  []string{"/4", "/5"},
+			stats: BackpressureStats{DroppedOldest: 3},
+		},
+		{
+			name:  "block times out",
+			cfg:   BackpressureConfig{Policy: BlockWithTimeout, Timeout: time.Millisecond},
+			paths: []string{"/1", "/2", "/3", "/4", "/5"},
+			want:  []string{"/1", "/2"},
+			stats: BackpressureStats{TimedOut: 3},
+		},
+		{
+			name:  "spill without fallback",
+			cfg:   BackpressureConfig{Policy: SpillToFallback},
+			paths: []string{"/1", "/2", "/3"},
+			want:  []string{"/1", "/2"},
+			stats: BackpressureStats{SpillFailed: 1},
+		},
+		{
+			name: "route overrides default",
+			cfg: BackpressureConfig{Policy: DropNewest, Routes: map[string]BackpressureConfig{
+				"/audit": {Policy: DropOldest},
+			}},
+			paths: []string{"/api/1", "/api/2", "/api/3", "/audit/1"},
+			want:  []string{"/api/2", "/audit/1"},
+			stats: BackpressureStats{DroppedNewest: 1, DroppedOldest: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newBackpressureLogger(t, 2, tt.cfg)
+			for _, path := range tt.paths {
+				if err := l.Write(&LogEntry{Method: "GET", Path: path, ClientIP: "127.0.0.1", StatusCode: 200}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			l.Flush()
+
+			if got := persistedPaths(t, l); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("persisted %v, want %v", got, tt.want)
+			}
+			if got := l.BackpressureStats(); got != tt.stats {
+				t.Errorf("stats = %+v, want %+v", got, tt.stats)
+			}
+			if got := l.Stats().Dropped; got != tt.stats.Dropped() {
+				t.Errorf("logger stats dropped = %d, want %d", got, tt.stats.Dropped())
+			}
+		})
+	}
+}
+
+func TestBackpressureSpillToFallback(t *testing.T) {
+	fallback := NewMemoryLogger(10)
+	l := newBackpressureLogger(t, 1, BackpressureConfig{Policy: SpillToFallback, Fallback: fallback})
+
+	for _, path := range []string{"/1", "/2", "/3"} {
+		if err := l.Write(&LogEntry{Method: "GET", Path: path, ClientIP: "127.0.0.1", StatusCode: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Flush()
+
+	if got := persistedPaths(t, l); fmt.Sprint(got) != "[/1]" {
+		t.Errorf("persisted %v, want [/1]", got)
+	}
+	if got := fallback.Len(); got != 2 {
+		t.Errorf("fallback holds %d entries, want 2", got)
+	}
+	if got := l.BackpressureStats(); got != (BackpressureStats{Spilled: 2}) {
+		t.Errorf("stats = %+v, want 2 spilled", got)
+	}
+}
+
+func TestBackpressureBlockWaitsForSpace(t *testing.T) {
+	l := newBackpressureLogger(t, 1, BackpressureConfig{Policy: BlockWithTimeout, Timeout: 5 * time.Second})
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/1", ClientIP: "127.0.0.1", StatusCode: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Write(&LogEntry{Method: "GET", Path: "/2", ClientIP: "127.0.0.1", StatusCode: 200})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write returned while the buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 腾出空位后阻塞的写入应立即完成
+	entry := <-l.bufferCh
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked write did not complete after space was freed")
+	}
+	if err := l.persistEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	l.Flush()
+
+	if got := persistedPaths(t, l); fmt.Sprint(got) != "[/1 /2]" {
+		t.Errorf("persisted %v, want [/1 /2]", got)
+	}
+	if got := l.BackpressureStats(); got.Dropped() != 0 {
+		t.Errorf("stats = %+v, want nothing dropped", got)
+	}
+}
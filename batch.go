@@ -0,0 +1,265 @@
+package reqlogmid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchConfig 批量发送配置
+type BatchConfig struct {
+	BatchSize     int                // 单批最大条数，默认 100
+	FlushInterval time.Duration      // 最长攒批时间，默认 1s
+	QueueSize     int                // 待发送队列大小，默认 1000
+	Backpressure  BackpressureConfig // 队列满时的处理策略
+}
+
+// BatchStats 批量发送统计
+type BatchStats struct {
+	Batches       uint64 `json:"batches"`        // 发送成功批次数
+	BatchesFailed uint64 `json:"batches_failed"` // 发送失败批次数
+}
+
+// partialBatchError 批次中部分日志发送失败，其余已成功
+type partialBatchError struct {
+	failed int
+	err    error
+}
+
+func (e *partialBatchError) Error() string {
+	return fmt.Sprintf("%d entries failed: %v", e.failed, e.err)
+}
+
+func (e *partialBatchError) Unwrap() error {
+	return e.err
+}
+
+// batcher 异步攒批发送，供基于批量接口的输出器复用
+type batcher struct {
+	cfg  BatchConfig
+	send func(ctx context.Context, entries []*LogEntry) error
+	name string
+
+	ch      chan *LogEntry
+	flushCh chan chan struct{}
+	quit    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+
+	// closing 在 shutdown 开始时关闭，使阻塞在投递刷新请求上的 flush 释放读锁
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// sendCtx 在关闭超时时取消，中断进行中的发送
+	sendCtx    context.Context
+	cancelSend context.CancelFunc
+	unsent     int64 // 退出时缓冲中未发送的条数
+
+	backpressure  backpressure
+	stats         writeStats
+	batches       uint64
+	batchesFailed uint64
+}
+
+// newBatcher 创建并启动攒批发送器，name 用于错误输出
+func newBatcher(name string, cfg BatchConfig, send func(ctx context.Context, entries []*LogEntry) error) *batcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+
+	b := &batcher{
+		cfg:     cfg,
+		send:    send,
+		name:    name,
+		ch:      make(chan *LogEntry, cfg.QueueSize),
+		flushCh: make(chan chan struct{}),
+		quit:    make(chan struct{}),
+		closing: make(chan struct{}),
+		stats:   writeStats{latency: NewHistogram(nil)},
+	}
+	b.backpressure.cfg = cfg.Backpressure
+	b.sendCtx, b.cancelSend = context.WithCancel(context.Background())
+	b.start()
+	return b
+}
+
+// start 启动攒批协程
+func (b *batcher) start() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(b.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		buf := make([]*LogEntry, 0, b.cfg.BatchSize)
+		sendBuf := func() {
+			if len(buf) == 0 {
+				return
+			}
+			b.sendBatch(buf)
+			buf = make([]*LogEntry, 0, b.cfg.BatchSize)
+		}
+
+		for {
+			select {
+			case <-b.quit:
+				atomic.StoreInt64(&b.unsent, int64(len(buf)))
+				return
+			case entry, ok := <-b.ch:
+				if !ok {
+					sendBuf()
+					return
+				}
+				buf = append(buf, entry)
+				if len(buf) >= b.cfg.BatchSize {
+					sendBuf()
+				}
+			case <-ticker.C:
+				sendBuf()
+			case done := <-b.flushCh:
+				// 先取空队列再发送；队列已关闭时停止，由下一轮循环发送剩余日志后退出
+				for drained := false; !drained; {
+					select {
+					case entry, ok := <-b.ch:
+						if !ok {
+							drained = true
+							break
+						}
+						buf = append(buf, entry)
+						if len(buf) >= b.cfg.BatchSize {
+							sendBuf()
+						}
+					default:
+						drained = true
+					}
+				}
+				sendBuf()
+				close(done)
+			}
+		}
+	}()
+}
+
+// sendBatch 发送一批日志并记录统计
+func (b *batcher) sendBatch(entries []*LogEntry) {
+	start := time.Now()
+	err := b.send(b.sendCtx, entries)
+
+	failed := len(entries)
+	var partial *partialBatchError
+	if errors.As(err, &partial) {
+		failed = partial.failed
+	}
+	if err == nil {
+		failed = 0
+	}
+	b.stats.recordBatch(start, len(entries)-failed, failed, err)
+	if err != nil {
+		atomic.AddUint64(&b.batchesFailed, 1)
+		fmt.Fprintf(os.Stderr, "%s: failed to send %d log entries: %v\n", b.name, failed, err)
+		return
+	}
+	atomic.AddUint64(&b.batches, 1)
+}
+
+// write 将日志放入队列
+func (b *batcher) write(entry *LogEntry) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return fmt.Errorf("logger is closed")
+	}
+	b.backpressure.offer(b.ch, entry, nil)
+	return nil
+}
+
+// flush 立即发送队列和缓冲中的所有日志
+func (b *batcher) flush() {
+	b.flushContext(context.Background())
+}
+
+// flushContext 立即发送队列和缓冲中的所有日志，在 ctx 结束或关闭超时时提前返回
+// 只在投递刷新请求时持有读锁，等待发送完成时不持锁，避免 shutdown 等锁而超出截止时间
+func (b *batcher) flushContext(ctx context.Context) error {
+	done := make(chan struct{})
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return nil
+	}
+	select {
+	case b.flushCh <- done:
+	case <-b.closing:
+		// 正在关闭，剩余日志由 shutdown 发送
+		b.mu.RUnlock()
+		return nil
+	case <-ctx.Done():
+		b.mu.RUnlock()
+		return ctx.Err()
+	}
+	b.mu.RUnlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-b.quit:
+		return errors.New("logger shut down before flush completed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown 停止接收新日志，在 ctx 截止前发送完剩余日志
+func (b *batcher) shutdown(ctx context.Context) (int, error) {
+	b.closingOnce.Do(func() { close(b.closing) })
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.ch)
+	if err := waitGroupContext(ctx, &b.wg); err != nil {
+		close(b.quit)
+		b.cancelSend()
+		b.wg.Wait()
+		lost := int(atomic.LoadInt64(&b.unsent)) + len(b.ch)
+		return lost, shutdownError(lost, err)
+	}
+	b.cancelSend()
+	return 0, nil
+}
+
+// loggerStats 返回运行统计，写入耗时为单批发送耗时
+func (b *batcher) loggerStats() LoggerStats {
+	stats := LoggerStats{
+		QueueDepth:    len(b.ch),
+		QueueCapacity: cap(b.ch),
+		Backpressure:  b.backpressure.stats(),
+	}
+	stats.Dropped = stats.Backpressure.Dropped()
+	b.stats.snapshot(&stats)
+	return stats
+}
+
+// batchStats 返回批次统计
+func (b *batcher) batchStats() BatchStats {
+	return BatchStats{
+		Batches:       atomic.LoadUint64(&b.batches),
+		BatchesFailed: atomic.LoadUint64(&b.batchesFailed),
+	}
+}
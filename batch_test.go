@@ -0,0 +1,82 @@
+package reqlogmid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatcherShutdownHonorsDeadlineDuringFlush(t *testing.T) {
+	// 模拟无响应的下游：发送一直阻塞到关闭超时取消 ctx
+	sending := make(chan struct{}, 1)
+	b := newBatcher("test", BatchConfig{BatchSize: 1, QueueSize: 10}, func(ctx context.Context, entries []*LogEntry) error {
+		select {
+		case sending <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := b.write(&LogEntry{Path: "/1"}); err != nil {
+		t.Fatal(err)
+	}
+	<-sending
+
+	// 攒批协程忙于发送时 flush 阻塞在投递上，不能因此拖住 shutdown 和写入
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		b.flush()
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	wrote := make(chan error, 1)
+	go func() { wrote <- b.write(&LogEntry{Path: "/2"}) }()
+	select {
+	case err := <-wrote:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write blocked behind a pending flush")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := b.shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v with a 200ms deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown error = %v, want deadline exceeded", err)
+	}
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("flush did not return after shutdown")
+	}
+}
+
+func TestBatcherFlushContext(t *testing.T) {
+	release := make(chan struct{})
+	b := newBatcher("test", BatchConfig{BatchSize: 1, QueueSize: 10}, func(ctx context.Context, entries []*LogEntry) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	defer b.shutdown(context.Background())
+	defer close(release)
+
+	b.write(&LogEntry{Path: "/1"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := b.flushContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("flushContext error = %v, want deadline exceeded", err)
+	}
+}
@@ -20,6 +20,8 @@ type Config struct {
 	Async bool
 	// BufferSize 异步日志缓冲区大小，默认 1000
 	BufferSize int
+	// Metrics 请求指标收集器，为 nil 时不收集
+	Metrics *RequestMetrics
 }
 
 // DefaultConfig 返回默认配置
@@ -0,0 +1,250 @@
+package reqlogmid
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ColorMode 控制台着色模式
+type ColorMode int
+
+const (
+	// ColorAuto 输出为终端时着色（默认）
+	ColorAuto ColorMode = iota
+	// ColorAlways 总是着色
+	ColorAlways
+	// ColorNever 从不着色
+	ColorNever
+)
+
+// ANSI 颜色
+const (
+	ansiReset   = "\033[0m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+	ansiWhite   = "\033[37m"
+	ansiGray    = "\033[90m"
+)
+
+// ConsoleConfig 控制台输出配置
+type ConsoleConfig struct {
+	Output       io.Writer // 输出目标，默认 os.Stdout
+	Color        ColorMode // 着色模式
+	Compact      bool      // 紧凑模式：只输出方法、路径、状态码、耗时
+	PrettyFields bool      // 自定义字段以缩进 JSON 多行输出，否则以 key=value 单行输出
+}
+
+// ConsoleLogger 面向本地开发的可读控制台输出，可替代 gin.Logger
+type ConsoleLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	color  bool
+	cfg    ConsoleConfig
+	closed bool
+}
+
+// NewConsoleLogger 创建控制台日志输出器
+func NewConsoleLogger(cfg ConsoleConfig) *ConsoleLogger {
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+
+	color := false
+	switch cfg.Color {
+	case ColorAlways:
+		color = true
+	case ColorAuto:
+		color = isTerminal(cfg.Output)
+	}
+
+	return &ConsoleLogger{
+		out:   cfg.Output,
+		color: color,
+		cfg:   cfg,
+	}
+}
+
+// isTerminal 判断输出是否为终端
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fd := f.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// Write 实现 Logger 接口
+func (l *ConsoleLogger) Write(entry *LogEntry) error {
+	line := l.format(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("logger is closed")
+	}
+	_, err := io.WriteString(l.out, line)
+	return err
+}
+
+// format 格式化单条日志
+func (l *ConsoleLogger) format(entry *LogEntry) string {
+	var sb strings.Builder
+	latency := formatLatency(entry.Duration)
+
+	if l.cfg.Compact {
+		fmt.Fprintf(&sb, "%s %s %s %s",
+			l.paint(methodColor(entry.Method), entry.Method),
+			entry.Path,
+			l.paint(statusColor(entry.StatusCode), fmt.Sprintf("%d", entry.StatusCode)),
+			l.paint(latencyColor(entry.Duration), latency),
+		)
+	} else {
+		// 先补齐宽度再着色，避免 ANSI 控制符影响对齐
+		fmt.Fprintf(&sb, "[REQ] %s |%s| %s | %15s |%s %q",
+			entry.Timestamp,
+			l.paint(statusColor(entry.StatusCode), fmt.Sprintf(" %3d ", entry.StatusCode)),
+			l.paint(latencyColor(entry.Duration), fmt.Sprintf("%10s", latency)),
+			entry.ClientIP,
+			l.paint(methodColor(entry.Method), fmt.Sprintf(" %-7s", entry.Method)),
+			entry.Path,
+		)
+	}
+
+	if len(entry.CustomFields) > 0 && !l.cfg.Compact {
+		if l.cfg.PrettyFields {
+			data, err := json.MarshalIndent(entry.CustomFields, "      ", "  ")
+			if err == nil {
+				sb.WriteString("\n      ")
+				sb.WriteString(l.paint(ansiGray, string(data)))
+			}
+		} else {
+			sb.WriteString(" ")
+			sb.WriteString(l.paint(ansiGray, formatFields(entry.CustomFields)))
+		}
+	}
+
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// paint 着色
+func (l *ConsoleLogger) paint(color, s string) string {
+	if !l.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// Flush 实现 Logger 接口（同步输出，无需刷新）
+func (l *ConsoleLogger) Flush() {}
+
+// Close 实现 Logger 接口，不关闭底层输出
+func (l *ConsoleLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+// formatFields 以 key=value 形式按键排序输出自定义字段
+func formatFields(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		var v string
+		switch val := fields[k].(type) {
+		case string:
+			v = val
+			if strings.ContainsAny(v, " \t\"=") {
+				v = fmt.Sprintf("%q", v)
+			}
+		default:
+			data, err := json.Marshal(val)
+			if err != nil {
+				v = fmt.Sprintf("%v", val)
+			} else {
+				v = string(data)
+			}
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatLatency 格式化耗时（毫秒）
+func formatLatency(ms float64) string {
+	d := time.Duration(ms * float64(time.Millisecond))
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+func statusColor(code int) string {
+	switch {
+	case code >= 500:
+		return ansiRed
+	case code >= 400:
+		return ansiYellow
+	case code >= 300:
+		return ansiWhite
+	case code >= 200:
+		return ansiGreen
+	default:
+		return ansiGray
+	}
+}
+
+func methodColor(method string) string {
+	switch method {
+	case "GET":
+		return ansiBlue
+	case "POST":
+		return ansiCyan
+	case "PUT", "PATCH":
+		return ansiYellow
+	case "DELETE":
+		return ansiRed
+	case "HEAD", "OPTIONS":
+		return ansiMagenta
+	default:
+		return ansiWhite
+	}
+}
+
+func latencyColor(ms float64) string {
+	switch {
+	case ms >= 1000:
+		return ansiRed
+	case ms >= 200:
+		return ansiYellow
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,160 @@
+package reqlogmid
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsoleLoggerFormat(t *testing.T) {
+	entry := &LogEntry{
+		Timestamp:  "2026-03-01T09:40:00+08:00",
+		Method:     "GET",
+		Path:       "/api/users",
+		ClientIP:   "127.0.0.1",
+		StatusCode: 200,
+		Duration:   1.5,
+	}
+	withFields := *entry
+	withFields.CustomFields = map[string]interface{}{"user": "alice smith", "tenant": "acme", "retries": 2}
+
+	tests := []struct {
+		name  string
+		cfg   ConsoleConfig
+		entry *LogEntry
+		want  string
+	}{
+		{
+			name:  "compact",
+			cfg:   ConsoleConfig{Compact: true},
+			entry: entry,
+			want:  "GET /api/users 200 1.5ms\n",
+		},
+		{
+			name:  "full",
+			entry: entry,
+			want:  `[REQ] 2026-03-01T09:40:00+08:00 | 200 |      1.5ms |       127.0.0.1 | GET     "/api/users"` + "\n",
+		},
+		{
+			name:  "fields sorted and quoted",
+			entry: &withFields,
+			want:  `[REQ] 2026-03-01T09:40:00+08:00 | 200 |      1.5ms |       127.0.0.1 | GET     "/api/users" retries=2 tenant=acme user="alice smith"` + "\n",
+		},
+		{
+			name:  "compact omits fields",
+			cfg:   ConsoleConfig{Compact: true},
+			entry: &withFields,
+			want:  "GET /api/users 200 1.5ms\n",
+		},
+		{
+			name:  "pretty fields",
+			cfg:   ConsoleConfig{PrettyFields: true},
+			entry: &withFields,
+			want: `[REQ] 2026-03-01T09:40:00+08:00 | 200 |      1.5ms |       127.0.0.1 | GET     "/api/users"` + "\n" +
+				"      {\n        \"retries\": 2,\n        \"tenant\": \"acme\",\n        \"user\": \"alice smith\"\n      }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.cfg.Output = &buf
+			l := NewConsoleLogger(tt.cfg)
+			if err := l.Write(tt.entry); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsoleLoggerColor(t *testing.T) {
+	entry := &LogEntry{Method: "DELETE", Path: "/x", StatusCode: 503, Duration: 1500}
+
+	var buf bytes.Buffer
+	l := NewConsoleLogger(ConsoleConfig{Output: &buf, Color: ColorAlways, Compact: true})
+	if err := l.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	want := ansiRed + "DELETE" + ansiReset + " /x " + ansiRed + "503" + ansiReset + " " + ansiRed + "1.5s" + ansiReset + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// 未达到阈值的耗时不着色，宽度补齐在着色之前完成
+	buf.Reset()
+	l = NewConsoleLogger(ConsoleConfig{Output: &buf, Color: ColorAlways})
+	l.Write(&LogEntry{Method: "GET", Path: "/", StatusCode: 200, Duration: 3})
+	if got := buf.String(); !strings.Contains(got, "|"+ansiGreen+" 200 "+ansiReset+"| ") || !strings.Contains(got, "|"+ansiBlue+" GET    "+ansiReset+" ") {
+		t.Errorf("colored output = %q", got)
+	}
+	if strings.Contains(buf.String(), ansiYellow) || strings.Contains(buf.String(), ansiRed) {
+		t.Errorf("fast request latency colored: %q", buf.String())
+	}
+}
+
+func TestConsoleLoggerColorMode(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name string
+		cfg  ConsoleConfig
+		want bool
+	}{
+		{"auto buffer", ConsoleConfig{Output: &bytes.Buffer{}}, false},
+		{"auto regular file", ConsoleConfig{Output: f}, false},
+		{"always buffer", ConsoleConfig{Output: &bytes.Buffer{}, Color: ColorAlways}, true},
+		{"never", ConsoleConfig{Output: f, Color: ColorNever}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewConsoleLogger(tt.cfg).color; got != tt.want {
+				t.Errorf("color = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// TERM=dumb 时即使是终端也不着色
+	t.Setenv("TERM", "dumb")
+	if isTerminal(os.Stdout) {
+		t.Error("TERM=dumb reported as a color terminal")
+	}
+}
+
+func TestConsoleLoggerClosed(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewConsoleLogger(ConsoleConfig{Output: &buf})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/"}); err == nil {
+		t.Error("write after close succeeded")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("closed logger wrote %q", buf.String())
+	}
+}
+
+func TestFormatLatency(t *testing.T) {
+	tests := []struct {
+		ms   float64
+		want string
+	}{
+		{0.0123, "12µs"},
+		{1.23456, "1.23ms"},
+		{250, "250ms"},
+		{1234.5678, "1.235s"},
+	}
+	for _, tt := range tests {
+		if got := formatLatency(tt.ms); got != tt.want {
+			t.Errorf("formatLatency(%v) = %q, want %q", tt.ms, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package reqlogmid
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogCursor 键集分页游标，指向上一页边界上的一条日志
+// 日志按 (created_at, id) 倒序排列，游标翻页不受新写入日志的影响，也不随页码增大而变慢
+type LogCursor struct {
+	CreatedAt time.Time
+	ID        int64
+	Backward  bool // true 表示取比游标更新的一页（上一页），否则取更旧的一页（下一页）
+}
+
+// LogPage 游标分页查询结果
+type LogPage struct {
+	Logs       []DBLogEntry `json:"logs"`
+	NextCursor string       `json:"next_cursor,omitempty"` // 更旧一页的游标，为空表示没有更多
+	PrevCursor string       `json:"prev_cursor,omitempty"` // 更新一页的游标，为空表示已是第一页
+}
+
+// cursorTimeFormat 返回游标时间条件传给数据库的格式，须与 created_at 的存储精度一致
+// PostgreSQL 的 TIMESTAMP 保留微秒；MySQL 的 DATETIME(3) 为毫秒；SQLite 按文本比较，
+// 须与写入的毫秒文本完全同形，否则多出的 000 会使边界上的日志被重复取出
+func cursorTimeFormat(d Dialect) string {
+	if d.Name() == "postgres" {
+		return "2006-01-02 15:04:05.000000"
+	}
+	return filterTimeFormat
+}
+
+// CursorAt 返回指向 entry 的游标
+func CursorAt(entry *DBLogEntry, backward bool) LogCursor {
+	return LogCursor{CreatedAt: entry.CreatedAt, ID: entry.ID, Backward: backward}
+}
+
+// Encode 将游标编码为不透明字符串
+func (c LogCursor) Encode() string {
+	dir := "n"
+	if c.Backward {
+		dir = "p"
+	}
+	raw := fmt.Sprintf("%s:%d:%d", dir, c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseLogCursor 解析 Encode 生成的游标
+func ParseLogCursor(s string) (LogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return LogCursor{}, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return LogCursor{}, fmt.Errorf("invalid cursor")
+	}
+	nanos, err1 := strconv.ParseInt(parts[1], 10, 64)
+	id, err2 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return LogCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return LogCursor{
+		CreatedAt: time.Unix(0, nanos).In(time.Local),
+		ID:        id,
+		Backward:  parts[0] == "p",
+	}, nil
+}
+
+// condition 返回游标的键集条件和参数，占位符为 ?
+// 写成 created_at 范围加 OR 的形式，使 (created_at, id) 索引可用于范围扫描
+func (c LogCursor) condition(d Dialect) (string, []interface{}) {
+	t := c.CreatedAt.In(time.Local).Format(cursorTimeFormat(d))
+	if c.Backward {
+		return "created_at >= ? AND (created_at > ? OR id > ?)", []interface{}{t, t, c.ID}
+	}
+	return "created_at <= ? AND (created_at < ? OR id < ?)", []interface{}{t, t, c.ID}
+}
+
+// cursorOrderBy 返回按翻页方向遍历的排序子句
+func cursorOrderBy(c *LogCursor) string {
+	if c != nil && c.Backward {
+		return "created_at ASC, id ASC"
+	}
+	return "created_at DESC, id DESC"
+}
+
+// olderThan 判断 e 是否排在游标之后（更旧）
+func (c LogCursor) olderThan(e *DBLogEntry) bool {
+	return e.CreatedAt.Before(c.CreatedAt) || (e.CreatedAt.Equal(c.CreatedAt) && e.ID < c.ID)
+}
+
+// newerThan 判断 e 是否排在游标之前（更新）
+func (c LogCursor) newerThan(e *DBLogEntry) bool {
+	return e.CreatedAt.After(c.CreatedAt) || (e.CreatedAt.Equal(c.CreatedAt) && e.ID > c.ID)
+}
+
+// buildLogPage 由按遍历方向取出的至多 limit+1 条日志生成分页结果
+// 多取的一条只用于判断是否还有更多，不返回
+func buildLogPage(rows []DBLogEntry, limit int, cursor *LogCursor) *LogPage {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &LogPage{Logs: rows}
+	if len(rows) == 0 {
+		return page
+	}
+	first, last := &rows[0], &rows[len(rows)-1]
+	if backward {
+		if more {
+			page.PrevCursor = CursorAt(first, true).Encode()
+		}
+		page.NextCursor = CursorAt(last, false).Encode()
+	} else {
+		if more {
+			page.NextCursor = CursorAt(last, false).Encode()
+		}
+		if cursor != nil {
+			page.PrevCursor = CursorAt(first, true).Encode()
+		}
+	}
+	return page
+}
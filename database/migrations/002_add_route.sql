@@ -0,0 +1,7 @@
+-- =====================================================
+-- 002 - request_logs 增加路由模板列
+-- =====================================================
+
+ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS route VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_route ON request_logs(route);
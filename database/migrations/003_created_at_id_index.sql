@@ -0,0 +1,5 @@
+-- =====================================================
+-- 003 - 游标分页使用的 (created_at, id) 联合索引
+-- =====================================================
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_created_at_id ON request_logs(created_at, id);
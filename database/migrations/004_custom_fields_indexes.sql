@@ -0,0 +1,10 @@
+-- =====================================================
+-- 004 - 自定义字段索引（PostgreSQL，按需执行）
+-- =====================================================
+
+-- 任意字段的存在性查询（field.<key>[exists]）
+CREATE INDEX IF NOT EXISTS idx_request_logs_custom_fields ON request_logs USING GIN (custom_fields);
+
+-- 提升字段的相等 / in 查询，表达式需与查询一致；按实际使用的字段增减
+CREATE INDEX IF NOT EXISTS idx_request_logs_cf_user_id ON request_logs((custom_fields->>'user_id'));
+CREATE INDEX IF NOT EXISTS idx_request_logs_cf_tenant ON request_logs((custom_fields->>'tenant'));
@@ -0,0 +1,21 @@
+-- =====================================================
+-- 005 - 查询字符串、错误信息列及检索索引（PostgreSQL）
+-- =====================================================
+
+ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS query_string TEXT;
+ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS error_message TEXT;
+
+-- 以下索引按需执行，表达式需与查询一致
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- 子串检索（q=...）
+CREATE INDEX IF NOT EXISTS idx_request_logs_search_trgm ON request_logs USING GIN (
+    LOWER((COALESCE(path, '') || ' ' || COALESCE(query_string, '') || ' ' || COALESCE(user_agent, '') || ' ' ||
+           COALESCE(error_message, '') || ' ' || COALESCE(custom_fields::text, ''))) gin_trgm_ops
+);
+
+-- 全文检索（q=...&search=fulltext）
+CREATE INDEX IF NOT EXISTS idx_request_logs_search_tsv ON request_logs USING GIN (
+    to_tsvector('simple', (COALESCE(path, '') || ' ' || COALESCE(query_string, '') || ' ' || COALESCE(user_agent, '') || ' ' ||
+                           COALESCE(error_message, '') || ' ' || COALESCE(custom_fields::text, '')))
+);
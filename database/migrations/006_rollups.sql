@@ -0,0 +1,31 @@
+-- =====================================================
+-- 006 - 预聚合汇总表（PostgreSQL，启用 RollupConfig 时需要）
+-- =====================================================
+
+-- 按分钟汇总，默认保留 7 天
+CREATE TABLE IF NOT EXISTS request_logs_rollup_1m (
+    bucket_start TIMESTAMP NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    status_class INT NOT NULL,
+    request_count BIGINT NOT NULL,
+    error_count BIGINT NOT NULL,
+    duration_sum DOUBLE PRECISION NOT NULL,
+    duration_max DOUBLE PRECISION NOT NULL,
+    duration_sketch TEXT NOT NULL,
+    PRIMARY KEY (bucket_start, route, method, status_class)
+);
+
+-- 按小时汇总，不清理
+CREATE TABLE IF NOT EXISTS request_logs_rollup_1h (
+    bucket_start TIMESTAMP NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    status_class INT NOT NULL,
+    request_count BIGINT NOT NULL,
+    error_count BIGINT NOT NULL,
+    duration_sum DOUBLE PRECISION NOT NULL,
+    duration_max DOUBLE PRECISION NOT NULL,
+    duration_sketch TEXT NOT NULL,
+    PRIMARY KEY (bucket_start, route, method, status_class)
+);
@@ -0,0 +1,107 @@
+-- =====================================================
+-- 007 - 将 request_logs 迁移为按天分区的表（PostgreSQL 11+，可选）
+-- 也可启用 PartitionConfig 后调用 DBLogger.MigrateToPartitioned 完成同样的步骤
+-- =====================================================
+
+BEGIN;
+
+-- 原表及其索引改名，分区表才能使用原来的名称
+ALTER TABLE request_logs RENAME TO request_logs_legacy;
+ALTER INDEX request_logs_pkey RENAME TO request_logs_pkey_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_method RENAME TO idx_request_logs_method_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_path RENAME TO idx_request_logs_path_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_route RENAME TO idx_request_logs_route_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_status_code RENAME TO idx_request_logs_status_code_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_created_at RENAME TO idx_request_logs_created_at_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_created_at_id RENAME TO idx_request_logs_created_at_id_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_custom_fields RENAME TO idx_request_logs_custom_fields_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_cf_user_id RENAME TO idx_request_logs_cf_user_id_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_cf_tenant RENAME TO idx_request_logs_cf_tenant_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_search_trgm RENAME TO idx_request_logs_search_trgm_legacy;
+ALTER INDEX IF EXISTS idx_request_logs_search_tsv RENAME TO idx_request_logs_search_tsv_legacy;
+
+-- 分区表的主键须包含分区键
+CREATE TABLE request_logs (
+    id BIGSERIAL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    route VARCHAR(255),
+    query_string TEXT,
+    client_ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512),
+    status_code INT NOT NULL,
+    duration_ms DOUBLE PRECISION NOT NULL,
+    timestamp VARCHAR(32) NOT NULL,
+    error_message TEXT,
+    custom_fields JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE request_logs_default PARTITION OF request_logs DEFAULT;
+
+-- 从原表最早的日志到 7 天后，每天一个分区
+DO $$
+DECLARE
+    d DATE;
+BEGIN
+    FOR d IN SELECT generate_series(
+        COALESCE((SELECT MIN(created_at)::date FROM request_logs_legacy), CURRENT_DATE),
+        CURRENT_DATE + 7, INTERVAL '1 day')::date
+    LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS request_logs_p%s PARTITION OF request_logs FOR VALUES FROM (%L) TO (%L)',
+            to_char(d, 'YYYYMMDD'), d, d + 1);
+    END LOOP;
+END $$;
+
+-- 自增 id 接续原表
+SELECT setval(pg_get_serial_sequence('request_logs', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM request_logs_legacy), false);
+
+CREATE INDEX IF NOT EXISTS idx_request_logs_method ON request_logs(method);
+CREATE INDEX IF NOT EXISTS idx_request_logs_path ON request_logs(path);
+CREATE INDEX IF NOT EXISTS idx_request_logs_route ON request_logs(route);
+CREATE INDEX IF NOT EXISTS idx_request_logs_status_code ON request_logs(status_code);
+CREATE INDEX IF NOT EXISTS idx_request_logs_created_at ON request_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_request_logs_created_at_id ON request_logs(created_at, id);
+
+-- 004、005 中按需执行的索引，原表上建过的在分区表上同样建立，表达式与 004、005 一致
+DO $$
+BEGIN
+    IF to_regclass('idx_request_logs_custom_fields_legacy') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_request_logs_custom_fields ON request_logs USING GIN (custom_fields);
+    END IF;
+    IF to_regclass('idx_request_logs_cf_user_id_legacy') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_request_logs_cf_user_id ON request_logs((custom_fields->>'user_id'));
+    END IF;
+    IF to_regclass('idx_request_logs_cf_tenant_legacy') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_request_logs_cf_tenant ON request_logs((custom_fields->>'tenant'));
+    END IF;
+    IF to_regclass('idx_request_logs_search_trgm_legacy') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_request_logs_search_trgm ON request_logs USING GIN (
+            LOWER((COALESCE(path, '') || ' ' || COALESCE(query_string, '') || ' ' || COALESCE(user_agent, '') || ' ' ||
+                   COALESCE(error_message, '') || ' ' || COALESCE(custom_fields::text, ''))) gin_trgm_ops
+        );
+    END IF;
+    IF to_regclass('idx_request_logs_search_tsv_legacy') IS NOT NULL THEN
+        CREATE INDEX IF NOT EXISTS idx_request_logs_search_tsv ON request_logs USING GIN (
+            to_tsvector('simple', (COALESCE(path, '') || ' ' || COALESCE(query_string, '') || ' ' || COALESCE(user_agent, '') || ' ' ||
+                                   COALESCE(error_message, '') || ' ' || COALESCE(custom_fields::text, '')))
+        );
+    END IF;
+END $$;
+
+COMMIT;
+
+-- 此后新日志写入分区表。按天移动原表数据，可分多次执行，直到原表为空
+WITH moved AS (
+    DELETE FROM request_logs_legacy
+    WHERE created_at < (SELECT MIN(created_at)::date + 1 FROM request_logs_legacy)
+    RETURNING id, method, path, route, query_string, client_ip, user_agent, status_code, duration_ms,
+              timestamp, error_message, custom_fields, created_at
+)
+INSERT INTO request_logs (id, method, path, route, query_string, client_ip, user_agent, status_code, duration_ms,
+                          timestamp, error_message, custom_fields, created_at)
+SELECT * FROM moved;
+
+-- 原表为空后删除
+-- DROP TABLE request_logs_legacy;
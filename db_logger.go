@@ -1,8 +1,10 @@
 package reqlogmid
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -14,12 +16,43 @@ import (
 type DBLogger struct {
 	db        *sql.DB
 	driver    string
+	dialect   Dialect
 	tableName string
 	bufferCh  chan *LogEntry
 	wg        sync.WaitGroup
 	quit      chan struct{}
 	closed    bool
-	mu        sync.Mutex
+	mu        sync.RWMutex
+
+	spool     *Spool
+	spoolQuit chan struct{}
+	spoolWg   sync.WaitGroup
+
+	rollup     *RollupConfig // 为 nil 时统计查询只读原始日志，读写需持有 mu
+	rollupQuit chan struct{}
+	rollupWg   sync.WaitGroup
+	// rollupRewind 写入的早于汇总进度的最早请求时间（UnixNano），0 表示没有；后台汇总据此回退进度
+	rollupRewind int64
+	// rollupAggregated 后台汇总已读取原始日志的分钟进度（UnixNano），早于它写入的日志需要重新汇总
+	rollupAggregated int64
+
+	partition     *PartitionConfig // 为 nil 时日志表不分区
+	partitionQuit chan struct{}
+	partitionWg   sync.WaitGroup
+
+	retry   RetryConfig
+	breaker *CircuitBreaker
+
+	backpressure backpressure
+	stats        writeStats
+
+	promotedFields    []string // 建表达式索引的自定义字段
+	indexCustomFields bool     // 是否在 custom_fields 上建倒排索引
+	searchIndexes     bool     // 是否建立自由文本检索索引
+
+	// writeCtx 在关闭超时时取消，中断进行中的数据库写入
+	writeCtx    context.Context
+	cancelWrite context.CancelFunc
 }
 
 // DBConfig 数据库连接配置
@@ -30,6 +63,23 @@ type DBConfig struct {
 	MaxOpenConns    int           // 最大打开连接数
 	MaxIdleConns    int           // 最大空闲连接数
 	ConnMaxLifetime time.Duration // 连接最大生命周期
+	Dialect         Dialect       // SQL 方言，默认根据 Driver 推断
+	Spool           *SpoolConfig  // 磁盘暂存配置，为 nil 时不启用
+	Rollup          *RollupConfig // 预聚合汇总表配置，为 nil 时不启用
+
+	Retry          *RetryConfig          // 写入重试配置，为 nil 时使用 DefaultRetryConfig
+	CircuitBreaker *CircuitBreakerConfig // 熔断器配置，为 nil 时使用 DefaultCircuitBreakerConfig
+	Backpressure   *BackpressureConfig   // 缓冲区满时的处理策略，为 nil 时丢弃新日志
+
+	// PromotedFields 常用于查询的自定义字段，CreateTable 为其建表达式索引（MySQL 需 8.0.13+）
+	PromotedFields []string
+	// IndexCustomFields 在 custom_fields 上建 GIN 索引，加速任意字段的存在性查询（仅 PostgreSQL）
+	IndexCustomFields bool
+	// SearchIndexes 建立自由文本检索索引：PostgreSQL 为 pg_trgm 和 tsvector 索引，SQLite 为 FTS5 检索表
+	// SQLite 使用 SearchFullText 检索前必须启用
+	SearchIndexes bool
+	// Partitioning 按写入时间范围分区（仅 PostgreSQL），为 nil 时不分区
+	Partitioning *PartitionConfig
 }
 
 // NewDBLogger 创建数据库日志输出器
@@ -55,12 +105,18 @@ func NewDBLogger(driver, dsn string, async bool, bufferSize int) (*DBLogger, err
 	logger := &DBLogger{
 		db:        db,
 		driver:    driver,
+		dialect:   DialectFor(driver),
 		tableName: "request_logs",
-		bufferCh:  make(chan *LogEntry, bufferSize),
 		quit:      make(chan struct{}),
+		retry:     DefaultRetryConfig(),
+		breaker:   NewCircuitBreaker(DefaultCircuitBreakerConfig()),
+		stats:     writeStats{latency: NewHistogram(nil)},
 	}
+	logger.writeCtx, logger.cancelWrite = context.WithCancel(context.Background())
 
+	// 仅异步模式使用缓冲区
 	if async {
+		logger.bufferCh = make(chan *LogEntry, bufferSize)
 		logger.startAsyncWriter()
 	}
 
@@ -100,15 +156,68 @@ func NewDBLoggerWithConfig(cfg DBConfig, async bool, bufferSize int) (*DBLogger,
 		tableName = "request_logs"
 	}
 
+	dialect := cfg.Dialect
+	if dialect == nil {
+		dialect = DialectFor(cfg.Driver)
+	}
+
+	for _, key := range cfg.PromotedFields {
+		if err := ValidateFieldKey(key); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	retry := DefaultRetryConfig()
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
+	breakerCfg := DefaultCircuitBreakerConfig()
+	if cfg.CircuitBreaker != nil {
+		breakerCfg = *cfg.CircuitBreaker
+	}
+
 	logger := &DBLogger{
 		db:        db,
 		driver:    cfg.Driver,
+		dialect:   dialect,
 		tableName: tableName,
-		bufferCh:  make(chan *LogEntry, bufferSize),
 		quit:      make(chan struct{}),
+		retry:     retry,
+		breaker:   NewCircuitBreaker(breakerCfg),
+		stats:     writeStats{latency: NewHistogram(nil)},
+
+		promotedFields:    cfg.PromotedFields,
+		indexCustomFields: cfg.IndexCustomFields,
+		searchIndexes:     cfg.SearchIndexes,
+	}
+	logger.writeCtx, logger.cancelWrite = context.WithCancel(context.Background())
+	if cfg.Backpressure != nil {
+		logger.backpressure.cfg = *cfg.Backpressure
 	}
 
+	if cfg.Spool != nil {
+		if err := logger.EnableSpool(*cfg.Spool); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if cfg.Rollup != nil {
+		if err := logger.EnableRollups(*cfg.Rollup); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if cfg.Partitioning != nil {
+		if err := logger.EnablePartitioning(*cfg.Partitioning); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// 仅异步模式使用缓冲区
 	if async {
+		logger.bufferCh = make(chan *LogEntry, bufferSize)
 		logger.startAsyncWriter()
 	}
 
@@ -122,6 +231,26 @@ func (l *DBLogger) SetTableName(name string) {
 	l.tableName = name
 }
 
+// SetPromotedFields 设置提升字段，需在 CreateTable 之前调用
+func (l *DBLogger) SetPromotedFields(keys ...string) error {
+	for _, key := range keys {
+		if err := ValidateFieldKey(key); err != nil {
+			return err
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.promotedFields = keys
+	return nil
+}
+
+// SetSearchIndexes 设置是否建立自由文本检索索引，需在 CreateTable 之前调用
+func (l *DBLogger) SetSearchIndexes(enabled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.searchIndexes = enabled
+}
+
 // setTimezone 设置数据库时区为本地时间，确保读取时间正确
 func setTimezone(db *sql.DB, driver string) {
 	// 不设置数据库时区，让读取的时间保持原样（本地时间）
@@ -145,23 +274,195 @@ func (l *DBLogger) DB() *sql.DB {
 	return l.db
 }
 
+// Dialect 获取当前使用的 SQL 方言
+func (l *DBLogger) Dialect() Dialect {
+	return l.dialect
+}
+
+// EnableSpool 启用磁盘暂存：数据库不可用或缓冲区满时日志写入磁盘，数据库恢复后按顺序回放
+// 需在开始写入日志前调用
+func (l *DBLogger) EnableSpool(cfg SpoolConfig) error {
+	spool, err := OpenSpool(cfg)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.spool != nil {
+		spool.Close()
+		return fmt.Errorf("spool already enabled")
+	}
+	l.spool = spool
+	l.spoolQuit = make(chan struct{})
+	l.startSpoolReplayer(spool.cfg.ReplayInterval)
+	return nil
+}
+
+// SpoolStats 返回磁盘暂存统计，未启用暂存时返回零值
+func (l *DBLogger) SpoolStats() SpoolStats {
+	if l.spool == nil {
+		return SpoolStats{}
+	}
+	return l.spool.Stats()
+}
+
+// startSpoolReplayer 启动暂存回放协程，定期尝试将暂存日志写回数据库
+func (l *DBLogger) startSpoolReplayer(interval time.Duration) {
+	l.spoolWg.Add(1)
+	go func() {
+		defer l.spoolWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if l.spool.Pending() == 0 {
+					continue
+				}
+				if _, err := l.spool.Replay(l.replayEntry); err != nil && !errors.Is(err, errSpoolStopped) {
+					fmt.Fprintf(os.Stderr, "failed to replay spooled logs: %v\n", err)
+				}
+			case <-l.spoolQuit:
+				return
+			}
+		}
+	}()
+}
+
+// replayEntry 回放单条暂存日志
+// 插入失败但数据库可连通时，认为该条日志本身无效，丢弃以免阻塞后续回放
+func (l *DBLogger) replayEntry(entry *LogEntry) error {
+	select {
+	case <-l.spoolQuit:
+		return errSpoolStopped
+	default:
+	}
+	if err := l.insertWithRetry(entry); err != nil {
+		if !l.retainable(err) {
+			return fmt.Errorf("%w: %v", ErrDiscardEntry, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// retainable 判断写入失败的日志是否应保留在暂存中等待重试
+// 数据库不可用、熔断或正在关闭时保留；数据本身的错误（约束冲突、值超长等）重试也不会成功，不保留
+// 无法归类的错误再检查数据库是否可连通
+func (l *DBLogger) retainable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || l.retry.retryable(err) || l.writeCtx.Err() != nil {
+		return true
+	}
+	return l.db.Ping() != nil
+}
+
+// errSpoolStopped 关闭时中断回放
+var errSpoolStopped = errors.New("spool replay stopped")
+
+// persistEntry 持久化单条日志，启用暂存时数据库写入失败的日志转入磁盘
+// 写入暂存同样计为写入成功
+func (l *DBLogger) persistEntry(entry *LogEntry) (err error) {
+	start := time.Now()
+	defer func() { l.stats.record(start, err) }()
+
+	if l.spool == nil {
+		return l.insertWithRetry(entry)
+	}
+
+	// 暂存中仍有未回放的日志时直接追加，保证写入顺序
+	if l.spool.Pending() > 0 {
+		return l.spool.Append(entry)
+	}
+
+	if err := l.insertWithRetry(entry); err != nil {
+		if !l.retainable(err) {
+			return err
+		}
+		if spoolErr := l.spool.Append(entry); spoolErr != nil {
+			return fmt.Errorf("%v; %w", err, spoolErr)
+		}
+	}
+	return nil
+}
+
+// insertWithRetry 带重试和熔断的插入
+// 熔断器打开时直接返回 ErrCircuitOpen，不访问数据库；只有连接、超时等暂时性错误才重试并计入熔断
+// 关闭超时取消 writeCtx 时中断退避等待
+func (l *DBLogger) insertWithRetry(entry *LogEntry) error {
+	return l.retry.DoContext(l.writeCtx, func() error {
+		if !l.breaker.Allow() {
+			return ErrCircuitOpen
+		}
+		err := l.insertEntry(entry)
+		switch {
+		case err == nil:
+			l.breaker.Success()
+		case l.retry.retryable(err):
+			l.breaker.Failure(err)
+		default:
+			// 数据本身的错误说明数据库可达，按成功处理熔断器，也结束半开状态的探测
+			l.breaker.Success()
+		}
+		return err
+	})
+}
+
+// CircuitBreaker 返回写入熔断器
+func (l *DBLogger) CircuitBreaker() *CircuitBreaker {
+	return l.breaker
+}
+
+// LoggerHealth 日志输出器健康状态
+type LoggerHealth struct {
+	Status  string              `json:"status"` // ok 或 degraded
+	Circuit CircuitBreakerStats `json:"circuit"`
+	Spool   *SpoolStats         `json:"spool,omitempty"`
+}
+
+// Health 返回写入链路健康状态
+// 熔断器未关闭或暂存中有待回放日志时为 degraded
+func (l *DBLogger) Health() LoggerHealth {
+	health := LoggerHealth{
+		Status:  "ok",
+		Circuit: l.breaker.Stats(),
+	}
+	if l.breaker.State() != CircuitClosed {
+		health.Status = "degraded"
+	}
+	if l.spool != nil {
+		stats := l.spool.Stats()
+		health.Spool = &stats
+		if stats.Pending > 0 {
+			health.Status = "degraded"
+		}
+	}
+	return health
+}
+
 // startAsyncWriter 启动异步写入协程
+// bufferCh 关闭后写完剩余日志退出；quit 关闭表示关闭超时，立即退出
 func (l *DBLogger) startAsyncWriter() {
 	l.wg.Add(1)
 	go func() {
 		defer l.wg.Done()
 		for {
+			select {
+			case <-l.quit:
+				return
+			default:
+			}
+
 			select {
 			case entry, ok := <-l.bufferCh:
 				if !ok {
-					l.flushBuffer()
 					return
 				}
-				if err := l.insertEntry(entry); err != nil {
+				if err := l.persistEntry(entry); err != nil {
 					fmt.Fprintf(os.Stderr, "failed to insert log: %v\n", err)
 				}
 			case <-l.quit:
-				l.flushBuffer()
 				return
 			}
 		}
@@ -170,70 +471,171 @@ func (l *DBLogger) startAsyncWriter() {
 
 // insertEntry 插入单条日志
 func (l *DBLogger) insertEntry(entry *LogEntry) error {
-	customFields, _ := json.Marshal(entry.CustomFields)
+	// 没有自定义字段时存 SQL NULL 而不是 JSON null，读取时统一还原为 "null"
+	var customFields interface{}
+	if entry.CustomFields != nil {
+		data, _ := json.Marshal(entry.CustomFields)
+		customFields = string(data)
+	}
+	createdAt := entry.RequestTime
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
 
-	query := fmt.Sprintf(`
-		INSERT INTO %s (method, path, client_ip, user_agent, status_code, duration_ms, timestamp, custom_fields, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, l.tableName)
+	query := Rebind(l.dialect, fmt.Sprintf(`
+		INSERT INTO %s (method, path, route, query_string, client_ip, user_agent, status_code, duration_ms, timestamp, error_message, custom_fields, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.tableName))
 
-	_, err := l.db.Exec(query,
+	_, err := l.db.ExecContext(l.writeCtx, query,
 		entry.Method,
 		entry.Path,
+		entry.Route,
+		entry.Query,
 		entry.ClientIP,
 		entry.UserAgent,
 		entry.StatusCode,
 		entry.Duration,
 		entry.Timestamp,
+		entry.Error,
 		customFields,
-		time.Now(),
+		// 与查询条件相同的本地时间文本，SQLite 按文本比较，驱动对 time.Time 的默认编码（RFC3339）无法与之比较
+		createdAt.In(time.Local).Format(filterTimeFormat),
 	)
+	if err == nil {
+		l.noteRollupWrite(createdAt)
+	}
 	return err
 }
 
-// Write 实现 Logger 接口
-func (l *DBLogger) Write(entry *LogEntry) error {
+// SetBackpressure 设置异步缓冲区满时的处理策略
+func (l *DBLogger) SetBackpressure(cfg BackpressureConfig) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.backpressure.cfg = cfg
+}
+
+// BackpressureStats 返回缓冲区满时各策略的丢弃计数
+func (l *DBLogger) BackpressureStats() BackpressureStats {
+	return l.backpressure.stats()
+}
+
+// Stats 返回运行统计
+func (l *DBLogger) Stats() LoggerStats {
+	stats := LoggerStats{
+		QueueDepth:    len(l.bufferCh),
+		QueueCapacity: cap(l.bufferCh),
+		Backpressure:  l.backpressure.stats(),
+	}
+	stats.Dropped = stats.Backpressure.Dropped()
+	l.stats.snapshot(&stats)
+	circuit := l.breaker.Stats()
+	stats.Circuit = &circuit
+	if l.spool != nil {
+		spool := l.spool.Stats()
+		stats.Spool = &spool
+	}
+	return stats
+}
+
+// Write 实现 Logger 接口
+func (l *DBLogger) Write(entry *LogEntry) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	if l.closed {
 		return fmt.Errorf("logger is closed")
 	}
 
 	if l.bufferCh != nil {
-		select {
-		case l.bufferCh <- entry:
-			return nil
-		default:
-			// 缓冲区满，丢弃日志
-			fmt.Fprintf(os.Stderr, "log buffer full, dropping entry: %s %s\n", entry.Method, entry.Path)
-			return nil
-		}
+		// 缓冲区满时按背压策略处理，启用暂存时被丢弃的日志写入磁盘
+		l.backpressure.offer(l.bufferCh, entry, l.rescueToSpool)
+		return nil
 	}
 
 	// 同步模式
-	return l.insertEntry(entry)
+	return l.persistEntry(entry)
 }
 
-// Close 实现 Logger 接口
+// rescueToSpool 将背压策略丢弃的日志写入磁盘暂存
+func (l *DBLogger) rescueToSpool(entry *LogEntry) bool {
+	if l.spool == nil {
+		return false
+	}
+	return l.spool.Append(entry) == nil
+}
+
+// Close 实现 Logger 接口，等待缓冲区中的日志全部写完
 func (l *DBLogger) Close() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	_, err := l.Shutdown(context.Background())
+	return err
+}
 
+// Shutdown 实现 Shutdowner 接口
+// 停止接收新日志，在 ctx 截止前写完缓冲区中的日志后关闭数据库连接
+// 截止时间到达时中断写入，启用暂存的情况下剩余日志转入磁盘，否则计为丢失
+func (l *DBLogger) Shutdown(ctx context.Context) (int, error) {
+	l.mu.Lock()
 	if l.closed {
-		return nil
+		l.mu.Unlock()
+		return 0, nil
 	}
 	l.closed = true
+	l.mu.Unlock()
 
+	var lost int
+	var deadlineErr error
 	if l.bufferCh != nil {
 		close(l.bufferCh)
-		l.wg.Wait()
+		if err := waitGroupContext(ctx, &l.wg); err != nil {
+			close(l.quit)
+			l.cancelWrite()
+			l.wg.Wait()
+			for entry := range l.bufferCh {
+				if !l.rescueToSpool(entry) {
+					lost++
+				}
+			}
+			deadlineErr = shutdownError(lost, err)
+		}
+	}
+
+	// 停止回放，未回放的日志保留在磁盘上
+	if l.spool != nil {
+		close(l.spoolQuit)
+		if err := waitGroupContext(ctx, &l.spoolWg); err != nil {
+			l.cancelWrite()
+			l.spoolWg.Wait()
+		}
+		l.spool.Close()
+	}
+
+	// 停止后台汇总，中断进行中的汇总事务
+	if l.rollupQuit != nil {
+		close(l.rollupQuit)
+		if err := waitGroupContext(ctx, &l.rollupWg); err != nil {
+			l.cancelWrite()
+			l.rollupWg.Wait()
+		}
+	}
+	// 停止分区维护，超时则中断进行中的维护语句
+	if l.partitionQuit != nil {
+		close(l.partitionQuit)
+		if err := waitGroupContext(ctx, &l.partitionWg); err != nil {
+			l.cancelWrite()
+			l.partitionWg.Wait()
+		}
 	}
 
+	l.cancelWrite()
+	var closeErr error
 	if l.db != nil {
-		return l.db.Close()
+		closeErr = l.db.Close()
 	}
-	return nil
+	if deadlineErr != nil {
+		return lost, deadlineErr
+	}
+	return 0, closeErr
 }
 
 // Flush 实现 Logger 接口
@@ -248,8 +650,11 @@ func (l *DBLogger) Flush() {
 func (l *DBLogger) flushBuffer() {
 	for {
 		select {
-		case entry := <-l.bufferCh:
-			if err := l.insertEntry(entry); err != nil {
+		case entry, ok := <-l.bufferCh:
+			if !ok {
+				return
+			}
+			if err := l.persistEntry(entry); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to flush log: %v\n", err)
 			}
 		default:
@@ -263,62 +668,45 @@ type DBLogEntry struct {
 	ID           int64     `json:"id"`
 	Method       string    `json:"method"`
 	Path         string    `json:"path"`
+	Route        string    `json:"route,omitempty"`
+	Query        string    `json:"query,omitempty"`
 	ClientIP     string    `json:"client_ip"`
 	UserAgent    string    `json:"user_agent"`
 	StatusCode   int       `json:"status_code"`
 	Duration     float64   `json:"duration_ms"`
 	Timestamp    string    `json:"timestamp"`
+	Error        string    `json:"error,omitempty"`
 	CustomFields string    `json:"custom_fields"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
-// QueryLogs 查询日志
-func (l *DBLogger) QueryLogs(offset, limit int, conditions map[string]interface{}) ([]DBLogEntry, error) {
-	query := fmt.Sprintf(`
-		SELECT id, method, path, client_ip, user_agent, status_code, duration_ms, timestamp, custom_fields, created_at
-		FROM %s
-	`, l.tableName)
+// logColumns 查询日志时选取的列，与 scanLogEntry 的顺序一致
+const logColumns = "id, method, path, COALESCE(route, ''), COALESCE(query_string, ''), client_ip, user_agent, status_code, duration_ms, timestamp, COALESCE(error_message, ''), COALESCE(custom_fields, 'null'), created_at"
 
-	args := []interface{}{}
-	conds := []string{}
-	argNum := 1
-
-	if method, ok := conditions["method"]; ok && method != "" {
-		conds = append(conds, fmt.Sprintf("method = $%d", argNum))
-		args = append(args, method)
-		argNum++
-	}
-	if path, ok := conditions["path"]; ok && path != "" {
-		if pathStr, ok := path.(string); ok && pathStr != "" {
-			conds = append(conds, fmt.Sprintf("path LIKE $%d", argNum))
-			args = append(args, "%"+pathStr+"%")
-			argNum++
-		}
-	}
-	if statusCode, ok := conditions["status_code"]; ok && statusCode != 0 {
-		conds = append(conds, fmt.Sprintf("status_code = $%d", argNum))
-		args = append(args, statusCode)
-		argNum++
-	}
-	if startTime, ok := conditions["start_time"]; ok && startTime != "" {
-		conds = append(conds, fmt.Sprintf("created_at >= $%d", argNum))
-		args = append(args, startTime)
-		argNum++
-	}
-	if endTime, ok := conditions["end_time"]; ok && endTime != "" {
-		conds = append(conds, fmt.Sprintf("created_at <= $%d", argNum))
-		args = append(args, endTime)
-		argNum++
-	}
+// scanLogEntry 扫描一行日志
+func scanLogEntry(scan func(dest ...interface{}) error) (DBLogEntry, error) {
+	var entry DBLogEntry
+	err := scan(
+		&entry.ID, &entry.Method, &entry.Path, &entry.Route, &entry.Query, &entry.ClientIP,
+		&entry.UserAgent, &entry.StatusCode, &entry.Duration,
+		&entry.Timestamp, &entry.Error, &entry.CustomFields, &entry.CreatedAt,
+	)
+	// 转换时区：数据库存的是本地时间，但被当作 UTC 读取
+	entry.CreatedAt = convertToLocalTime(entry.CreatedAt)
+	return entry, err
+}
 
-	if len(conds) > 0 {
-		query += " WHERE " + joinStrings(conds, " AND ")
+// QueryLogs 按条件分页查询日志，按时间倒序
+func (l *DBLogger) QueryLogs(offset, limit int, filter LogFilter) ([]DBLogEntry, error) {
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return nil, err
 	}
 
-	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argNum, argNum+1)
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", logColumns, l.tableName, where)
 	args = append(args, limit, offset)
 
-	rows, err := l.db.Query(query, args...)
+	rows, err := l.db.Query(Rebind(l.dialect, query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -326,91 +714,88 @@ func (l *DBLogger) QueryLogs(offset, limit int, conditions map[string]interface{
 
 	var entries []DBLogEntry
 	for rows.Next() {
-		var entry DBLogEntry
-		if err := rows.Scan(
-			&entry.ID, &entry.Method, &entry.Path, &entry.ClientIP,
-			&entry.UserAgent, &entry.StatusCode, &entry.Duration,
-			&entry.Timestamp, &entry.CustomFields, &entry.CreatedAt,
-		); err != nil {
+		entry, err := scanLogEntry(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 		entries = append(entries, entry)
 	}
-
-	// 转换时区：数据库存的是本地时间，但被当作 UTC 读取
-	for i := range entries {
-		entries[i].CreatedAt = convertToLocalTime(entries[i].CreatedAt)
-	}
-
-	return entries, nil
+	return entries, rows.Err()
 }
 
-// CountLogs 统计日志数量
-func (l *DBLogger) CountLogs(conditions map[string]interface{}) (int64, error) {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", l.tableName)
+// QueryLogsByCursor 按条件做键集分页查询，cursor 为 nil 时返回第一页（最新）
+func (l *DBLogger) QueryLogsByCursor(cursor *LogCursor, limit int, filter LogFilter) (*LogPage, error) {
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return nil, err
+	}
+	if cursor != nil {
+		cond, cargs := cursor.condition(l.dialect)
+		if where == "" {
+			where = " WHERE " + cond
+		} else {
+			where += " AND " + cond
+		}
+		args = append(args, cargs...)
+	}
 
-	args := []interface{}{}
-	conds := []string{}
-	argNum := 1
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT ?", logColumns, l.tableName, where, cursorOrderBy(cursor))
+	args = append(args, limit+1)
 
-	if method, ok := conditions["method"]; ok && method != "" {
-		conds = append(conds, fmt.Sprintf("method = $%d", argNum))
-		args = append(args, method)
-		argNum++
+	rows, err := l.db.Query(Rebind(l.dialect, query), args...)
+	if err != nil {
+		return nil, err
 	}
-	if startTime, ok := conditions["start_time"]; ok && startTime != "" {
-		conds = append(conds, fmt.Sprintf("created_at >= $%d", argNum))
-		args = append(args, startTime)
-		argNum++
+	defer rows.Close()
+
+	var entries []DBLogEntry
+	for rows.Next() {
+		entry, err := scanLogEntry(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
 	}
-	if endTime, ok := conditions["end_time"]; ok && endTime != "" {
-		conds = append(conds, fmt.Sprintf("created_at <= $%d", argNum))
-		args = append(args, endTime)
-		argNum++
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+	return buildLogPage(entries, limit, cursor), nil
+}
 
-	if len(conds) > 0 {
-		query += " WHERE " + joinStrings(conds, " AND ")
+// CountLogs 按与 QueryLogs 相同的条件统计日志数量
+func (l *DBLogger) CountLogs(filter LogFilter) (int64, error) {
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return 0, err
 	}
 
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", l.tableName, where)
 	var count int64
-	err := l.db.QueryRow(query, args...).Scan(&count)
+	err = l.db.QueryRow(Rebind(l.dialect, query), args...).Scan(&count)
 	return count, err
 }
 
 // GetLogByID 根据ID获取单条日志
 func (l *DBLogger) GetLogByID(id int64) (*DBLogEntry, error) {
-	query := fmt.Sprintf(`
-		SELECT id, method, path, client_ip, user_agent, status_code, duration_ms, timestamp, custom_fields, created_at
-		FROM %s WHERE id = $1
-	`, l.tableName)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", logColumns, l.tableName)
 
-	var entry DBLogEntry
-	err := l.db.QueryRow(query, id).Scan(
-		&entry.ID, &entry.Method, &entry.Path, &entry.ClientIP,
-		&entry.UserAgent, &entry.StatusCode, &entry.Duration,
-		&entry.Timestamp, &entry.CustomFields, &entry.CreatedAt,
-	)
+	entry, err := scanLogEntry(l.db.QueryRow(Rebind(l.dialect, query), id).Scan)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-
-	// 转换时区
-	entry.CreatedAt = convertToLocalTime(entry.CreatedAt)
 	return &entry, nil
 }
 
 // DeleteOldLogs 删除指定天数之前的日志
+// 启用分区时删除整个过期分区，截止时间所在分区中更早的日志保留到该分区整体过期
 func (l *DBLogger) DeleteOldLogs(days int) (int64, error) {
-	var query string
-	if l.isMySQL() {
-		query = fmt.Sprintf("DELETE FROM %s WHERE created_at < DATE_SUB(NOW(), INTERVAL %d DAY)", l.tableName, days)
-	} else {
-		query = fmt.Sprintf("DELETE FROM %s WHERE created_at < NOW() - INTERVAL '%d days'", l.tableName, days)
+	if l.partition != nil {
+		return l.dropPartitionsBefore(time.Now().AddDate(0, 0, -days))
 	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE created_at < %s", l.tableName, l.dialect.DaysAgo(days))
 	result, err := l.db.Exec(query)
 	if err != nil {
 		return 0, err
@@ -418,19 +803,9 @@ func (l *DBLogger) DeleteOldLogs(days int) (int64, error) {
 	return result.RowsAffected()
 }
 
-// isMySQL 判断是否为 MySQL 数据库
-func (l *DBLogger) isMySQL() bool {
-	return l.driver == "mysql"
-}
-
 // GetTodayLogsCount 获取今日请求数
 func (l *DBLogger) GetTodayLogsCount() (int64, error) {
-	var query string
-	if l.isMySQL() {
-		query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE DATE(created_at) = CURDATE()", l.tableName)
-	} else {
-		query = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE DATE(created_at) = CURRENT_DATE", l.tableName)
-	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", l.tableName, l.dialect.IsToday("created_at"))
 	var count int64
 	err := l.db.QueryRow(query).Scan(&count)
 	return count, err
@@ -466,78 +841,228 @@ func (l *DBLogger) GetErrorRate() (float64, error) {
 	return rate, err
 }
 
-// GetStats 获取统计数据
+// GetStats 获取统计数据，今日按服务器本地时区的零点起算
+// 启用汇总表时总数、平均响应时间和错误率由汇总加上尚未汇总的原始日志算出；否则在一次扫描中算出全部四项
 func (l *DBLogger) GetStats() (int64, int64, float64, float64, error) {
-	todayCount, err1 := l.GetTodayLogsCount()
-	totalCount, err2 := l.GetTotalLogsCount()
-	avgDuration, err3 := l.GetAvgDuration()
-	errorRate, err4 := l.GetErrorRate()
+	midnight := SeriesDay.Truncate(time.Now(), time.Local)
+	today, total, ok, err := l.statsFromRollups(midnight)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("统计查询失败: %v", err)
+	}
+	if !ok {
+		query := fmt.Sprintf(`
+			SELECT COUNT(*),
+				COALESCE(SUM(CASE WHEN created_at >= ? THEN 1 ELSE 0 END), 0),
+				SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END),
+				COALESCE(SUM(duration_ms), 0)
+			FROM %s
+		`, l.tableName)
+		var errorCount sql.NullInt64
+		err := l.db.QueryRow(Rebind(l.dialect, query), midnight.Format(filterTimeFormat)).Scan(&total.count, &today, &errorCount, &total.sum)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("统计查询失败: %v", err)
+		}
+		total.errors = errorCount.Int64
+	}
+
+	var avgDuration, errorRate float64
+	if total.count > 0 {
+		avgDuration = total.sum / float64(total.count)
+		errorRate = 100 * float64(total.errors) / float64(total.count)
+	}
+	return today, total.count, avgDuration, errorRate, nil
+}
+
+// statsFromRollups 由汇总表计算今日请求数和全部日志的请求数、错误数、耗时总和
+// 只统计最早一条原始日志所在分钟之后的汇总，DeleteOldLogs 删除的日志不再计入；未启用汇总或跨度不足 MinQueryRange 时 ok 为 false
+// 今日跨度不足 MinQueryRange 时今日请求数按时间索引读原始日志
+func (l *DBLogger) statsFromRollups(midnight time.Time) (today int64, total rollupRow, ok bool, err error) {
+	if l.rollupConfig() == nil {
+		return 0, total, false, nil
+	}
+	var first sql.NullString
+	query := fmt.Sprintf("SELECT %s FROM %s", l.dialect.MinuteKey("MIN(created_at)"), l.tableName)
+	if err := l.db.QueryRow(query).Scan(&first); err != nil {
+		return 0, total, false, err
+	}
+	if !first.Valid {
+		return 0, total, true, nil
+	}
+	oldest, err := parseMinuteKey(first.String)
+	if err != nil {
+		return 0, total, false, err
+	}
 
-	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-		return 0, 0, 0, 0, fmt.Errorf("统计查询失败: %v %v %v %v", err1, err2, err3, err4)
+	rows, ok, err := l.rollupRows(LogFilter{StartTime: oldest}, true)
+	if err != nil || !ok {
+		return 0, total, false, err
+	}
+	for i := range rows {
+		total.count += rows[i].count
+		total.errors += rows[i].errors
+		total.sum += rows[i].sum
 	}
 
-	return todayCount, totalCount, avgDuration, errorRate, nil
+	if midnight.Before(oldest) {
+		midnight = oldest
+	}
+	rows, ok, err = l.rollupRows(LogFilter{StartTime: midnight}, true)
+	if err != nil {
+		return 0, total, false, err
+	}
+	if !ok {
+		today, err = l.CountLogs(LogFilter{StartTime: midnight})
+		return today, total, err == nil, err
+	}
+	for i := range rows {
+		today += rows[i].count
+	}
+	return today, total, true, nil
 }
 
-// CreateTable 创建日志表（PostgreSQL 语法）
+// CreateTable 创建日志表（按当前方言生成 DDL）
 func (l *DBLogger) CreateTable() error {
-	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id BIGSERIAL PRIMARY KEY,
-			method VARCHAR(10) NOT NULL,
-			path VARCHAR(512) NOT NULL,
-			client_ip VARCHAR(45) NOT NULL,
-			user_agent VARCHAR(512),
-			status_code INT NOT NULL,
-			duration_ms DOUBLE PRECISION NOT NULL,
-			timestamp VARCHAR(32) NOT NULL,
-			custom_fields JSONB,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`, l.tableName)
-
-	_, err := l.db.Exec(query)
+	_, err := l.db.Exec(l.createTableStmt())
 	if err != nil {
 		return err
 	}
 
-	// 创建索引
-	indexes := []string{
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_method ON %s(method)", l.tableName, l.tableName),
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_path ON %s(path)", l.tableName, l.tableName),
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_status_code ON %s(status_code)", l.tableName, l.tableName),
-		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_created_at ON %s(created_at)", l.tableName, l.tableName),
+	// 分区表先建分区，之后在父表上建的索引会同步到各分区
+	if l.partition != nil {
+		if err := l.createPartitions(); err != nil {
+			return err
+		}
+	}
+
+	// 旧版本建的表缺少后来增加的列，补齐；列已存在的错误忽略
+	for _, stmt := range l.upgradeStmts() {
+		l.db.Exec(stmt)
 	}
 
-	for _, idx := range indexes {
+	// 创建索引，索引已存在等错误忽略
+	for _, idx := range l.createIndexStmts() {
 		l.db.Exec(idx)
 	}
 
+	if l.rollupConfig() != nil {
+		for _, stmt := range l.createRollupStmts() {
+			if _, err := l.db.Exec(stmt); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
-// CreateTableSQL 返回建表 SQL（PostgreSQL）
+// CreateTableSQL 返回建表 SQL（按当前方言生成）
 func (l *DBLogger) CreateTableSQL() string {
+	stmts := []string{l.createTableStmt()}
+	if l.partition != nil {
+		stmts = append(stmts, l.partitionStmts(time.Now())...)
+	}
+	stmts = append(stmts, l.createIndexStmts()...)
+	if l.rollupConfig() != nil {
+		stmts = append(stmts, l.createRollupStmts()...)
+	}
+	return strings.Join(stmts, ";\n") + ";\n"
+}
+
+// createTableStmt 返回建表语句
+func (l *DBLogger) createTableStmt() string {
+	id, primaryKey, partitionBy := l.dialect.AutoIncrementPrimaryKey(), "", ""
+	if l.partition != nil {
+		// 分区表的主键须包含分区键
+		id = "BIGSERIAL"
+		primaryKey = ",\n\t\t\tPRIMARY KEY (id, created_at)"
+		partitionBy = " PARTITION BY RANGE (created_at)"
+	}
 	return fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
-			id BIGSERIAL PRIMARY KEY,
+			id %s,
 			method VARCHAR(10) NOT NULL,
 			path VARCHAR(512) NOT NULL,
+			route VARCHAR(255),
+			query_string TEXT,
 			client_ip VARCHAR(45) NOT NULL,
 			user_agent VARCHAR(512),
 			status_code INT NOT NULL,
 			duration_ms DOUBLE PRECISION NOT NULL,
 			timestamp VARCHAR(32) NOT NULL,
-			custom_fields JSONB,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_%s_method ON %s(method);
-		CREATE INDEX IF NOT EXISTS idx_%s_path ON %s(path);
-		CREATE INDEX IF NOT EXISTS idx_%s_status_code ON %s(status_code);
-		CREATE INDEX IF NOT EXISTS idx_%s_created_at ON %s(created_at);
-	`, l.tableName, l.tableName, l.tableName, l.tableName, l.tableName, l.tableName, l.tableName, l.tableName, l.tableName)
+			error_message TEXT,
+			custom_fields %s,
+			created_at %s NOT NULL DEFAULT %s%s
+		)%s`, l.tableName, id, l.dialect.JSONType(), l.dialect.TimestampType(), l.dialect.CurrentTimestamp(), primaryKey, partitionBy)
+}
+
+// upgradeStmts 返回为旧表补齐新增列的语句
+func (l *DBLogger) upgradeStmts() []string {
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN route VARCHAR(255)", l.tableName),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN query_string TEXT", l.tableName),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN error_message TEXT", l.tableName),
+	}
+}
+
+// createIndexStmts 返回建索引语句
+func (l *DBLogger) createIndexStmts() []string {
+	columns := []string{"method", "path", "route", "status_code", "created_at"}
+	stmts := make([]string, 0, len(columns)+1)
+	for _, col := range columns {
+		stmts = append(stmts, l.dialect.CreateIndex(fmt.Sprintf("idx_%s_%s", l.tableName, col), l.tableName, col))
+	}
+	// 键集分页按 (created_at, id) 排序和比较
+	stmts = append(stmts, l.dialect.CreateIndex(fmt.Sprintf("idx_%s_created_at_id", l.tableName), l.tableName, "created_at, id"))
+
+	// 提升字段：在与查询相同的表达式上建索引
+	for _, key := range l.promotedFields {
+		name := fmt.Sprintf("idx_%s_cf_%s", l.tableName, indexNameSuffix(key))
+		stmts = append(stmts, l.dialect.CreateIndex(name, l.tableName, "("+l.dialect.JSONText("custom_fields", key)+")"))
+	}
+	if l.indexCustomFields {
+		if stmt := l.dialect.InvertedIndex(fmt.Sprintf("idx_%s_custom_fields", l.tableName), l.tableName, "custom_fields"); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	if l.searchIndexes {
+		stmts = append(stmts, l.dialect.SearchIndexStmts(l.tableName)...)
+	}
+	return stmts
+}
+
+// indexNameSuffix 将字段名中不能用于索引名的字符替换为下划线
+func indexNameSuffix(key string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(key)
+}
+
+// FieldKeys 在最近 sample 条日志中发现自定义字段，sample <= 0 时使用 DefaultFieldKeySample
+func (l *DBLogger) FieldKeys(sample int) ([]FieldKey, error) {
+	if sample <= 0 {
+		sample = DefaultFieldKeySample
+	}
+	query := fmt.Sprintf("SELECT custom_fields FROM %s ORDER BY created_at DESC, id DESC LIMIT ?", l.tableName)
+	rows, err := l.db.Query(Rebind(l.dialect, query), sample)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counter := newFieldKeyCounter()
+	for rows.Next() {
+		var customFields sql.NullString
+		if err := rows.Scan(&customFields); err != nil {
+			return nil, err
+		}
+		counter.add(customFields.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	promoted := l.promotedFields
+	l.mu.RUnlock()
+	return counter.result(promoted), nil
 }
 
 // joinStrings 辅助函数
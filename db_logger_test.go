@@ -0,0 +1,504 @@
+package reqlogmid
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDBLogger 创建基于临时 SQLite 数据库的同步 DBLogger 并建表
+func newTestDBLogger(t *testing.T) *DBLogger {
+	t.Helper()
+	l, err := NewDBLogger("sqlite", filepath.Join(t.TempDir(), "logs.db"), false, 0)
+	if err != nil {
+		t.Fatalf("NewDBLogger: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	if err := l.CreateTable(); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	return l
+}
+
+func TestDBLoggerCreateTableIsIdempotent(t *testing.T) {
+	l := newTestDBLogger(t)
+	if err := l.CreateTable(); err != nil {
+		t.Fatalf("second CreateTable: %v", err)
+	}
+}
+
+func TestDBLoggerQueryAndCount(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	entries := []LogEntry{
+		{Method: "GET", Path: "/api/users", Route: "/api/users", ClientIP: "10.0.0.1", StatusCode: 200, Duration: 12},
+		{Method: "GET", Path: "/api/users/1", Route: "/api/users/:id", ClientIP: "10.0.0.2", StatusCode: 404, Duration: 3},
+		{Method: "POST", Path: "/api/users", Route: "/api/users", ClientIP: "10.0.0.1", StatusCode: 201, Duration: 40},
+		{Method: "DELETE", Path: "/api/users/2", Route: "/api/users/:id", ClientIP: "192.168.1.5", StatusCode: 500, Duration: 90,
+			Error: "boom", CustomFields: map[string]interface{}{"tenant": "acme"}},
+	}
+	for i := range entries {
+		entries[i].Timestamp = time.Now().Format(time.RFC3339)
+		if err := l.Write(&entries[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   int
+	}{
+		{"all", LogFilter{}, 4},
+		{"method", LogFilter{Methods: []string{"GET"}}, 2},
+		{"path prefix", LogFilter{PathPrefix: "/api/users/"}, 2},
+		{"route", LogFilter{Routes: []string{"/api/users/:id"}}, 2},
+		{"status class", LogFilter{StatusClasses: []int{4, 5}}, 2},
+		{"status range", LogFilter{MinStatus: 200, MaxStatus: 299}, 2},
+		{"duration", LogFilter{MinDuration: 10, MaxDuration: 50}, 2},
+		{"client ip", LogFilter{ClientIPs: []string{"10.0.0.1"}}, 2},
+		{"time range", LogFilter{StartTime: time.Now().Add(-time.Minute), EndTime: time.Now().Add(time.Minute)}, 4},
+		{"future", LogFilter{StartTime: time.Now().Add(time.Hour)}, 0},
+		{"custom field", LogFilter{Fields: []FieldPredicate{{Key: "tenant", Op: FieldEq, Value: "acme"}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := l.QueryLogs(0, 10, tt.filter)
+			if err != nil {
+				t.Fatalf("QueryLogs: %v", err)
+			}
+			count, err := l.CountLogs(tt.filter)
+			if err != nil {
+				t.Fatalf("CountLogs: %v", err)
+			}
+			if len(logs) != tt.want || count != int64(tt.want) {
+				t.Errorf("got %d logs, count %d, want %d", len(logs), count, tt.want)
+			}
+		})
+	}
+
+	logs, err := l.QueryLogs(0, 10, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs[0].Method != "DELETE" || logs[len(logs)-1].Method != "GET" {
+		t.Errorf("logs not ordered newest first: %s ... %s", logs[0].Method, logs[len(logs)-1].Method)
+	}
+	if since := time.Since(logs[0].CreatedAt); since < 0 || since > time.Minute {
+		t.Errorf("created_at %v not read back as local time", logs[0].CreatedAt)
+	}
+
+	got, err := l.GetLogByID(logs[0].ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetLogByID: %v, %v", got, err)
+	}
+	if got.Error != "boom" || got.Route != "/api/users/:id" {
+		t.Errorf("GetLogByID returned %+v", got)
+	}
+	if missing, err := l.GetLogByID(-1); missing != nil || err != nil {
+		t.Errorf("GetLogByID(-1) = %v, %v, want nil, nil", missing, err)
+	}
+
+	page, err := l.QueryLogs(2, 2, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != logs[2].ID {
+		t.Errorf("offset page = %v, want ids starting at %d", page, logs[2].ID)
+	}
+}
+
+func TestDBLoggerDeleteOldLogs(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	now := time.Now()
+	ages := []int{0, 2, 8, 30}
+	for _, days := range ages {
+		query := fmt.Sprintf("INSERT INTO %s (method, path, client_ip, status_code, duration_ms, timestamp, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)", l.tableName)
+		if _, err := l.db.Exec(query, "GET", "/", "127.0.0.1", 200, 1.0, "", now.AddDate(0, 0, -days).Format(filterTimeFormat)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		days        int
+		wantDeleted int64
+		wantLeft    int64
+	}{
+		{60, 0, 4},
+		{7, 2, 2},
+		{7, 0, 2},
+		{1, 1, 1},
+	}
+	for _, tt := range tests {
+		deleted, err := l.DeleteOldLogs(tt.days)
+		if err != nil {
+			t.Fatalf("DeleteOldLogs(%d): %v", tt.days, err)
+		}
+		left, err := l.CountLogs(LogFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != tt.wantDeleted || left != tt.wantLeft {
+			t.Errorf("DeleteOldLogs(%d) deleted %d, %d left; want %d deleted, %d left", tt.days, deleted, left, tt.wantDeleted, tt.wantLeft)
+		}
+	}
+}
+
+func TestDBLoggerGetStats(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	for _, status := range []int{200, 200, 200, 500} {
+		if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: status, Duration: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	today, total, avg, errorRate, err := l.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if today != 4 || total != 4 || avg != 10 || errorRate != 25 {
+		t.Errorf("GetStats = %d, %d, %v, %v; want 4, 4, 10, 25", today, total, avg, errorRate)
+	}
+}
+
+func TestDBLoggerUsesRequestTime(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	requestTime := time.Now().Add(-3 * time.Hour).Truncate(time.Millisecond)
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 200, RequestTime: requestTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := l.QueryLogs(0, 1, LogFilter{EndTime: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || !logs[0].CreatedAt.Equal(requestTime) {
+		t.Errorf("created_at = %v, want request time %v", logs, requestTime)
+	}
+}
+
+func TestDBLoggerPermanentErrorsDoNotTripBreaker(t *testing.T) {
+	l := newTestDBLogger(t)
+	l.retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Second}
+	l.breaker = NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+
+	// 表不存在是语句错误，不应重试也不应打开熔断器
+	if _, err := l.db.Exec("DROP TABLE " + l.tableName); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 200}); err == nil {
+			t.Fatal("write to missing table succeeded")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("permanent errors were retried, took %v", elapsed)
+	}
+	if state := l.breaker.State(); state != CircuitClosed {
+		t.Errorf("breaker state = %v, want closed", state)
+	}
+	if stats := l.Stats(); stats.Failed != 5 {
+		t.Errorf("failed = %d, want 5", stats.Failed)
+	}
+}
+
+func TestDBLoggerStoresCreatedAtInFilterFormat(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	requestTime := time.Date(2026, 3, 1, 9, 40, 0, 0, time.Local)
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 200, RequestTime: requestTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 紧贴请求时间两侧的范围都应按时间而不是按编码格式比较
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   int64
+	}{
+		{"inside", LogFilter{StartTime: requestTime.Add(-time.Minute), EndTime: requestTime.Add(time.Minute)}, 1},
+		{"before", LogFilter{EndTime: requestTime.Add(-time.Second)}, 0},
+		{"after", LogFilter{StartTime: requestTime.Add(time.Second)}, 0},
+	}
+	for _, tt := range tests {
+		count, err := l.CountLogs(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.want {
+			t.Errorf("%s: count = %d, want %d", tt.name, count, tt.want)
+		}
+	}
+}
+
+func TestDBLoggerShutdownInterruptsPartitionMaintenance(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	// 模拟一条长时间运行的维护语句，只在 writeCtx 取消时返回
+	l.partitionQuit = make(chan struct{})
+	l.partitionWg.Add(1)
+	go func() {
+		defer l.partitionWg.Done()
+		l.db.ExecContext(l.writeCtx, "WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r) SELECT COUNT(*) FROM r")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Shutdown waited %v for partition maintenance", elapsed)
+	}
+}
+
+func TestDBLoggerCursorPagingWithTiedTimes(t *testing.T) {
+	l := newTestDBLogger(t)
+
+	// 多条日志落在同一毫秒，游标须靠 id 区分，翻页既不重复也不遗漏
+	tied := time.Date(2026, 3, 1, 9, 40, 0, 123000000, time.Local)
+	times := []time.Time{tied.Add(-time.Second), tied, tied, tied, tied, tied, tied.Add(time.Second)}
+	for i, at := range times {
+		entry := &LogEntry{Method: "GET", Path: fmt.Sprintf("/%d", i+1), ClientIP: "127.0.0.1", StatusCode: 200, RequestTime: at}
+		if err := l.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "[/7 /6 /5 /4 /3 /2 /1]"
+
+	var forward []DBLogEntry
+	var cursor *LogCursor
+	var last *LogPage
+	for i := 0; ; i++ {
+		if i > len(times) {
+			t.Fatalf("next_cursor did not terminate, got %s so far", logPaths(forward))
+		}
+		page, err := l.QueryLogsByCursor(cursor, 2, LogFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		forward = append(forward, page.Logs...)
+		last = page
+		if page.NextCursor == "" {
+			break
+		}
+		c, err := ParseLogCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor = &c
+	}
+	if got := logPaths(forward); got != want {
+		t.Errorf("forward pages = %s, want %s", got, want)
+	}
+
+	// 从最后一页沿 prev_cursor 翻回第一页
+	backward := last.Logs
+	for i := 0; last.PrevCursor != ""; i++ {
+		if i > len(times) {
+			t.Fatalf("prev_cursor did not terminate, got %s so far", logPaths(backward))
+		}
+		c, err := ParseLogCursor(last.PrevCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := l.QueryLogsByCursor(&c, 2, LogFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		backward = append(append([]DBLogEntry{}, page.Logs...), backward...)
+		last = page
+	}
+	if got := logPaths(backward); got != want {
+		t.Errorf("backward pages = %s, want %s", got, want)
+	}
+}
+
+func TestDBLoggerFieldKeys(t *testing.T) {
+	l := newTestDBLogger(t)
+	if err := l.SetPromotedFields("tenant"); err != nil {
+		t.Fatal(err)
+	}
+	mem := NewMemoryLogger(10)
+
+	entries := []LogEntry{
+		{CustomFields: map[string]interface{}{"tenant": "acme", "user_id": 42, "tags": []string{"a"}}},
+		{},
+		{CustomFields: map[string]interface{}{"tenant": "globex", "user_id": "u-7", "meta": map[string]interface{}{"k": 1}}},
+		{CustomFields: map[string]interface{}{"tenant": nil, "beta": true}},
+	}
+	for i := range entries {
+		e := entries[i]
+		e.Method, e.Path, e.ClientIP, e.StatusCode = "GET", "/", "127.0.0.1", 200
+		e.RequestTime = time.Date(2026, 3, 1, 9, 40, i, 0, time.Local)
+		if err := l.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		sample int
+		want   []FieldKey
+	}{
+		{"all", 0, []FieldKey{
+			{Key: "tenant", Count: 3, Types: []string{"null", "string"}, Promoted: true},
+			{Key: "user_id", Count: 2, Types: []string{"number", "string"}},
+			{Key: "beta", Count: 1, Types: []string{"bool"}},
+			{Key: "meta", Count: 1, Types: []string{"object"}},
+			{Key: "tags", Count: 1, Types: []string{"array"}},
+		}},
+		// 只采样最近的两条：第 4 条和第 3 条
+		{"sample", 2, []FieldKey{
+			{Key: "tenant", Count: 2, Types: []string{"null", "string"}, Promoted: true},
+			{Key: "beta", Count: 1, Types: []string{"bool"}},
+			{Key: "meta", Count: 1, Types: []string{"object"}},
+			{Key: "user_id", Count: 1, Types: []string{"string"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.FieldKeys(tt.sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FieldKeys(%d) = %+v\nwant %+v", tt.sample, got, tt.want)
+			}
+
+			// MemoryLogger 不建索引，不标记提升字段，其余一致
+			memKeys, err := mem.FieldKeys(tt.sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range memKeys {
+				memKeys[i].Promoted = containsString([]string{"tenant"}, memKeys[i].Key)
+			}
+			if fmt.Sprint(memKeys) != fmt.Sprint(got) {
+				t.Errorf("memory FieldKeys(%d) = %+v, sqlite = %+v", tt.sample, memKeys, got)
+			}
+		})
+	}
+}
+
+func TestDBLoggerPromotedFieldIndexes(t *testing.T) {
+	l, err := NewDBLoggerWithConfig(DBConfig{
+		Driver:         "sqlite",
+		DSN:            filepath.Join(t.TempDir(), "logs.db"),
+		PromotedFields: []string{"tenant", "user.id"},
+	}, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"idx_request_logs_cf_tenant", "idx_request_logs_cf_user_id"} {
+		var count int
+		if err := l.DB().QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("index %s not created", name)
+		}
+	}
+
+	// 查询条件与索引使用同一表达式，应命中索引
+	tests := []struct {
+		filter LogFilter
+		index  string
+	}{
+		{LogFilter{Fields: []FieldPredicate{{Key: "tenant", Value: "acme"}}}, "idx_request_logs_cf_tenant"},
+		{LogFilter{Fields: []FieldPredicate{{Key: "user.id", Op: FieldIn, Values: []string{"1", "2"}}}}, "idx_request_logs_cf_user_id"},
+	}
+	for _, tt := range tests {
+		where, args, err := tt.filter.whereClause(l.Dialect(), "request_logs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := l.DB().Query("EXPLAIN QUERY PLAN SELECT id FROM request_logs"+where, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, notused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+				t.Fatal(err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+		if !strings.Contains(strings.Join(plan, "\n"), tt.index) {
+			t.Errorf("%s: query plan %q does not use %s", where, plan, tt.index)
+		}
+	}
+
+	if err := l.SetPromotedFields("bad key"); err == nil {
+		t.Error("SetPromotedFields accepted an invalid key")
+	}
+	if _, err := NewDBLoggerWithConfig(DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "x.db"), PromotedFields: []string{"a'b"}}, false, 0); err == nil {
+		t.Error("NewDBLoggerWithConfig accepted an invalid promoted field")
+	}
+}
+
+func TestDBLoggerFullTextIndexesExistingLogs(t *testing.T) {
+	l := newTestDBLogger(t)
+	for _, msg := range []string{"connection refused", "upstream timeout"} {
+		if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 502, Error: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 对已有日志的表启用检索：首次建立检索表时补建存量日志，重复 CreateTable 不重复索引
+	l.SetSearchIndexes(true)
+	for i := 0; i < 2; i++ {
+		if err := l.CreateTable(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 504, Error: "gateway timeout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  int64
+	}{
+		{"refused", 1},
+		{"timeout", 2},
+		{"upstream timeout", 1},
+		{"missing", 0},
+	}
+	for _, tt := range tests {
+		count, err := l.CountLogs(LogFilter{Query: tt.query, SearchMode: SearchFullText})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.want {
+			t.Errorf("%q: count = %d, want %d", tt.query, count, tt.want)
+		}
+	}
+
+	var indexed int
+	if err := l.DB().QueryRow("SELECT COUNT(*) FROM request_logs_fts_docsize").Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 3 {
+		t.Errorf("fts index holds %d documents, want 3", indexed)
+	}
+}
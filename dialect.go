@@ -0,0 +1,484 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect 抽象不同数据库之间的 SQL 方言差异
+// DBLogger 和 admin.ConfigRepository 通过它生成占位符、建表语句和时间运算
+type Dialect interface {
+	// Name 返回方言名称：postgres、mysql、sqlite
+	Name() string
+	// Placeholder 返回第 n 个参数的占位符（从 1 开始）
+	Placeholder(n int) string
+	// AutoIncrementPrimaryKey 返回自增主键列定义
+	AutoIncrementPrimaryKey() string
+	// JSONType 返回存储 JSON 的列类型
+	JSONType() string
+	// TimestampType 返回时间列类型
+	TimestampType() string
+	// CurrentTimestamp 返回时间列默认值表达式，精度与 TimestampType 一致
+	CurrentTimestamp() string
+	// DaysAgo 返回表示“当前时间减去 days 天”的 SQL 表达式
+	DaysAgo(days int) string
+	// IsToday 返回判断列是否为今天的 SQL 条件
+	IsToday(column string) string
+	// CreateIndex 返回创建索引的语句
+	CreateIndex(name, table, column string) string
+	// Upsert 返回按 conflictColumn 冲突时更新其余列的插入语句，占位符为 ?，执行前需经 Rebind
+	Upsert(table, conflictColumn string, columns []string) string
+	// Like 返回列与一个参数做 LIKE 匹配的条件，参数需经 EscapeLike 转义
+	// 大小写敏感性随数据库而定（PostgreSQL 敏感，MySQL/SQLite 默认不敏感），需要一致语义时两侧都转为小写
+	Like(column string) string
+	// Regexp 返回列与一个参数做正则匹配的条件，不支持时返回空字符串
+	Regexp(column string) string
+	// JSONText 返回取 JSON 列中 key 的值（文本形式）的表达式，key 需由调用方校验
+	// 提升字段的表达式索引使用同一表达式，查询才能命中索引
+	JSONText(column, key string) string
+	// JSONNumber 返回取 JSON 列中 key 的数值的表达式，值不是 JSON 数字时为 NULL
+	JSONNumber(column, key string) string
+	// JSONHasKey 返回判断 JSON 列中存在 key 的条件（值为 null 也算存在）
+	JSONHasKey(column, key string) string
+	// InvertedIndex 返回在 JSON 列上建倒排（GIN）索引的语句，不支持时返回空字符串
+	InvertedIndex(name, table, column string) string
+	// InetContains 返回“列中的 IP 属于参数给定的网段”条件，不支持时返回空字符串
+	// 列中不是合法 IP 的值视为不匹配，不能导致查询报错
+	InetContains(column string) string
+	// MinuteKey 返回将时间列截断到分钟的文本表达式，格式为 2006-01-02 15:04（存储的本地时间）
+	MinuteKey(column string) string
+	// Percentile 返回列的连续分位数（线性插值）聚合表达式，不支持时返回空字符串
+	Percentile(column string, p float64) string
+	// SearchDocument 返回拼接路径、查询字符串、User-Agent、错误信息和自定义字段的检索文本表达式
+	SearchDocument() string
+	// FullTextMatch 返回全文检索条件及其参数，条件中有一个占位符；不支持时返回空字符串
+	FullTextMatch(table, query string) (string, interface{})
+	// SearchIndexStmts 返回支撑检索的索引（或检索表）语句，不需要时返回 nil
+	SearchIndexStmts(table string) []string
+}
+
+// searchColumns 参与检索的列，SQLite FTS5 检索表使用相同的列名
+var searchColumns = []string{"path", "query_string", "user_agent", "error_message", "custom_fields"}
+
+// concatSearchDocument 用 || 拼接检索列（PostgreSQL 和 SQLite），NULL 视为空串
+// jsonCast 为 custom_fields 转文本的写法
+func concatSearchDocument(jsonCast string) string {
+	parts := make([]string, len(searchColumns))
+	for i, col := range searchColumns {
+		if col == "custom_fields" {
+			col = jsonCast
+		}
+		parts[i] = fmt.Sprintf("COALESCE(%s, '')", col)
+	}
+	return "(" + strings.Join(parts, " || ' ' || ") + ")"
+}
+
+// DialectFor 根据驱动名返回对应的方言，未知驱动按 PostgreSQL 处理
+func DialectFor(driver string) Dialect {
+	switch driver {
+	case "mysql":
+		return MySQLDialect{}
+	case "sqlite", "sqlite3":
+		return SQLiteDialect{}
+	default:
+		return PostgresDialect{}
+	}
+}
+
+// Rebind 将查询中的 ? 占位符替换为方言对应的占位符
+// 单引号字符串字面量中的 ? 不会被替换，?? 表示字面量 ?（如 PostgreSQL 的 jsonb ? 运算符）
+func Rebind(d Dialect, query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	inQuote := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			inQuote = !inQuote
+			sb.WriteByte(ch)
+		case ch == '?' && !inQuote && i+1 < len(query) && query[i+1] == '?':
+			sb.WriteByte('?')
+			i++
+		case ch == '?' && !inQuote:
+			n++
+			sb.WriteString(d.Placeholder(n))
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// placeholders 返回 n 个以逗号分隔的 ? 占位符
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
+
+// PostgresDialect PostgreSQL 方言
+type PostgresDialect struct{}
+
+// Name 实现 Dialect 接口
+func (PostgresDialect) Name() string { return "postgres" }
+
+// Placeholder 实现 Dialect 接口
+func (PostgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// AutoIncrementPrimaryKey 实现 Dialect 接口
+func (PostgresDialect) AutoIncrementPrimaryKey() string { return "BIGSERIAL PRIMARY KEY" }
+
+// JSONType 实现 Dialect 接口
+func (PostgresDialect) JSONType() string { return "JSONB" }
+
+// TimestampType 实现 Dialect 接口
+func (PostgresDialect) TimestampType() string { return "TIMESTAMP" }
+
+// CurrentTimestamp 实现 Dialect 接口
+func (PostgresDialect) CurrentTimestamp() string { return "CURRENT_TIMESTAMP" }
+
+// DaysAgo 实现 Dialect 接口
+func (PostgresDialect) DaysAgo(days int) string {
+	return fmt.Sprintf("NOW() - INTERVAL '%d days'", days)
+}
+
+// IsToday 实现 Dialect 接口
+func (PostgresDialect) IsToday(column string) string {
+	return fmt.Sprintf("DATE(%s) = CURRENT_DATE", column)
+}
+
+// CreateIndex 实现 Dialect 接口
+func (PostgresDialect) CreateIndex(name, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)", name, table, column)
+}
+
+// Upsert 实现 Dialect 接口
+func (PostgresDialect) Upsert(table, conflictColumn string, columns []string) string {
+	return conflictUpsert(table, conflictColumn, columns)
+}
+
+// Like 实现 Dialect 接口
+func (PostgresDialect) Like(column string) string {
+	return column + ` LIKE ? ESCAPE '\'`
+}
+
+// Regexp 实现 Dialect 接口
+func (PostgresDialect) Regexp(column string) string {
+	return column + " ~ ?"
+}
+
+// JSONText 实现 Dialect 接口
+func (PostgresDialect) JSONText(column, key string) string {
+	return fmt.Sprintf("%s->>'%s'", column, key)
+}
+
+// JSONNumber 实现 Dialect 接口
+func (PostgresDialect) JSONNumber(column, key string) string {
+	return fmt.Sprintf("CASE WHEN jsonb_typeof(%s->'%s') = 'number' THEN (%s->>'%s')::numeric END", column, key, column, key)
+}
+
+// JSONHasKey 实现 Dialect 接口，使用 jsonb 的 ? 运算符，可命中 GIN 索引
+func (PostgresDialect) JSONHasKey(column, key string) string {
+	return fmt.Sprintf("%s ?? '%s'", column, key)
+}
+
+// InvertedIndex 实现 Dialect 接口
+func (PostgresDialect) InvertedIndex(name, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)", name, table, column)
+}
+
+// InetContains 实现 Dialect 接口
+// client_ip 为文本列，可能含有代理写入的非 IP 值，直接转换为 inet 会使整个查询报错，
+// 因此先用正则筛出合法的 IP 文本再转换，其余值视为不匹配
+func (PostgresDialect) InetContains(column string) string {
+	return fmt.Sprintf("CASE WHEN %s ~ '%s' THEN %s::inet <<= ?::inet ELSE FALSE END", column, inetPattern, column)
+}
+
+// inetPattern 匹配可安全转换为 inet 的 IP 文本：点分十进制 IPv4，或不含内嵌 IPv4 的 IPv6
+// 使用 PostgreSQL 与 Go regexp 共同支持的语法，且不含 ? 和反斜杠，可直接写入 SQL 字面量
+var inetPattern = func() string {
+	octet := "(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])"
+	h := "[0-9a-fA-F]{1,4}"
+	ipv6 := []string{
+		"(" + h + ":){7}" + h,
+		"(" + h + ":){1,7}:",
+		"(" + h + ":){1,6}:" + h,
+		"(" + h + ":){1,5}(:" + h + "){1,2}",
+		"(" + h + ":){1,4}(:" + h + "){1,3}",
+		"(" + h + ":){1,3}(:" + h + "){1,4}",
+		"(" + h + ":){1,2}(:" + h + "){1,5}",
+		h + ":(:" + h + "){1,6}",
+		":((:" + h + "){1,7}|:)",
+	}
+	return "^((" + octet + "[.]){3}" + octet + "|" + strings.Join(ipv6, "|") + ")$"
+}()
+
+// MinuteKey 实现 Dialect 接口
+func (PostgresDialect) MinuteKey(column string) string {
+	return "to_char(" + column + ", 'YYYY-MM-DD HH24:MI')"
+}
+
+// Percentile 实现 Dialect 接口
+func (PostgresDialect) Percentile(column string, p float64) string {
+	return fmt.Sprintf("percentile_cont(%g) WITHIN GROUP (ORDER BY %s)", p, column)
+}
+
+// SearchDocument 实现 Dialect 接口
+func (PostgresDialect) SearchDocument() string {
+	return concatSearchDocument("custom_fields::text")
+}
+
+// FullTextMatch 实现 Dialect 接口
+// 使用 simple 配置不做词干化，websearch_to_tsquery（PostgreSQL 11+）支持引号短语和 -排除，任意输入都不会报错
+func (d PostgresDialect) FullTextMatch(table, query string) (string, interface{}) {
+	return fmt.Sprintf("to_tsvector('simple', %s) @@ websearch_to_tsquery('simple', ?)", d.SearchDocument()), query
+}
+
+// SearchIndexStmts 实现 Dialect 接口
+// 子串检索使用 pg_trgm 三元组索引，全文检索使用 tsvector 表达式索引
+func (d PostgresDialect) SearchIndexStmts(table string) []string {
+	return []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search_trgm ON %s USING GIN (LOWER(%s) gin_trgm_ops)", table, table, d.SearchDocument()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search_tsv ON %s USING GIN (to_tsvector('simple', %s))", table, table, d.SearchDocument()),
+	}
+}
+
+// MySQLDialect MySQL 方言
+type MySQLDialect struct{}
+
+// Name 实现 Dialect 接口
+func (MySQLDialect) Name() string { return "mysql" }
+
+// Placeholder 实现 Dialect 接口
+func (MySQLDialect) Placeholder(n int) string { return "?" }
+
+// AutoIncrementPrimaryKey 实现 Dialect 接口
+func (MySQLDialect) AutoIncrementPrimaryKey() string { return "BIGINT AUTO_INCREMENT PRIMARY KEY" }
+
+// JSONType 实现 Dialect 接口
+func (MySQLDialect) JSONType() string { return "JSON" }
+
+// TimestampType 实现 Dialect 接口
+func (MySQLDialect) TimestampType() string { return "DATETIME(3)" }
+
+// CurrentTimestamp 实现 Dialect 接口，DATETIME(3) 的默认值须带相同精度，否则 MySQL 报 1067
+func (MySQLDialect) CurrentTimestamp() string { return "CURRENT_TIMESTAMP(3)" }
+
+// DaysAgo 实现 Dialect 接口
+func (MySQLDialect) DaysAgo(days int) string {
+	return fmt.Sprintf("DATE_SUB(NOW(), INTERVAL %d DAY)", days)
+}
+
+// IsToday 实现 Dialect 接口
+func (MySQLDialect) IsToday(column string) string {
+	return fmt.Sprintf("DATE(%s) = CURDATE()", column)
+}
+
+// CreateIndex 实现 Dialect 接口
+// MySQL 不支持 IF NOT EXISTS，索引已存在时执行会报错，调用方应忽略该错误
+func (MySQLDialect) CreateIndex(name, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s(%s)", name, table, column)
+}
+
+// Upsert 实现 Dialect 接口
+func (MySQLDialect) Upsert(table, conflictColumn string, columns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col == conflictColumn {
+			continue
+		}
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", col, col))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		table, strings.Join(columns, ", "), placeholders(len(columns)), strings.Join(updates, ", "))
+}
+
+// Like 实现 Dialect 接口
+// MySQL 默认以反斜杠转义，且字符串字面量中的反斜杠需要双写，因此不写 ESCAPE 子句
+func (MySQLDialect) Like(column string) string {
+	return column + " LIKE ?"
+}
+
+// Regexp 实现 Dialect 接口
+func (MySQLDialect) Regexp(column string) string {
+	return column + " REGEXP ?"
+}
+
+// JSONText 实现 Dialect 接口
+// 转为定长字符串并指定排序规则，与函数索引的表达式一致（MySQL 8.0.13+），超过 255 个字符的值会被截断
+func (MySQLDialect) JSONText(column, key string) string {
+	return fmt.Sprintf(`(CAST(JSON_UNQUOTE(JSON_EXTRACT(%s, '$."%s"')) AS CHAR(255)) COLLATE utf8mb4_bin)`, column, key)
+}
+
+// JSONNumber 实现 Dialect 接口
+func (MySQLDialect) JSONNumber(column, key string) string {
+	path := fmt.Sprintf(`JSON_EXTRACT(%s, '$."%s"')`, column, key)
+	return fmt.Sprintf("CASE WHEN JSON_TYPE(%s) IN ('INTEGER', 'UNSIGNED INTEGER', 'DOUBLE', 'DECIMAL') THEN CAST(%s AS DECIMAL(38, 10)) END", path, path)
+}
+
+// JSONHasKey 实现 Dialect 接口
+func (MySQLDialect) JSONHasKey(column, key string) string {
+	return fmt.Sprintf(`JSON_CONTAINS_PATH(%s, 'one', '$."%s"')`, column, key)
+}
+
+// InvertedIndex 实现 Dialect 接口（不支持）
+func (MySQLDialect) InvertedIndex(name, table, column string) string { return "" }
+
+// InetContains 实现 Dialect 接口（不支持，由调用方改用前缀匹配）
+func (MySQLDialect) InetContains(column string) string { return "" }
+
+// MinuteKey 实现 Dialect 接口
+func (MySQLDialect) MinuteKey(column string) string {
+	return "DATE_FORMAT(" + column + ", '%Y-%m-%d %H:%i')"
+}
+
+// Percentile 实现 Dialect 接口（不支持，由调用方估算）
+func (MySQLDialect) Percentile(column string, p float64) string { return "" }
+
+// SearchDocument 实现 Dialect 接口，CONCAT_WS 会跳过 NULL
+func (MySQLDialect) SearchDocument() string {
+	return "CONCAT_WS(' ', path, query_string, user_agent, error_message, CAST(custom_fields AS CHAR))"
+}
+
+// FullTextMatch 实现 Dialect 接口（不支持：FULLTEXT 索引不能包含 JSON 列）
+func (MySQLDialect) FullTextMatch(table, query string) (string, interface{}) { return "", nil }
+
+// SearchIndexStmts 实现 Dialect 接口（子串检索无法使用索引）
+func (MySQLDialect) SearchIndexStmts(table string) []string { return nil }
+
+// SQLiteDialect SQLite 方言
+type SQLiteDialect struct{}
+
+// Name 实现 Dialect 接口
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+// Placeholder 实现 Dialect 接口
+func (SQLiteDialect) Placeholder(n int) string { return "?" }
+
+// AutoIncrementPrimaryKey 实现 Dialect 接口
+func (SQLiteDialect) AutoIncrementPrimaryKey() string { return "INTEGER PRIMARY KEY AUTOINCREMENT" }
+
+// JSONType 实现 Dialect 接口
+func (SQLiteDialect) JSONType() string { return "TEXT" }
+
+// TimestampType 实现 Dialect 接口
+func (SQLiteDialect) TimestampType() string { return "DATETIME" }
+
+// CurrentTimestamp 实现 Dialect 接口
+// CURRENT_TIMESTAMP 是 UTC 且不带毫秒，与写入的本地时间文本无法按文本比较，因此生成相同格式的本地时间
+func (SQLiteDialect) CurrentTimestamp() string {
+	return "(strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime'))"
+}
+
+// DaysAgo 实现 Dialect 接口
+func (SQLiteDialect) DaysAgo(days int) string {
+	return fmt.Sprintf("datetime('now', 'localtime', '-%d days')", days)
+}
+
+// IsToday 实现 Dialect 接口
+// 时间以本地时间文本存储，取前 10 位即为日期
+func (SQLiteDialect) IsToday(column string) string {
+	return fmt.Sprintf("substr(%s, 1, 10) = date('now', 'localtime')", column)
+}
+
+// CreateIndex 实现 Dialect 接口
+func (SQLiteDialect) CreateIndex(name, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)", name, table, column)
+}
+
+// Upsert 实现 Dialect 接口
+func (SQLiteDialect) Upsert(table, conflictColumn string, columns []string) string {
+	return conflictUpsert(table, conflictColumn, columns)
+}
+
+// Like 实现 Dialect 接口
+func (SQLiteDialect) Like(column string) string {
+	return column + ` LIKE ? ESCAPE '\'`
+}
+
+// Regexp 实现 Dialect 接口
+// SQLite 的 REGEXP 需要驱动注册自定义函数，默认不支持
+func (SQLiteDialect) Regexp(column string) string { return "" }
+
+// JSONText 实现 Dialect 接口
+// json_extract 对数字返回数值类型、对布尔值返回 1/0，转为与其他数据库一致的 JSON 文本后再比较
+func (SQLiteDialect) JSONText(column, key string) string {
+	return fmt.Sprintf(`CASE json_type(%s, '$."%s"') WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' ELSE CAST(json_extract(%s, '$."%s"') AS TEXT) END`,
+		column, key, column, key)
+}
+
+// JSONNumber 实现 Dialect 接口
+func (SQLiteDialect) JSONNumber(column, key string) string {
+	return fmt.Sprintf(`CASE WHEN json_type(%s, '$."%s"') IN ('integer', 'real') THEN json_extract(%s, '$."%s"') END`, column, key, column, key)
+}
+
+// JSONHasKey 实现 Dialect 接口
+// json_type 对 JSON null 返回 'null'，键不存在时返回 SQL NULL
+func (SQLiteDialect) JSONHasKey(column, key string) string {
+	return fmt.Sprintf(`json_type(%s, '$."%s"') IS NOT NULL`, column, key)
+}
+
+// InvertedIndex 实现 Dialect 接口（不支持）
+func (SQLiteDialect) InvertedIndex(name, table, column string) string { return "" }
+
+// InetContains 实现 Dialect 接口（不支持，由调用方改用前缀匹配）
+func (SQLiteDialect) InetContains(column string) string { return "" }
+
+// MinuteKey 实现 Dialect 接口
+// 时间以本地时间文本存储，取前 16 位即为分钟；避免 strftime 按时区后缀换算成 UTC
+func (SQLiteDialect) MinuteKey(column string) string {
+	return "substr(" + column + ", 1, 16)"
+}
+
+// Percentile 实现 Dialect 接口（不支持，由调用方估算）
+func (SQLiteDialect) Percentile(column string, p float64) string { return "" }
+
+// SearchDocument 实现 Dialect 接口
+func (SQLiteDialect) SearchDocument() string {
+	return concatSearchDocument("custom_fields")
+}
+
+// FullTextMatch 实现 Dialect 接口
+// 使用 <table>_fts 检索表（FTS5），查询按空白拆分为词，每个词作为短语加引号，避免 FTS5 语法注入
+func (SQLiteDialect) FullTextMatch(table, query string) (string, interface{}) {
+	terms := strings.Fields(query)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return fmt.Sprintf("id IN (SELECT rowid FROM %s_fts WHERE %s_fts MATCH ?)", table, table), strings.Join(terms, " ")
+}
+
+// SearchIndexStmts 实现 Dialect 接口
+// 建立外部内容 FTS5 检索表，并用触发器与日志表保持同步；modernc.org/sqlite 已内置 FTS5
+// 检索表刚建立时为空，已有日志的表需 rebuild 一次才能检索到存量日志；以 docsize 影子表为空作为判断，重复执行不会重建
+func (SQLiteDialect) SearchIndexStmts(table string) []string {
+	cols := strings.Join(searchColumns, ", ")
+	newCols := "new." + strings.Join(searchColumns, ", new.")
+	oldCols := "old." + strings.Join(searchColumns, ", old.")
+	return []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s_fts USING fts5(%s, content='%s', content_rowid='id')", table, cols, table),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_fts_ai AFTER INSERT ON %s BEGIN INSERT INTO %s_fts(rowid, %s) VALUES (new.id, %s); END",
+			table, table, table, cols, newCols),
+		fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s_fts_ad AFTER DELETE ON %s BEGIN INSERT INTO %s_fts(%s_fts, rowid, %s) VALUES ('delete', old.id, %s); END",
+			table, table, table, table, cols, oldCols),
+		fmt.Sprintf("INSERT INTO %s_fts(%s_fts) SELECT 'rebuild' WHERE NOT EXISTS (SELECT 1 FROM %s_fts_docsize) AND EXISTS (SELECT 1 FROM %s)",
+			table, table, table, table),
+	}
+}
+
+// conflictUpsert 生成 ON CONFLICT ... DO UPDATE 形式的插入语句（PostgreSQL 和 SQLite 通用）
+func conflictUpsert(table, conflictColumn string, columns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col == conflictColumn {
+			continue
+		}
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", col, col))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		table, strings.Join(columns, ", "), placeholders(len(columns)), conflictColumn, strings.Join(updates, ", "))
+}
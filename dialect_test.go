@@ -0,0 +1,216 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// openTestSQLite 打开临时目录下的 SQLite 数据库
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"postgres numbers placeholders", PostgresDialect{}, "a = ? AND b = ?", "a = $1 AND b = $2"},
+		{"mysql keeps question marks", MySQLDialect{}, "a = ? AND b = ?", "a = ? AND b = ?"},
+		{"sqlite keeps question marks", SQLiteDialect{}, "a = ? AND b = ?", "a = ? AND b = ?"},
+		{"quoted literal untouched", PostgresDialect{}, "a = '?' AND b = ?", "a = '?' AND b = $1"},
+		{"escaped quote inside literal", PostgresDialect{}, "a = 'it''s ?' AND b = ?", "a = 'it''s ?' AND b = $1"},
+		{"double question mark is literal", PostgresDialect{}, "custom_fields ?? ? AND id = ?", "custom_fields ? $1 AND id = $2"},
+		{"double question mark on mysql", MySQLDialect{}, "a ?? b", "a ? b"},
+		{"no placeholders", PostgresDialect{}, "SELECT 1", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rebind(tt.dialect, tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectFor(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{"postgres", "postgres"},
+		{"mysql", "mysql"},
+		{"sqlite", "sqlite"},
+		{"sqlite3", "sqlite"},
+		{"unknown", "postgres"},
+	}
+	for _, tt := range tests {
+		if got := DialectFor(tt.driver).Name(); got != tt.want {
+			t.Errorf("DialectFor(%q) = %q, want %q", tt.driver, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTableStmt(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{PostgresDialect{}, []string{"id BIGSERIAL PRIMARY KEY", "custom_fields JSONB", "created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"}},
+		{MySQLDialect{}, []string{"id BIGINT AUTO_INCREMENT PRIMARY KEY", "custom_fields JSON", "created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)"}},
+		{SQLiteDialect{}, []string{"id INTEGER PRIMARY KEY AUTOINCREMENT", "custom_fields TEXT", "created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime'))"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			l := &DBLogger{dialect: tt.dialect, tableName: "request_logs"}
+			stmt := l.createTableStmt()
+			for _, want := range tt.want {
+				if !strings.Contains(stmt, want) {
+					t.Errorf("create table statement missing %q:\n%s", want, stmt)
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertStatement(t *testing.T) {
+	columns := []string{"id", "name", "value"}
+	tests := []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{PostgresDialect{}, []string{"VALUES (?, ?, ?)", "ON CONFLICT (id) DO UPDATE SET", "name = excluded.name"}},
+		{MySQLDialect{}, []string{"VALUES (?, ?, ?)", "ON DUPLICATE KEY UPDATE", "name = VALUES(name)"}},
+		{SQLiteDialect{}, []string{"VALUES (?, ?, ?)", "ON CONFLICT (id) DO UPDATE SET", "name = excluded.name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			stmt := tt.dialect.Upsert("kv", "id", columns)
+			for _, want := range tt.want {
+				if !strings.Contains(stmt, want) {
+					t.Errorf("upsert statement missing %q:\n%s", want, stmt)
+				}
+			}
+		})
+	}
+
+	// 占位符统一为 ?，由调用方按方言 Rebind
+	if stmt := Rebind(PostgresDialect{}, PostgresDialect{}.Upsert("kv", "id", columns)); !strings.Contains(stmt, "VALUES ($1, $2, $3)") {
+		t.Errorf("rebound postgres upsert = %s", stmt)
+	}
+}
+
+func TestSQLiteUpsert(t *testing.T) {
+	db := openTestSQLite(t)
+	d := SQLiteDialect{}
+	if _, err := db.Exec("CREATE TABLE kv (id INTEGER PRIMARY KEY, name TEXT, value INT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	query := d.Upsert("kv", "id", []string{"id", "name", "value"})
+	for i, v := range []int{1, 2, 3} {
+		if _, err := db.Exec(query, 1, "a", v); err != nil {
+			t.Fatalf("upsert %d: %v", i, err)
+		}
+	}
+
+	var count, value int
+	if err := db.QueryRow("SELECT COUNT(*), MAX(value) FROM kv").Scan(&count, &value); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || value != 3 {
+		t.Errorf("got %d rows with value %d, want 1 row with value 3", count, value)
+	}
+}
+
+func TestSQLiteDaysAgoAndIsToday(t *testing.T) {
+	db := openTestSQLite(t)
+	d := SQLiteDialect{}
+	if _, err := db.Exec("CREATE TABLE t (name TEXT, created_at DATETIME)"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	rows := []struct {
+		name string
+		at   time.Time
+	}{
+		{"now", now},
+		{"two days ago", now.AddDate(0, 0, -2)},
+		{"ten days ago", now.AddDate(0, 0, -10)},
+	}
+	for _, r := range rows {
+		if _, err := db.Exec("INSERT INTO t (name, created_at) VALUES (?, ?)", r.name, r.at.Format(filterTimeFormat)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		where string
+		want  int
+	}{
+		{"older than 1 day", "created_at < " + d.DaysAgo(1), 2},
+		{"older than 7 days", "created_at < " + d.DaysAgo(7), 1},
+		{"older than 30 days", "created_at < " + d.DaysAgo(30), 0},
+		{"today", d.IsToday("created_at"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			if err := db.QueryRow("SELECT COUNT(*) FROM t WHERE " + tt.where).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s: got %d rows, want %d", tt.where, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteCurrentTimestampIsLocalFilterText(t *testing.T) {
+	db := openTestSQLite(t)
+	d := SQLiteDialect{}
+	if _, err := db.Exec("CREATE TABLE t (name TEXT, created_at DATETIME DEFAULT " + d.CurrentTimestamp() + ")"); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Truncate(time.Millisecond)
+	if _, err := db.Exec("INSERT INTO t (name) VALUES ('a')"); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	// 默认值须与写入的 created_at 同为本地时间文本，查询条件才能按文本比较
+	var text string
+	if err := db.QueryRow("SELECT CAST(created_at AS TEXT) FROM t").Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+	got, err := time.ParseInLocation(filterTimeFormat, text, time.Local)
+	if err != nil {
+		t.Fatalf("default %q is not in filter format: %v", text, err)
+	}
+	if got.Before(before) || got.After(after) {
+		t.Errorf("default = %v, want between %v and %v", got, before, after)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM t WHERE created_at >= ?", before.Format(filterTimeFormat)).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("range filter matched %d rows, want 1", count)
+	}
+}
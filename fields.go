@@ -0,0 +1,94 @@
+package reqlogmid
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultFieldKeySample 发现自定义字段时默认采样的最近日志条数
+const DefaultFieldKeySample = 1000
+
+// FieldKey 在最近日志中出现过的自定义字段
+type FieldKey struct {
+	Key      string   `json:"key"`
+	Count    int      `json:"count"`    // 采样中出现的条数
+	Types    []string `json:"types"`    // 出现过的 JSON 类型：string、number、bool、null、object、array
+	Promoted bool     `json:"promoted"` // 是否为建有索引的提升字段
+}
+
+// ValidateFieldKey 校验自定义字段名，只允许字母、数字、下划线、连字符和点
+func ValidateFieldKey(key string) error {
+	if !fieldKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid custom field key %q", key)
+	}
+	return nil
+}
+
+// fieldKeyCounter 统计采样日志中的自定义字段及其类型
+type fieldKeyCounter struct {
+	keys map[string]*FieldKey
+}
+
+func newFieldKeyCounter() *fieldKeyCounter {
+	return &fieldKeyCounter{keys: make(map[string]*FieldKey)}
+}
+
+// add 统计一条日志的 custom_fields（JSON 文本），非对象忽略
+func (c *fieldKeyCounter) add(customFields string) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(customFields), &fields); err != nil {
+		return
+	}
+	for key, raw := range fields {
+		k, ok := c.keys[key]
+		if !ok {
+			k = &FieldKey{Key: key}
+			c.keys[key] = k
+		}
+		k.Count++
+		if t := jsonType(raw); !containsString(k.Types, t) {
+			k.Types = append(k.Types, t)
+		}
+	}
+}
+
+// result 返回按出现次数降序、字段名升序排列的结果，并标记提升字段
+func (c *fieldKeyCounter) result(promoted []string) []FieldKey {
+	keys := make([]FieldKey, 0, len(c.keys))
+	for _, k := range c.keys {
+		sort.Strings(k.Types)
+		k.Promoted = containsString(promoted, k.Key)
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
+
+// jsonType 返回 JSON 值的类型名
+func jsonType(raw json.RawMessage) string {
+	s := strings.TrimSpace(string(raw))
+	if s == "" {
+		return "null"
+	}
+	switch s[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
@@ -2,6 +2,7 @@ package reqlogmid
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -17,7 +18,11 @@ type FileLogger struct {
 	wg       sync.WaitGroup
 	quit     chan struct{}
 	closed   bool
-	mu       sync.Mutex
+	mu       sync.RWMutex
+	writeMu  sync.Mutex // 串行化文件写入
+
+	backpressure backpressure
+	stats        writeStats
 }
 
 // NewFileLogger 创建一个新的文件日志输出器
@@ -31,13 +36,15 @@ func NewFileLogger(filename string, async bool, bufferSize int) (*FileLogger, er
 	}
 
 	logger := &FileLogger{
-		file:     file,
-		writer:   bufio.NewWriterSize(file, 1), // 最小缓冲区，立即刷盘
-		bufferCh: make(chan *LogEntry, bufferSize),
-		quit:     make(chan struct{}),
+		file:   file,
+		writer: bufio.NewWriterSize(file, 1), // 最小缓冲区，立即刷盘
+		quit:   make(chan struct{}),
+		stats:  writeStats{latency: NewHistogram(nil)},
 	}
 
+	// 仅异步模式使用缓冲区
 	if async {
+		logger.bufferCh = make(chan *LogEntry, bufferSize)
 		logger.startAsyncWriter()
 	}
 
@@ -45,23 +52,27 @@ func NewFileLogger(filename string, async bool, bufferSize int) (*FileLogger, er
 }
 
 // startAsyncWriter 启动异步写入协程
+// bufferCh 关闭后写完剩余日志退出；quit 关闭表示关闭超时，立即退出
 func (l *FileLogger) startAsyncWriter() {
 	l.wg.Add(1)
 	go func() {
 		defer l.wg.Done()
 		for {
+			select {
+			case <-l.quit:
+				return
+			default:
+			}
+
 			select {
 			case entry, ok := <-l.bufferCh:
 				if !ok {
-					// 通道关闭，写入剩余日志
-					l.flushBuffer()
 					return
 				}
 				if err := l.writeEntry(entry); err != nil {
 					fmt.Fprintf(os.Stderr, "failed to write log: %v\n", err)
 				}
 			case <-l.quit:
-				l.flushBuffer()
 				return
 			}
 		}
@@ -69,68 +80,111 @@ func (l *FileLogger) startAsyncWriter() {
 }
 
 // writeEntry 写入单条日志
-func (l *FileLogger) writeEntry(entry *LogEntry) error {
+func (l *FileLogger) writeEntry(entry *LogEntry) (err error) {
+	start := time.Now()
+	defer func() { l.stats.record(start, err) }()
+
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal log entry: %w", err)
 	}
 	data = append(data, '\n')
+
+	l.writeMu.Lock()
 	_, err = l.writer.Write(data)
+	l.writeMu.Unlock()
 	if err != nil {
 		return fmt.Errorf("failed to write log: %w", err)
 	}
 	return nil
 }
 
-// Write 实现 Logger 接口
-func (l *FileLogger) Write(entry *LogEntry) error {
+// SetBackpressure 设置异步缓冲区满时的处理策略
+func (l *FileLogger) SetBackpressure(cfg BackpressureConfig) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.backpressure.cfg = cfg
+}
+
+// BackpressureStats 返回缓冲区满时各策略的丢弃计数
+func (l *FileLogger) BackpressureStats() BackpressureStats {
+	return l.backpressure.stats()
+}
+
+// Stats 返回运行统计
+func (l *FileLogger) Stats() LoggerStats {
+	stats := LoggerStats{
+		QueueDepth:    len(l.bufferCh),
+		QueueCapacity: cap(l.bufferCh),
+		Backpressure:  l.backpressure.stats(),
+	}
+	stats.Dropped = stats.Backpressure.Dropped()
+	l.stats.snapshot(&stats)
+	return stats
+}
+
+// Write 实现 Logger 接口
+func (l *FileLogger) Write(entry *LogEntry) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	if l.closed {
 		return fmt.Errorf("logger is closed")
 	}
 
 	if l.bufferCh != nil {
-		// 异步模式：发送到缓冲区
-		select {
-		case l.bufferCh <- entry:
-			return nil
-		default:
-			// 缓冲区满，丢弃日志或同步写入
-			fmt.Fprintf(os.Stderr, "log buffer full, dropping entry: %s %s\n", entry.Method, entry.Path)
-			return nil
-		}
+		// 异步模式：发送到缓冲区，缓冲区满时按背压策略处理
+		l.backpressure.offer(l.bufferCh, entry, nil)
+		return nil
 	}
 
 	// 同步模式
 	return l.writeEntry(entry)
 }
 
-// Close 实现 Logger 接口
+// Close 实现 Logger 接口，等待缓冲区中的日志全部写完
 func (l *FileLogger) Close() error {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	_, err := l.Shutdown(context.Background())
+	return err
+}
 
+// Shutdown 实现 Shutdowner 接口
+// 停止接收新日志，在 ctx 截止前写完缓冲区中的日志后关闭文件，返回未写入的条数
+func (l *FileLogger) Shutdown(ctx context.Context) (int, error) {
+	l.mu.Lock()
 	if l.closed {
-		return nil
+		l.mu.Unlock()
+		return 0, nil
 	}
 	l.closed = true
+	l.mu.Unlock()
 
-	// 关闭异步写入
+	var lost int
+	var deadlineErr error
 	if l.bufferCh != nil {
 		close(l.bufferCh)
-		l.wg.Wait()
+		if err := waitGroupContext(ctx, &l.wg); err != nil {
+			close(l.quit)
+			l.wg.Wait()
+			lost = len(l.bufferCh)
+			deadlineErr = shutdownError(lost, err)
+		}
 	}
 
 	// 刷新并关闭文件
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
 	if l.writer != nil {
 		l.writer.Flush()
 	}
+	var closeErr error
 	if l.file != nil {
-		return l.file.Close()
+		closeErr = l.file.Close()
 	}
-	return nil
+	if deadlineErr != nil {
+		return lost, deadlineErr
+	}
+	return 0, closeErr
 }
 
 // Flush 实现 Logger 接口
@@ -145,7 +199,10 @@ func (l *FileLogger) Flush() {
 func (l *FileLogger) flushBuffer() {
 	for {
 		select {
-		case entry := <-l.bufferCh:
+		case entry, ok := <-l.bufferCh:
+			if !ok {
+				return
+			}
 			if err := l.writeEntry(entry); err != nil {
 				fmt.Fprintf(os.Stderr, "failed to flush log: %v\n", err)
 			}
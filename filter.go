@@ -0,0 +1,478 @@
+package reqlogmid
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldOp 自定义字段谓词的比较方式
+type FieldOp string
+
+const (
+	// FieldEq 值（文本形式）相等
+	FieldEq FieldOp = "eq"
+	// FieldExists 字段存在（值为 null 也算存在），忽略 Value
+	FieldExists FieldOp = "exists"
+	// FieldIn 值（文本形式）等于 Values 中任一项
+	FieldIn FieldOp = "in"
+	// FieldGt 数值大于 Value，值不是 JSON 数字时不匹配
+	FieldGt FieldOp = "gt"
+	// FieldGte 数值大于等于 Value
+	FieldGte FieldOp = "gte"
+	// FieldLt 数值小于 Value
+	FieldLt FieldOp = "lt"
+	// FieldLte 数值小于等于 Value
+	FieldLte FieldOp = "lte"
+)
+
+// numericOps 数值比较运算符对应的 SQL 运算符
+var numericOps = map[FieldOp]string{FieldGt: ">", FieldGte: ">=", FieldLt: "<", FieldLte: "<="}
+
+// FieldPredicate 自定义字段（custom_fields）上的谓词
+type FieldPredicate struct {
+	Key    string   // 字段名，只允许字母、数字、下划线、连字符和点
+	Op     FieldOp  // 比较方式，默认 FieldEq
+	Value  string   // 比较值，数字和布尔值按 JSON 文本比较，如 42、true；数值比较时须为数字
+	Values []string // FieldIn 的候选值
+}
+
+// compare 按数值比较运算符比较 v 和 target
+func (p FieldPredicate) compare(v, target float64) bool {
+	switch p.Op {
+	case FieldGt:
+		return v > target
+	case FieldGte:
+		return v >= target
+	case FieldLt:
+		return v < target
+	default:
+		return v <= target
+	}
+}
+
+// SearchMode 自由文本检索方式
+type SearchMode string
+
+const (
+	// SearchSubstring 不区分大小写的子串匹配（默认），PostgreSQL 可由三元组索引加速
+	SearchSubstring SearchMode = "substring"
+	// SearchFullText 按词匹配的全文检索，PostgreSQL 使用 tsvector，SQLite 使用 FTS5，MySQL 不支持
+	SearchFullText SearchMode = "fulltext"
+)
+
+// LogFilter 日志查询条件，各条件之间为 AND 关系，零值表示不过滤
+// DBLogger 的列表和计数使用同一套 SQL 生成逻辑，MemoryLogger 使用等价的内存匹配
+type LogFilter struct {
+	Methods []string // 请求方法，任一匹配
+
+	PathPrefix   string // 路径前缀，不区分大小写
+	PathContains string // 路径包含，不区分大小写
+	PathRegex    string // 路径正则（SQLite 不支持）
+
+	Routes []string // 路由模板，任一匹配
+
+	StatusCodes   []int // 状态码，任一匹配
+	StatusClasses []int // 状态码类别，如 4 表示 4xx，任一匹配
+	MinStatus     int   // 最小状态码（含），0 表示不限
+	MaxStatus     int   // 最大状态码（含），0 表示不限
+
+	MinDuration float64 // 最小耗时（毫秒，含），0 表示不限
+	MaxDuration float64 // 最大耗时（毫秒，含），0 表示不限
+
+	ClientIPs  []string // 客户端 IP，任一匹配
+	ClientCIDR string   // 客户端网段，如 10.0.0.0/8
+
+	StartTime time.Time // 写入时间下限（含）
+	EndTime   time.Time // 写入时间上限（含）
+
+	Fields []FieldPredicate // 自定义字段谓词
+
+	// Query 自由文本，在路径、查询字符串、User-Agent、错误信息和自定义字段中检索
+	Query      string
+	SearchMode SearchMode // 检索方式，默认 SearchSubstring
+}
+
+var fieldKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// Validate 校验正则、网段、自定义字段名及其比较方式
+func (f LogFilter) Validate() error {
+	if f.PathRegex != "" {
+		if _, err := regexp.Compile(f.PathRegex); err != nil {
+			return fmt.Errorf("invalid path regex: %w", err)
+		}
+	}
+	if f.ClientCIDR != "" {
+		if _, _, err := net.ParseCIDR(f.ClientCIDR); err != nil {
+			return fmt.Errorf("invalid client cidr: %w", err)
+		}
+	}
+	switch f.SearchMode {
+	case "", SearchSubstring, SearchFullText:
+	default:
+		return fmt.Errorf("unsupported search mode %q", f.SearchMode)
+	}
+	for _, class := range f.StatusClasses {
+		if class < 1 || class > 5 {
+			return fmt.Errorf("invalid status class %d", class)
+		}
+	}
+	for _, p := range f.Fields {
+		if err := ValidateFieldKey(p.Key); err != nil {
+			return err
+		}
+		switch p.Op {
+		case "", FieldEq, FieldExists:
+		case FieldIn:
+			if len(p.Values) == 0 {
+				return fmt.Errorf("custom field %q: in requires at least one value", p.Key)
+			}
+		case FieldGt, FieldGte, FieldLt, FieldLte:
+			if _, err := strconv.ParseFloat(p.Value, 64); err != nil {
+				return fmt.Errorf("custom field %q: %q is not a number", p.Key, p.Value)
+			}
+		default:
+			return fmt.Errorf("unsupported custom field op %q", p.Op)
+		}
+	}
+	return nil
+}
+
+// filterTimeFormat 时间条件传给数据库的格式，与写入时的本地时间保持一致
+const filterTimeFormat = "2006-01-02 15:04:05.000"
+
+// whereClause 生成 WHERE 子句（含 WHERE 关键字，无条件时为空）和参数，占位符为 ?
+// table 为日志表名，全文检索需要据此定位检索表
+func (f LogFilter) whereClause(d Dialect, table string) (string, []interface{}, error) {
+	if err := f.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	var conds []string
+	var args []interface{}
+	add := func(cond string, a ...interface{}) {
+		conds = append(conds, cond)
+		args = append(args, a...)
+	}
+	in := func(column string, n int) string {
+		if n == 1 {
+			return column + " = ?"
+		}
+		return fmt.Sprintf("%s IN (%s)", column, placeholders(n))
+	}
+
+	if len(f.Methods) > 0 {
+		add(in("method", len(f.Methods)), stringArgs(f.Methods)...)
+	}
+	// 各数据库 LIKE 的大小写敏感性不同，统一转为小写比较，与 Matcher 一致
+	if f.PathPrefix != "" {
+		add(d.Like("LOWER(path)"), EscapeLike(strings.ToLower(f.PathPrefix))+"%")
+	}
+	if f.PathContains != "" {
+		add(d.Like("LOWER(path)"), "%"+EscapeLike(strings.ToLower(f.PathContains))+"%")
+	}
+	if f.PathRegex != "" {
+		cond := d.Regexp("path")
+		if cond == "" {
+			return "", nil, fmt.Errorf("path regex is not supported by %s", d.Name())
+		}
+		add(cond, f.PathRegex)
+	}
+	if len(f.Routes) > 0 {
+		add(in("route", len(f.Routes)), stringArgs(f.Routes)...)
+	}
+	if len(f.StatusCodes) > 0 {
+		a := make([]interface{}, len(f.StatusCodes))
+		for i, code := range f.StatusCodes {
+			a[i] = code
+		}
+		add(in("status_code", len(a)), a...)
+	}
+	if len(f.StatusClasses) > 0 {
+		parts := make([]string, len(f.StatusClasses))
+		var a []interface{}
+		for i, class := range f.StatusClasses {
+			parts[i] = "(status_code >= ? AND status_code < ?)"
+			a = append(a, class*100, class*100+100)
+		}
+		add("("+strings.Join(parts, " OR ")+")", a...)
+	}
+	if f.MinStatus > 0 {
+		add("status_code >= ?", f.MinStatus)
+	}
+	if f.MaxStatus > 0 {
+		add("status_code <= ?", f.MaxStatus)
+	}
+	if f.MinDuration > 0 {
+		add("duration_ms >= ?", f.MinDuration)
+	}
+	if f.MaxDuration > 0 {
+		add("duration_ms <= ?", f.MaxDuration)
+	}
+	if len(f.ClientIPs) > 0 {
+		add(in("client_ip", len(f.ClientIPs)), stringArgs(f.ClientIPs)...)
+	}
+	if f.ClientCIDR != "" {
+		if cond := d.InetContains("client_ip"); cond != "" {
+			add(cond, f.ClientCIDR)
+		} else {
+			prefixes, err := cidrPrefixes(f.ClientCIDR)
+			if err != nil {
+				return "", nil, err
+			}
+			parts := make([]string, len(prefixes))
+			a := make([]interface{}, len(prefixes))
+			for i, p := range prefixes {
+				// 以点结尾的是八位组前缀，否则为完整 IP
+				if p == "" || strings.HasSuffix(p, ".") {
+					parts[i] = d.Like("client_ip")
+					a[i] = EscapeLike(p) + "%"
+				} else {
+					parts[i] = "client_ip = ?"
+					a[i] = p
+				}
+			}
+			add("("+strings.Join(parts, " OR ")+")", a...)
+		}
+	}
+	if !f.StartTime.IsZero() {
+		add("created_at >= ?", f.StartTime.In(time.Local).Format(filterTimeFormat))
+	}
+	if !f.EndTime.IsZero() {
+		add("created_at <= ?", f.EndTime.In(time.Local).Format(filterTimeFormat))
+	}
+	for _, p := range f.Fields {
+		switch p.Op {
+		case FieldExists:
+			add(d.JSONHasKey("custom_fields", p.Key))
+		case FieldIn:
+			add(in(d.JSONText("custom_fields", p.Key), len(p.Values)), stringArgs(p.Values)...)
+		case FieldGt, FieldGte, FieldLt, FieldLte:
+			n, _ := strconv.ParseFloat(p.Value, 64)
+			add(d.JSONNumber("custom_fields", p.Key)+" "+numericOps[p.Op]+" ?", n)
+		default:
+			add(d.JSONText("custom_fields", p.Key)+" = ?", p.Value)
+		}
+	}
+	if f.Query != "" {
+		if f.SearchMode == SearchFullText {
+			cond, arg := d.FullTextMatch(table, f.Query)
+			if cond == "" {
+				return "", nil, fmt.Errorf("full-text search is not supported by %s", d.Name())
+			}
+			add(cond, arg)
+		} else {
+			add(d.Like("LOWER("+d.SearchDocument()+")"), "%"+EscapeLike(strings.ToLower(f.Query))+"%")
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// cidrPrefixes 将 IPv4 网段转换为文本前缀列表，用于不支持 inet 类型的数据库
+// 如 10.0.16.0/20 转换为 10.0.16. 到 10.0.31. 共 16 个前缀；
+// 不足一个八位组的部分落在最后一段时（/25 到 /32）返回完整 IP
+func cidrPrefixes(cidr string) ([]string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client cidr: %w", err)
+	}
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("ipv6 cidr is only supported on postgres")
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ones == 32 {
+		return []string{ip.String()}, nil
+	}
+	if ones == 0 {
+		return []string{""}, nil
+	}
+
+	// 完整的八位组作为固定前缀，剩余位在下一个八位组中展开
+	full := ones / 8
+	rest := ones % 8
+	prefix := ""
+	for i := 0; i < full; i++ {
+		prefix += fmt.Sprintf("%d.", ip[i])
+	}
+	if rest == 0 {
+		return []string{prefix}, nil
+	}
+
+	n := 1 << (8 - rest)
+	base := int(ip[full])
+	prefixes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		p := fmt.Sprintf("%s%d", prefix, base+i)
+		if full < 3 {
+			p += "."
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+// Matcher 返回与 SQL 条件等价的内存匹配函数，供不基于数据库的 LogStore 使用
+func (f LogFilter) Matcher() (func(*DBLogEntry) bool, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	var pathRegex *regexp.Regexp
+	if f.PathRegex != "" {
+		pathRegex = regexp.MustCompile(f.PathRegex)
+	}
+	var cidr *net.IPNet
+	if f.ClientCIDR != "" {
+		_, cidr, _ = net.ParseCIDR(f.ClientCIDR)
+	}
+	pathPrefix, pathContains := strings.ToLower(f.PathPrefix), strings.ToLower(f.PathContains)
+	// 全文检索在内存中近似为每个词都出现
+	var terms []string
+	if f.SearchMode == SearchFullText {
+		terms = strings.Fields(strings.ToLower(f.Query))
+	} else if f.Query != "" {
+		terms = []string{strings.ToLower(f.Query)}
+	}
+
+	return func(e *DBLogEntry) bool {
+		if len(f.Methods) > 0 && !containsString(f.Methods, e.Method) {
+			return false
+		}
+		if pathPrefix != "" && !strings.HasPrefix(strings.ToLower(e.Path), pathPrefix) {
+			return false
+		}
+		if pathContains != "" && !strings.Contains(strings.ToLower(e.Path), pathContains) {
+			return false
+		}
+		if pathRegex != nil && !pathRegex.MatchString(e.Path) {
+			return false
+		}
+		if len(f.Routes) > 0 && !containsString(f.Routes, e.Route) {
+			return false
+		}
+		if len(f.StatusCodes) > 0 && !containsInt(f.StatusCodes, e.StatusCode) {
+			return false
+		}
+		if len(f.StatusClasses) > 0 && !containsInt(f.StatusClasses, e.StatusCode/100) {
+			return false
+		}
+		if f.MinStatus > 0 && e.StatusCode < f.MinStatus {
+			return false
+		}
+		if f.MaxStatus > 0 && e.StatusCode > f.MaxStatus {
+			return false
+		}
+		if f.MinDuration > 0 && e.Duration < f.MinDuration {
+			return false
+		}
+		if f.MaxDuration > 0 && e.Duration > f.MaxDuration {
+			return false
+		}
+		if len(f.ClientIPs) > 0 && !containsString(f.ClientIPs, e.ClientIP) {
+			return false
+		}
+		if cidr != nil {
+			ip := net.ParseIP(e.ClientIP)
+			if ip == nil || !cidr.Contains(ip) {
+				return false
+			}
+		}
+		if !f.StartTime.IsZero() && e.CreatedAt.Before(f.StartTime) {
+			return false
+		}
+		if !f.EndTime.IsZero() && e.CreatedAt.After(f.EndTime) {
+			return false
+		}
+		if len(f.Fields) > 0 {
+			fields := map[string]json.RawMessage{}
+			json.Unmarshal([]byte(e.CustomFields), &fields)
+			for _, p := range f.Fields {
+				if !matchField(p, fields) {
+					return false
+				}
+			}
+		}
+		if len(terms) > 0 {
+			// 没有自定义字段的日志在数据库中为 NULL，不参与检索
+			customFields := e.CustomFields
+			if customFields == "null" {
+				customFields = ""
+			}
+			doc := strings.ToLower(strings.Join([]string{e.Path, e.Query, e.UserAgent, e.Error, customFields}, " "))
+			for _, t := range terms {
+				if !strings.Contains(doc, t) {
+					return false
+				}
+			}
+		}
+		return true
+	}, nil
+}
+
+// matchField 在已解析的自定义字段上判断谓词，与 whereClause 生成的 SQL 等价
+func matchField(p FieldPredicate, fields map[string]json.RawMessage) bool {
+	v, ok := fields[p.Key]
+	if !ok {
+		return false
+	}
+	switch p.Op {
+	case FieldExists:
+		return true
+	case FieldIn:
+		return containsString(p.Values, jsonText(v))
+	case FieldGt, FieldGte, FieldLt, FieldLte:
+		// 只有 JSON 数字参与比较，字符串形式的数字不匹配
+		var n float64
+		if err := json.Unmarshal(v, &n); err != nil {
+			return false
+		}
+		target, _ := strconv.ParseFloat(p.Value, 64)
+		return p.compare(n, target)
+	default:
+		return jsonText(v) == p.Value
+	}
+}
+
+// jsonText 返回 JSON 值的文本形式：字符串去掉引号，其余保持 JSON 文本
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, n := range values {
+		if n == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package reqlogmid
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestFilterPathCaseMatchesMemory SQL 条件与内存匹配对路径大小写的处理一致
+func TestFilterPathCaseMatchesMemory(t *testing.T) {
+	db := newTestDBLogger(t)
+	mem := NewMemoryLogger(10)
+	for _, path := range []string{"/API/Users", "/api/users/1", "/Health", "/static/App.js"} {
+		entry := &LogEntry{Method: "GET", Path: path, ClientIP: "127.0.0.1", StatusCode: 200}
+		if err := db.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   int64
+	}{
+		{"prefix lower", LogFilter{PathPrefix: "/api/"}, 2},
+		{"prefix upper", LogFilter{PathPrefix: "/API/USERS"}, 2},
+		{"contains mixed", LogFilter{PathContains: "aPp"}, 1},
+		{"contains no match", LogFilter{PathContains: "orders"}, 0},
+		{"like wildcard escaped", LogFilter{PathContains: "_"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbCount, err := db.CountLogs(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			memCount, err := mem.CountLogs(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dbCount != tt.want || memCount != tt.want {
+				t.Errorf("sqlite %d, memory %d, want %d", dbCount, memCount, tt.want)
+			}
+		})
+	}
+}
+
+func TestInetPattern(t *testing.T) {
+	re := regexp.MustCompile(inetPattern)
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"255.255.255.255", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::", true},
+		{"2001:db8::8a2e:370:7334", true},
+		{"fe80::", true},
+		{"1:2:3:4:5:6:7:8", true},
+		{"", false},
+		{"unknown", false},
+		{"256.1.1.1", false},
+		{"1.2.3", false},
+		{"01.2.3.4", false},
+		{"10.0.0.1, 10.0.0.2", false},
+		{"1:2:3:4:5:6:7:8:9", false},
+		{"1::2::3", false},
+		{":::", false},
+		{"12345::", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		got := re.MatchString(tt.in)
+		if got != tt.want {
+			t.Errorf("inetPattern matches %q = %v, want %v", tt.in, got, tt.want)
+		}
+		// 正则放行的值必须能被解析为 IP，否则 PostgreSQL 的 ::inet 转换会报错
+		if got && net.ParseIP(tt.in) == nil {
+			t.Errorf("inetPattern accepts %q which is not an IP", tt.in)
+		}
+	}
+}
+
+func TestPostgresClientCIDRIsGuarded(t *testing.T) {
+	where, args, err := LogFilter{ClientCIDR: "10.0.0.0/8"}.whereClause(PostgresDialect{}, "request_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := " WHERE CASE WHEN client_ip ~ '" + inetPattern + "' THEN client_ip::inet <<= ?::inet ELSE FALSE END"
+	if where != want || len(args) != 1 || args[0] != "10.0.0.0/8" {
+		t.Errorf("whereClause = %q %v, want %q", where, args, want)
+	}
+	if got := Rebind(PostgresDialect{}, where); got != strings.Replace(want, "?::inet", "$1::inet", 1) {
+		t.Errorf("Rebind = %q", got)
+	}
+}
@@ -0,0 +1,332 @@
+package reqlogmid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPFormat 批量请求体格式
+type HTTPFormat string
+
+const (
+	// HTTPFormatNDJSON 每行一条 JSON（默认）
+	HTTPFormatNDJSON HTTPFormat = "ndjson"
+	// HTTPFormatJSONArray 整批为一个 JSON 数组
+	HTTPFormatJSONArray HTTPFormat = "json"
+)
+
+// HTTPConfig HTTP 批量输出配置
+type HTTPConfig struct {
+	URL     string            // 接收地址，必填
+	Format  HTTPFormat        // 请求体格式，默认 NDJSON
+	Gzip    bool              // 是否 gzip 压缩请求体
+	Headers map[string]string // 自定义请求头
+	// HMACSecret 不为空时对请求体（压缩后）做 HMAC-SHA256 签名
+	HMACSecret string
+	// HMACHeader 签名请求头，默认 X-Signature，值为 sha256=<hex>
+	HMACHeader string
+	Timeout    time.Duration // 单次请求超时，默认 10s
+	Retry      *RetryConfig  // 重试配置，默认 DefaultRetryConfig
+	Batch      BatchConfig   // 攒批配置
+	Client     *http.Client  // 自定义 HTTP 客户端，默认 http.DefaultClient 的超时副本
+}
+
+// DeliveryStats HTTP 投递统计
+type DeliveryStats struct {
+	BatchStats
+	Requests   uint64 `json:"requests"`    // 发出的请求数（含重试）
+	Retries    uint64 `json:"retries"`     // 重试次数
+	LastStatus int    `json:"last_status"` // 最近一次响应状态码，0 表示网络错误
+}
+
+// HTTPStatusError 非 2xx 响应
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// httpSender 发送 HTTP 请求，处理压缩、签名、超时和重试，供基于 HTTP 的输出器复用
+type httpSender struct {
+	client     *http.Client
+	headers    map[string]string
+	gzip       bool
+	hmacSecret []byte
+	hmacHeader string
+	timeout    time.Duration
+	retry      RetryConfig
+
+	requests   uint64
+	retries    uint64
+	lastStatus int64
+}
+
+// newHTTPSender 创建 HTTP 发送器，零值字段使用默认值
+func newHTTPSender(client *http.Client, timeout time.Duration, retry *RetryConfig) *httpSender {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	s := &httpSender{
+		client:     client,
+		timeout:    timeout,
+		retry:      DefaultRetryConfig(),
+		hmacHeader: "X-Signature",
+	}
+	if retry != nil {
+		s.retry = *retry
+	}
+	return s
+}
+
+// post 发送 POST 请求，见 request
+func (s *httpSender) post(ctx context.Context, url, contentType string, payload []byte) ([]byte, error) {
+	return s.request(ctx, http.MethodPost, url, contentType, payload)
+}
+
+// request 发送请求，网络错误、429 和 5xx 按退避重试，429/503 优先使用 Retry-After（不超过 MaxBackoff）
+// 返回最后一次响应的响应体
+func (s *httpSender) request(ctx context.Context, method, url, contentType string, payload []byte) ([]byte, error) {
+	body := payload
+	if s.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+
+	var signature string
+	if len(s.hmacSecret) > 0 {
+		mac := hmac.New(sha256.New, s.hmacSecret)
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	maxAttempts := s.retry.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		respBody, retryAfter, err := s.do(ctx, method, url, contentType, body, signature)
+		if err == nil {
+			return respBody, nil
+		}
+		if !retryableHTTPError(err) || attempt >= maxAttempts || ctx.Err() != nil {
+			return respBody, err
+		}
+
+		wait := s.retry.Backoff(attempt)
+		if retryAfter > 0 {
+			// 服务端给出的等待时间可能很长，限制在 MaxBackoff 内，避免阻塞发送协程和关闭流程
+			wait = retryAfter
+			if s.retry.MaxBackoff > 0 {
+				wait = min(retryAfter, s.retry.MaxBackoff)
+			}
+		}
+		atomic.AddUint64(&s.retries, 1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return respBody, fmt.Errorf("%v; %w", err, ctx.Err())
+		}
+	}
+}
+
+// do 发送一次请求
+func (s *httpSender) do(ctx context.Context, method, url, contentType string, body []byte, signature string) ([]byte, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if signature != "" {
+		req.Header.Set(s.hmacHeader, signature)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	atomic.AddUint64(&s.requests, 1)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		atomic.StoreInt64(&s.lastStatus, 0)
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	atomic.StoreInt64(&s.lastStatus, int64(resp.StatusCode))
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, 0, nil
+	}
+
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	snippet := string(respBody)
+	if len(snippet) > 256 {
+		snippet = snippet[:256]
+	}
+	return respBody, retryAfter, &HTTPStatusError{StatusCode: resp.StatusCode, Body: snippet}
+}
+
+// deliveryStats 返回请求统计
+func (s *httpSender) deliveryStats() DeliveryStats {
+	return DeliveryStats{
+		Requests:   atomic.LoadUint64(&s.requests),
+		Retries:    atomic.LoadUint64(&s.retries),
+		LastStatus: int(atomic.LoadInt64(&s.lastStatus)),
+	}
+}
+
+// retryableHTTPError 网络错误、429 和 5xx 可重试，其余状态码不重试
+func retryableHTTPError(err error) bool {
+	if se, ok := err.(*HTTPStatusError); ok {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// HTTPLogger 将日志攒批后以 NDJSON 或 JSON 数组 POST 到指定地址
+type HTTPLogger struct {
+	cfg     HTTPConfig
+	sender  *httpSender
+	batcher *batcher
+}
+
+// NewHTTPLogger 创建 HTTP 批量日志输出器
+func NewHTTPLogger(cfg HTTPConfig) (*HTTPLogger, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("http logger: url is required")
+	}
+	if cfg.Format == "" {
+		cfg.Format = HTTPFormatNDJSON
+	}
+	if cfg.Format != HTTPFormatNDJSON && cfg.Format != HTTPFormatJSONArray {
+		return nil, fmt.Errorf("http logger: unsupported format %q", cfg.Format)
+	}
+
+	sender := newHTTPSender(cfg.Client, cfg.Timeout, cfg.Retry)
+	sender.headers = cfg.Headers
+	sender.gzip = cfg.Gzip
+	if cfg.HMACSecret != "" {
+		sender.hmacSecret = []byte(cfg.HMACSecret)
+	}
+	if cfg.HMACHeader != "" {
+		sender.hmacHeader = cfg.HMACHeader
+	}
+
+	l := &HTTPLogger{cfg: cfg, sender: sender}
+	l.batcher = newBatcher("http logger", cfg.Batch, l.send)
+	return l, nil
+}
+
+// send 编码并发送一批日志
+func (l *HTTPLogger) send(ctx context.Context, entries []*LogEntry) error {
+	payload, contentType, err := l.encode(entries)
+	if err != nil {
+		return err
+	}
+	_, err = l.sender.post(ctx, l.cfg.URL, contentType, payload)
+	return err
+}
+
+// encode 按配置格式编码
+func (l *HTTPLogger) encode(entries []*LogEntry) ([]byte, string, error) {
+	if l.cfg.Format == HTTPFormatJSONArray {
+		data, err := json.Marshal(entries)
+		return data, "application/json", err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return nil, "", err
+		}
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
+
+// Write 实现 Logger 接口
+func (l *HTTPLogger) Write(entry *LogEntry) error {
+	return l.batcher.write(entry)
+}
+
+// Flush 实现 Logger 接口，立即发送所有待发送日志
+func (l *HTTPLogger) Flush() {
+	l.batcher.flush()
+}
+
+// Close 实现 Logger 接口
+func (l *HTTPLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口
+func (l *HTTPLogger) Shutdown(ctx context.Context) (int, error) {
+	return l.batcher.shutdown(ctx)
+}
+
+// Stats 返回运行统计，写入耗时为单批请求耗时（含重试）
+func (l *HTTPLogger) Stats() LoggerStats {
+	return l.batcher.loggerStats()
+}
+
+// DeliveryStats 返回投递统计
+func (l *HTTPLogger) DeliveryStats() DeliveryStats {
+	stats := l.sender.deliveryStats()
+	stats.BatchStats = l.batcher.batchStats()
+	return stats
+}
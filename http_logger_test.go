@@ -0,0 +1,154 @@
+package reqlogmid
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPLoggerSignsGzipNDJSON(t *testing.T) {
+	const secret = "s3cret"
+	received := make(chan []LogEntry, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get("X-Sig") != want {
+			t.Errorf("signature = %q, want %q", r.Header.Get("X-Sig"), want)
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("Content-Type") != "application/x-ndjson" || r.Header.Get("X-Tenant") != "acme" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Errorf("gzip: %v", err)
+			return
+		}
+		var entries []LogEntry
+		sc := bufio.NewScanner(zr)
+		for sc.Scan() {
+			var e LogEntry
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				t.Errorf("line %q: %v", sc.Text(), err)
+			}
+			entries = append(entries, e)
+		}
+		received <- entries
+	}))
+	defer srv.Close()
+
+	l, err := NewHTTPLogger(HTTPConfig{
+		URL:        srv.URL,
+		Gzip:       true,
+		HMACSecret: secret,
+		HMACHeader: "X-Sig",
+		Headers:    map[string]string{"X-Tenant": "acme"},
+		Batch:      BatchConfig{BatchSize: 10, FlushInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, path := range []string{"/a", "/b"} {
+		if err := l.Write(&LogEntry{Method: "GET", Path: path, StatusCode: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Flush()
+
+	select {
+	case entries := <-received:
+		if len(entries) != 2 || entries[0].Path != "/a" || entries[1].Path != "/b" {
+			t.Errorf("received %+v", entries)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no request received")
+	}
+}
+
+func TestHTTPSenderRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int
+		retryAfter   string
+		wantErr      bool
+		wantRequests int64
+	}{
+		{"success", []int{200}, "", false, 1},
+		{"5xx retried", []int{502, 500, 200}, "", false, 3},
+		{"gives up after max attempts", []int{500, 500, 500, 200}, "", true, 3},
+		{"4xx not retried", []int{400, 200}, "", true, 1},
+		{"429 retried", []int{429, 200}, "0", false, 2},
+		{"long Retry-After clamped to MaxBackoff", []int{429, 503, 200}, "3600", false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt64(&n, 1) - 1
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.responses[i])
+			}))
+			defer srv.Close()
+
+			s := newHTTPSender(nil, time.Second, &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond, Multiplier: 2})
+			start := time.Now()
+			_, err := s.post(context.Background(), srv.URL, "application/json", []byte("{}"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("post error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("post took %v", elapsed)
+			}
+			stats := s.deliveryStats()
+			if int64(stats.Requests) != tt.wantRequests || atomic.LoadInt64(&n) != tt.wantRequests {
+				t.Errorf("requests = %d (server saw %d), want %d", stats.Requests, atomic.LoadInt64(&n), tt.wantRequests)
+			}
+			if stats.Retries != uint64(tt.wantRequests-1) {
+				t.Errorf("retries = %d, want %d", stats.Retries, tt.wantRequests-1)
+			}
+			var se *HTTPStatusError
+			if tt.wantErr && !errors.As(err, &se) {
+				t.Errorf("error %v is not an HTTPStatusError", err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(date in 1h) = %v", got)
+	}
+}
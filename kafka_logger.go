@@ -0,0 +1,247 @@
+package reqlogmid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// KafkaKeyBy 消息键的来源，决定分区
+type KafkaKeyBy string
+
+const (
+	// KafkaKeyNone 不设置键，由生产者轮询分区（默认）
+	KafkaKeyNone KafkaKeyBy = ""
+	// KafkaKeyRequestID 按请求 ID（自定义字段）分区
+	KafkaKeyRequestID KafkaKeyBy = "request_id"
+	// KafkaKeyClientIP 按客户端 IP 分区，同一客户端的请求保持有序
+	KafkaKeyClientIP KafkaKeyBy = "client_ip"
+	// KafkaKeyRoute 按路由模板分区，未匹配路由时使用请求路径
+	KafkaKeyRoute KafkaKeyBy = "route"
+)
+
+// KafkaCompression 消息压缩算法
+type KafkaCompression string
+
+const (
+	KafkaCompressionNone   KafkaCompression = "none"
+	KafkaCompressionGzip   KafkaCompression = "gzip"
+	KafkaCompressionSnappy KafkaCompression = "snappy"
+	KafkaCompressionLZ4    KafkaCompression = "lz4"
+	KafkaCompressionZstd   KafkaCompression = "zstd"
+)
+
+// KafkaAcks 生产者确认级别
+type KafkaAcks string
+
+const (
+	KafkaAcksNone   KafkaAcks = "none"   // 不等待确认（acks=0）
+	KafkaAcksLeader KafkaAcks = "leader" // leader 写入即确认（acks=1）
+	KafkaAcksAll    KafkaAcks = "all"    // 所有 ISR 写入后确认（acks=-1）
+)
+
+// KafkaMessage 待发送的消息
+type KafkaMessage struct {
+	Topic   string
+	Key     []byte // 为 nil 时由生产者选择分区
+	Value   []byte // LogEntry 的 JSON
+	Headers map[string]string
+	Time    time.Time
+}
+
+// KafkaProducer Kafka 生产者，由调用方基于具体客户端（如 sarama、franz-go、kafka-go）实现
+type KafkaProducer interface {
+	// Produce 同步发送一批消息，返回时消息已按 acks 级别确认
+	Produce(ctx context.Context, msgs []KafkaMessage) error
+	// Close 关闭生产者
+	Close() error
+}
+
+// KafkaProducerSettings 创建生产者时传入的设置
+type KafkaProducerSettings struct {
+	Brokers     []string
+	ClientID    string
+	Compression KafkaCompression
+	Acks        KafkaAcks
+}
+
+// KafkaConfig Kafka 输出配置
+type KafkaConfig struct {
+	Brokers     []string         // broker 地址
+	Topic       string           // 主题，必填
+	ClientID    string           // 客户端 ID，默认 req-log-mid
+	KeyBy       KafkaKeyBy       // 消息键来源
+	Compression KafkaCompression // 压缩算法，默认 snappy
+	Acks        KafkaAcks        // 确认级别，默认 leader
+	// RequestIDField 请求 ID 所在的自定义字段，默认 request_id
+	RequestIDField string
+	// KeyFunc 自定义消息键，设置后忽略 KeyBy
+	KeyFunc func(entry *LogEntry) []byte
+	// Headers 附加到每条消息的头
+	Headers map[string]string
+	Batch   BatchConfig // 攒批配置
+	// Retry 发送失败时的重试配置，默认 DefaultRetryConfig
+	// 默认只重试 IsKafkaRetriable 判定的暂时性错误，可通过 Retryable 接入客户端自己的判定（如 franz-go 的 kerr.IsRetriable）
+	Retry *RetryConfig
+	// NewProducer 根据设置创建生产者，必填
+	NewProducer func(settings KafkaProducerSettings) (KafkaProducer, error)
+}
+
+// KafkaLogger 将日志攒批后发送到 Kafka
+type KafkaLogger struct {
+	cfg      KafkaConfig
+	producer KafkaProducer
+	retry    RetryConfig
+	batcher  *batcher
+	closer   sync.Once
+}
+
+// IsKafkaRetriable 判断生产者错误是否为暂时性错误：IsTransientError，
+// 或实现了 Temporary() bool / Timeout() bool 且返回 true（如 kafka-go 的 Error）
+func IsKafkaRetriable(err error) bool {
+	if IsTransientError(err) {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// NewKafkaLogger 创建 Kafka 日志输出器
+func NewKafkaLogger(cfg KafkaConfig) (*KafkaLogger, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("kafka logger: topic is required")
+	}
+	if cfg.NewProducer == nil {
+		return nil, fmt.Errorf("kafka logger: NewProducer is required")
+	}
+	switch cfg.KeyBy {
+	case KafkaKeyNone, KafkaKeyRequestID, KafkaKeyClientIP, KafkaKeyRoute:
+	default:
+		return nil, fmt.Errorf("kafka logger: unsupported key %q", cfg.KeyBy)
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "req-log-mid"
+	}
+	if cfg.Compression == "" {
+		cfg.Compression = KafkaCompressionSnappy
+	}
+	if cfg.Acks == "" {
+		cfg.Acks = KafkaAcksLeader
+	}
+	if cfg.RequestIDField == "" {
+		cfg.RequestIDField = "request_id"
+	}
+
+	producer, err := cfg.NewProducer(KafkaProducerSettings{
+		Brokers:     cfg.Brokers,
+		ClientID:    cfg.ClientID,
+		Compression: cfg.Compression,
+		Acks:        cfg.Acks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kafka logger: failed to create producer: %w", err)
+	}
+
+	retry := DefaultRetryConfig()
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
+	}
+	if retry.Retryable == nil {
+		retry.Retryable = IsKafkaRetriable
+	}
+
+	l := &KafkaLogger{cfg: cfg, producer: producer, retry: retry}
+	l.batcher = newBatcher("kafka logger", cfg.Batch, l.send)
+	return l, nil
+}
+
+// Key 返回日志对应的消息键
+func (l *KafkaLogger) Key(entry *LogEntry) []byte {
+	if l.cfg.KeyFunc != nil {
+		return l.cfg.KeyFunc(entry)
+	}
+
+	var key string
+	switch l.cfg.KeyBy {
+	case KafkaKeyRequestID:
+		if v, ok := entry.CustomFields[l.cfg.RequestIDField]; ok {
+			key = fmt.Sprintf("%v", v)
+		}
+	case KafkaKeyClientIP:
+		key = entry.ClientIP
+	case KafkaKeyRoute:
+		key = entry.Route
+		if key == "" {
+			key = entry.Path
+		}
+	}
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// send 编码并发送一批日志，暂时性错误按重试配置退避重发整批
+func (l *KafkaLogger) send(ctx context.Context, entries []*LogEntry) error {
+	msgs := make([]KafkaMessage, 0, len(entries))
+	for _, entry := range entries {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, KafkaMessage{
+			Topic:   l.cfg.Topic,
+			Key:     l.Key(entry),
+			Value:   value,
+			Headers: l.cfg.Headers,
+			Time:    entryTime(entry, ""),
+		})
+	}
+	return l.retry.DoContext(ctx, func() error {
+		return l.producer.Produce(ctx, msgs)
+	})
+}
+
+// Write 实现 Logger 接口
+func (l *KafkaLogger) Write(entry *LogEntry) error {
+	return l.batcher.write(entry)
+}
+
+// Flush 实现 Logger 接口，立即发送所有待发送日志
+func (l *KafkaLogger) Flush() {
+	l.batcher.flush()
+}
+
+// Close 实现 Logger 接口
+func (l *KafkaLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口，发送完剩余日志后关闭生产者
+func (l *KafkaLogger) Shutdown(ctx context.Context) (int, error) {
+	lost, err := l.batcher.shutdown(ctx)
+	l.closer.Do(func() {
+		if cerr := l.producer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	})
+	return lost, err
+}
+
+// Stats 返回运行统计，写入耗时为单批发送耗时
+func (l *KafkaLogger) Stats() LoggerStats {
+	return l.batcher.loggerStats()
+}
+
+// BatchStats 返回批次统计
+func (l *KafkaLogger) BatchStats() BatchStats {
+	return l.batcher.batchStats()
+}
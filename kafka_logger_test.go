@@ -0,0 +1,145 @@
+package reqlogmid
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockProducer 记录每次 Produce 调用，按顺序返回 errs 中的错误，用完后返回 nil
+type mockProducer struct {
+	mu       sync.Mutex
+	errs     []error
+	calls    int
+	produced [][]KafkaMessage
+	settings KafkaProducerSettings
+	closed   bool
+}
+
+func (p *mockProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	p.produced = append(p.produced, msgs)
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// temporaryError 模拟客户端的暂时性错误（如 kafka-go 的 Error）
+type temporaryError struct{ temporary bool }
+
+func (e temporaryError) Error() string   { return "kafka error" }
+func (e temporaryError) Temporary() bool { return e.temporary }
+
+func newTestKafkaLogger(t *testing.T, p *mockProducer, cfg KafkaConfig) *KafkaLogger {
+	t.Helper()
+	cfg.Topic = "requests"
+	cfg.NewProducer = func(s KafkaProducerSettings) (KafkaProducer, error) {
+		p.settings = s
+		return p, nil
+	}
+	if cfg.Retry == nil {
+		cfg.Retry = &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	}
+	l, err := NewKafkaLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestKafkaLoggerRetries(t *testing.T) {
+	errPermanent := errors.New("message too large")
+	tests := []struct {
+		name      string
+		errs      []error
+		retryable func(error) bool
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", nil, nil, nil, 1},
+		{"temporary errors retried", []error{temporaryError{true}, context.DeadlineExceeded}, nil, nil, 3},
+		{"gives up after max attempts", []error{temporaryError{true}, temporaryError{true}, temporaryError{true}}, nil, temporaryError{true}, 3},
+		{"permanent error not retried", []error{errPermanent}, nil, errPermanent, 1},
+		{"non-temporary error not retried", []error{temporaryError{false}}, nil, temporaryError{false}, 1},
+		{"custom classification", []error{errPermanent}, func(err error) bool { return err == errPermanent }, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockProducer{errs: tt.errs}
+			l := newTestKafkaLogger(t, p, KafkaConfig{Retry: &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Retryable: tt.retryable}})
+
+			err := l.send(context.Background(), []*LogEntry{{Method: "GET", Path: "/"}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("send = %v, want %v", err, tt.wantErr)
+			}
+			if p.calls != tt.wantCalls {
+				t.Errorf("Produce called %d times, want %d", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestKafkaLoggerRetryStopsOnContextCancel(t *testing.T) {
+	p := &mockProducer{errs: []error{temporaryError{true}, temporaryError{true}}}
+	l := newTestKafkaLogger(t, p, KafkaConfig{Retry: &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Hour}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.send(ctx, []*LogEntry{{Method: "GET", Path: "/"}}); err == nil {
+		t.Fatal("send succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second || p.calls != 1 {
+		t.Errorf("send took %v with %d calls, want prompt return after 1 call", elapsed, p.calls)
+	}
+}
+
+func TestKafkaLoggerMessages(t *testing.T) {
+	p := &mockProducer{}
+	l := newTestKafkaLogger(t, p, KafkaConfig{KeyBy: KafkaKeyRoute, Headers: map[string]string{"source": "test"}})
+	if p.settings.ClientID != "req-log-mid" || p.settings.Compression != KafkaCompressionSnappy || p.settings.Acks != KafkaAcksLeader {
+		t.Errorf("producer settings = %+v", p.settings)
+	}
+
+	// 自定义 TimeFormat 的 Timestamp 无法解析，消息时间取中间件记录的请求时间
+	requestTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, e := range []LogEntry{
+		{Method: "GET", Path: "/users/1", Route: "/users/:id", Timestamp: "02/01/2026 03:04:05", RequestTime: requestTime},
+		{Method: "GET", Path: "/nope"},
+	} {
+		if err := l.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Flush()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.produced) != 1 || len(p.produced[0]) != 2 {
+		t.Fatalf("produced %v", p.produced)
+	}
+	msgs := p.produced[0]
+	if msgs[0].Topic != "requests" || string(msgs[0].Key) != "/users/:id" || string(msgs[1].Key) != "/nope" || msgs[0].Headers["source"] != "test" {
+		t.Errorf("messages = %+v", msgs)
+	}
+	if !msgs[0].Time.Equal(requestTime) {
+		t.Errorf("message time = %v, want %v", msgs[0].Time, requestTime)
+	}
+}
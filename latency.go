@@ -0,0 +1,213 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LatencyPercentiles 响应耗时分位数（毫秒）
+type LatencyPercentiles struct {
+	Count       int64   `json:"count"`
+	Avg         float64 `json:"avg"`
+	P50         float64 `json:"p50"`
+	P90         float64 `json:"p90"`
+	P95         float64 `json:"p95"`
+	P99         float64 `json:"p99"`
+	Max         float64 `json:"max"`
+	Approximate bool    `json:"approximate"` // 为 true 时分位数由细粒度直方图插值估算
+}
+
+// approxPercentileBuckets 估算分位数使用的分桶：0.1ms 到约 2 分钟按 1.2 倍递增，相对误差约 10%
+var approxPercentileBuckets = func() []float64 {
+	var b []float64
+	for v := 0.1; v < 120000; v *= 1.2 {
+		b = append(b, math.Round(v*1000)/1000)
+	}
+	return b
+}()
+
+// normalizeBuckets 复制并升序排列分桶上界，为空时使用 DefaultLatencyBuckets
+func normalizeBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return b
+}
+
+// percentileCont 对已排序的数据按线性插值计算分位数，与 PostgreSQL percentile_cont 一致
+func percentileCont(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// percentileFromHistogram 由累计直方图在桶内线性插值估算分位数，落在最后一个桶之外时返回 maxValue
+func percentileFromHistogram(snap HistogramSnapshot, q, maxValue float64) float64 {
+	if snap.Count == 0 {
+		return 0
+	}
+	rank := q * float64(snap.Count)
+	var prevCount uint64
+	prevBound := 0.0
+	for i, upper := range snap.Buckets {
+		if float64(snap.Counts[i]) >= rank && snap.Counts[i] > prevCount {
+			inBucket := float64(snap.Counts[i] - prevCount)
+			v := prevBound + (upper-prevBound)*(rank-float64(prevCount))/inBucket
+			return math.Min(v, maxValue)
+		}
+		prevCount = snap.Counts[i]
+		prevBound = upper
+	}
+	return maxValue
+}
+
+// exactLatencyPercentiles 由全部耗时计算精确分位数，durations 会被排序
+func exactLatencyPercentiles(durations []float64) LatencyPercentiles {
+	p := LatencyPercentiles{Count: int64(len(durations))}
+	if len(durations) == 0 {
+		return p
+	}
+	sort.Float64s(durations)
+	var sum float64
+	for _, d := range durations {
+		sum += d
+	}
+	p.Avg = sum / float64(len(durations))
+	p.P50 = percentileCont(durations, 0.5)
+	p.P90 = percentileCont(durations, 0.9)
+	p.P95 = percentileCont(durations, 0.95)
+	p.P99 = percentileCont(durations, 0.99)
+	p.Max = durations[len(durations)-1]
+	return p
+}
+
+// LatencyPercentiles 实现 LatencyStatsProvider 接口
+// 方言支持 percentile_cont 时精确计算，否则由细粒度直方图估算；可使用汇总表时由汇总的耗时分布估算
+func (l *DBLogger) LatencyPercentiles(filter LogFilter) (LatencyPercentiles, error) {
+	rows, ok, err := l.rollupRows(filter, true)
+	if err != nil {
+		return LatencyPercentiles{}, err
+	}
+	if ok {
+		total := rollupRow{sketch: newLatencySketch()}
+		for i := range rows {
+			total.merge(&rows[i])
+		}
+		return total.latency(), nil
+	}
+
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return LatencyPercentiles{}, err
+	}
+
+	var p LatencyPercentiles
+	var maxDuration sql.NullFloat64
+	if l.dialect.Percentile("duration_ms", 0.5) == "" {
+		query := fmt.Sprintf("SELECT COUNT(*), COALESCE(AVG(duration_ms), 0), MAX(duration_ms) FROM %s%s", l.tableName, where)
+		if err := l.db.QueryRow(Rebind(l.dialect, query), args...).Scan(&p.Count, &p.Avg, &maxDuration); err != nil {
+			return p, err
+		}
+		p.Max = maxDuration.Float64
+		if p.Count == 0 {
+			return p, nil
+		}
+		snap, err := l.LatencyHistogram(filter, approxPercentileBuckets)
+		if err != nil {
+			return p, err
+		}
+		p.P50 = percentileFromHistogram(snap, 0.5, p.Max)
+		p.P90 = percentileFromHistogram(snap, 0.9, p.Max)
+		p.P95 = percentileFromHistogram(snap, 0.95, p.Max)
+		p.P99 = percentileFromHistogram(snap, 0.99, p.Max)
+		p.Approximate = true
+		return p, nil
+	}
+
+	var p50, p90, p95, p99 sql.NullFloat64
+	query := fmt.Sprintf("SELECT COUNT(*), COALESCE(AVG(duration_ms), 0), %s, %s, %s, %s, MAX(duration_ms) FROM %s%s",
+		l.dialect.Percentile("duration_ms", 0.5), l.dialect.Percentile("duration_ms", 0.9),
+		l.dialect.Percentile("duration_ms", 0.95), l.dialect.Percentile("duration_ms", 0.99),
+		l.tableName, where)
+	err = l.db.QueryRow(Rebind(l.dialect, query), args...).Scan(&p.Count, &p.Avg, &p50, &p90, &p95, &p99, &maxDuration)
+	p.P50, p.P90, p.P95, p.P99, p.Max = p50.Float64, p90.Float64, p95.Float64, p99.Float64, maxDuration.Float64
+	return p, err
+}
+
+// LatencyHistogram 实现 LatencyStatsProvider 接口，Counts 为累计计数
+func (l *DBLogger) LatencyHistogram(filter LogFilter, buckets []float64) (HistogramSnapshot, error) {
+	buckets = normalizeBuckets(buckets)
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return HistogramSnapshot{}, err
+	}
+
+	// 分桶上界作为参数放在 WHERE 参数之前
+	cols := make([]string, len(buckets))
+	bucketArgs := make([]interface{}, len(buckets))
+	for i, b := range buckets {
+		cols[i] = "COALESCE(SUM(CASE WHEN duration_ms <= ? THEN 1 ELSE 0 END), 0)"
+		bucketArgs[i] = b
+	}
+	query := fmt.Sprintf("SELECT %s, COUNT(*), COALESCE(SUM(duration_ms), 0) FROM %s%s",
+		strings.Join(cols, ", "), l.tableName, where)
+
+	snap := HistogramSnapshot{Buckets: buckets, Counts: make([]uint64, len(buckets))}
+	dest := make([]interface{}, 0, len(buckets)+2)
+	for i := range snap.Counts {
+		dest = append(dest, &snap.Counts[i])
+	}
+	dest = append(dest, &snap.Count, &snap.Sum)
+	err = l.db.QueryRow(Rebind(l.dialect, query), append(bucketArgs, args...)...).Scan(dest...)
+	return snap, err
+}
+
+// LatencyPercentiles 实现 LatencyStatsProvider 接口（精确计算）
+func (l *MemoryLogger) LatencyPercentiles(filter LogFilter) (LatencyPercentiles, error) {
+	durations, err := l.durations(filter)
+	if err != nil {
+		return LatencyPercentiles{}, err
+	}
+	return exactLatencyPercentiles(durations), nil
+}
+
+// LatencyHistogram 实现 LatencyStatsProvider 接口，Counts 为累计计数
+func (l *MemoryLogger) LatencyHistogram(filter LogFilter, buckets []float64) (HistogramSnapshot, error) {
+	durations, err := l.durations(filter)
+	if err != nil {
+		return HistogramSnapshot{}, err
+	}
+	h := NewHistogram(buckets)
+	for _, d := range durations {
+		h.Observe(d)
+	}
+	return h.Snapshot(), nil
+}
+
+// durations 返回满足条件的日志耗时
+func (l *MemoryLogger) durations(filter LogFilter) ([]float64, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var durations []float64
+	for i := 0; i < l.count; i++ {
+		if e := l.at(i); match(e) {
+			durations = append(durations, e.Duration)
+		}
+	}
+	return durations, nil
+}
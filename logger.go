@@ -9,12 +9,19 @@ import (
 type LogEntry struct {
 	Method       string                 `json:"method"`
 	Path         string                 `json:"path"`
+	Route        string                 `json:"route,omitempty"`
+	Query        string                 `json:"query,omitempty"` // 原始查询字符串（不含 ?）
 	ClientIP     string                 `json:"client_ip"`
 	UserAgent    string                 `json:"user_agent"`
 	StatusCode   int                    `json:"status_code"`
 	Duration     float64                `json:"duration_ms"`
 	Timestamp    string                 `json:"timestamp"`
+	Error        string                 `json:"error,omitempty"` // 处理过程中通过 c.Error 记录的错误信息
 	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+
+	// RequestTime 请求完成时间，数据库输出以此作为 created_at；零值时使用写入时间
+	// 不参与 JSON 输出，磁盘暂存会单独保存，回放的日志仍落在原来的时间上
+	RequestTime time.Time `json:"-"`
 }
 
 // Logger 接口定义了日志输出的抽象
@@ -28,6 +35,69 @@ type Logger interface {
 	Flush()
 }
 
+// LogStore 日志读取侧接口，提供查询、计数、统计和清理
+// 管理后台通过此接口访问日志，DBLogger 和 MemoryLogger 均已实现；
+// 也可基于文件归档、只读副本等自行实现
+type LogStore interface {
+	// QueryLogs 按条件分页查询日志，按时间倒序
+	QueryLogs(offset, limit int, filter LogFilter) ([]DBLogEntry, error)
+	// QueryLogsByCursor 按条件做键集分页查询，cursor 为 nil 时返回第一页
+	QueryLogsByCursor(cursor *LogCursor, limit int, filter LogFilter) (*LogPage, error)
+	// CountLogs 按与 QueryLogs 相同的条件统计数量
+	CountLogs(filter LogFilter) (int64, error)
+	// GetLogByID 获取单条日志，不存在时返回 nil, nil
+	GetLogByID(id int64) (*DBLogEntry, error)
+	// GetStats 返回今日请求数、总请求数、平均响应时间（毫秒）、错误率（百分比）
+	GetStats() (todayLogs int64, totalLogs int64, avgDuration float64, errorRate float64, err error)
+	// DeleteOldLogs 删除指定天数之前的日志，返回删除条数
+	DeleteOldLogs(days int) (int64, error)
+}
+
+// HealthReporter 可报告写入链路健康状态的输出器
+type HealthReporter interface {
+	Health() LoggerHealth
+}
+
+// FieldKeyLister 可列出最近日志中出现过的自定义字段的存储，供管理界面构建筛选项
+type FieldKeyLister interface {
+	FieldKeys(sample int) ([]FieldKey, error)
+}
+
+// LatencyStatsProvider 可按条件计算耗时分位数和直方图的存储
+type LatencyStatsProvider interface {
+	// LatencyPercentiles 计算满足条件的日志的耗时分位数
+	LatencyPercentiles(filter LogFilter) (LatencyPercentiles, error)
+	// LatencyHistogram 按 buckets（毫秒，为空时使用 DefaultLatencyBuckets）统计耗时分布
+	LatencyHistogram(filter LogFilter, buckets []float64) (HistogramSnapshot, error)
+}
+
+// TimeSeriesProvider 可按时间分桶聚合日志的存储
+type TimeSeriesProvider interface {
+	// TimeSeries 按 interval 在 loc 时区下分桶聚合满足条件的日志，按时间升序
+	// filter 同时指定 StartTime 和 EndTime 时补齐无请求的分桶
+	TimeSeries(filter LogFilter, interval SeriesInterval, loc *time.Location) ([]TimeSeriesPoint, error)
+}
+
+// TopProvider 可按维度分组排行的存储
+type TopProvider interface {
+	Top(q TopQuery) ([]TopItem, error)
+}
+
+var (
+	_ LogStore       = (*DBLogger)(nil)
+	_ LogStore       = (*MemoryLogger)(nil)
+	_ HealthReporter = (*DBLogger)(nil)
+	_ FieldKeyLister = (*DBLogger)(nil)
+	_ FieldKeyLister = (*MemoryLogger)(nil)
+
+	_ LatencyStatsProvider = (*DBLogger)(nil)
+	_ LatencyStatsProvider = (*MemoryLogger)(nil)
+	_ TimeSeriesProvider   = (*DBLogger)(nil)
+	_ TimeSeriesProvider   = (*MemoryLogger)(nil)
+	_ TopProvider          = (*DBLogger)(nil)
+	_ TopProvider          = (*MemoryLogger)(nil)
+)
+
 // MarshalJSON 实现 json.Marshaler 接口
 func (l *LogEntry) MarshalJSON() ([]byte, error) {
 	type Alias LogEntry
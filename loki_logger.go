@@ -0,0 +1,217 @@
+package reqlogmid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loki 可用的动态标签字段
+const (
+	LokiLabelMethod      = "method"       // 请求方法
+	LokiLabelRoute       = "route"        // 路由模板（未匹配路由时不设置）
+	LokiLabelStatusClass = "status_class" // 状态码类别，如 2xx、5xx
+	LokiLabelStatus      = "status"       // 具体状态码
+)
+
+// LokiConfig Loki 输出配置
+type LokiConfig struct {
+	URL      string            // push 地址，如 http://loki:3100/loki/api/v1/push，必填
+	TenantID string            // 多租户 ID，设置 X-Scope-OrgID 请求头
+	Service  string            // service 标签值，为空不设置
+	Labels   map[string]string // 静态标签
+	// LabelFields 从日志提取的动态标签，默认 method、status_class
+	// 标签决定流的数量，不要使用 path、client_ip 等高基数字段
+	LabelFields []string
+	Headers     map[string]string // 自定义请求头，如 Authorization
+	Gzip        bool              // 是否 gzip 压缩请求体
+	Timeout     time.Duration     // 单次请求超时，默认 10s
+	Retry       *RetryConfig      // 重试配置，默认 DefaultRetryConfig，429 优先使用 Retry-After
+	Batch       BatchConfig       // 攒批配置
+	Client      *http.Client      // 自定义 HTTP 客户端
+}
+
+// LokiLogger 将日志按标签分流后推送到 Loki
+// 使用 push API 的 JSON 格式，日志行为 LogEntry 的 JSON；暂不支持 snappy protobuf 格式
+type LokiLogger struct {
+	cfg     LokiConfig
+	sender  *httpSender
+	batcher *batcher
+}
+
+// NewLokiLogger 创建 Loki 日志输出器
+func NewLokiLogger(cfg LokiConfig) (*LokiLogger, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("loki logger: url is required")
+	}
+	if len(cfg.LabelFields) == 0 {
+		cfg.LabelFields = []string{LokiLabelMethod, LokiLabelStatusClass}
+	}
+	for _, f := range cfg.LabelFields {
+		switch f {
+		case LokiLabelMethod, LokiLabelRoute, LokiLabelStatusClass, LokiLabelStatus:
+		default:
+			return nil, fmt.Errorf("loki logger: unsupported label field %q", f)
+		}
+	}
+
+	sender := newHTTPSender(cfg.Client, cfg.Timeout, cfg.Retry)
+	sender.gzip = cfg.Gzip
+	sender.headers = make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		sender.headers[k] = v
+	}
+	if cfg.TenantID != "" {
+		sender.headers["X-Scope-OrgID"] = cfg.TenantID
+	}
+
+	l := &LokiLogger{cfg: cfg, sender: sender}
+	l.batcher = newBatcher("loki logger", cfg.Batch, l.send)
+	return l, nil
+}
+
+// lokiStream push 请求中的一个流
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiPush push 请求体
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+// Labels 返回日志对应的流标签
+func (l *LokiLogger) Labels(entry *LogEntry) map[string]string {
+	labels := make(map[string]string, len(l.cfg.Labels)+len(l.cfg.LabelFields)+1)
+	for k, v := range l.cfg.Labels {
+		labels[k] = v
+	}
+	if l.cfg.Service != "" {
+		labels["service"] = l.cfg.Service
+	}
+	for _, f := range l.cfg.LabelFields {
+		var v string
+		switch f {
+		case LokiLabelMethod:
+			v = entry.Method
+		case LokiLabelRoute:
+			v = entry.Route
+		case LokiLabelStatusClass:
+			v = strconv.Itoa(entry.StatusCode/100) + "xx"
+		case LokiLabelStatus:
+			v = strconv.Itoa(entry.StatusCode)
+		}
+		if v != "" {
+			labels[f] = v
+		}
+	}
+	return labels
+}
+
+// encode 将一批日志按标签分组编码为 push 请求体
+func (l *LokiLogger) encode(entries []*LogEntry) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, entry := range entries {
+		labels := l.Labels(entry)
+		key := lokiStreamKey(labels)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		ts := strconv.FormatInt(entryTime(entry, "").UnixNano(), 10)
+		s.Values = append(s.Values, [2]string{ts, string(line)})
+	}
+
+	push := lokiPush{Streams: make([]*lokiStream, 0, len(order))}
+	for _, key := range order {
+		s := streams[key]
+		// 同一流内按时间排序，避免乱序被拒绝
+		sort.SliceStable(s.Values, func(i, j int) bool {
+			return len(s.Values[i][0]) < len(s.Values[j][0]) ||
+				len(s.Values[i][0]) == len(s.Values[j][0]) && s.Values[i][0] < s.Values[j][0]
+		})
+		push.Streams = append(push.Streams, s)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(push); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lokiStreamKey 按键排序生成标签集合的唯一键
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[k]))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+// send 发送一批日志
+func (l *LokiLogger) send(ctx context.Context, entries []*LogEntry) error {
+	payload, err := l.encode(entries)
+	if err != nil {
+		return err
+	}
+	_, err = l.sender.post(ctx, l.cfg.URL, "application/json", payload)
+	return err
+}
+
+// Write 实现 Logger 接口
+func (l *LokiLogger) Write(entry *LogEntry) error {
+	return l.batcher.write(entry)
+}
+
+// Flush 实现 Logger 接口，立即推送所有待发送日志
+func (l *LokiLogger) Flush() {
+	l.batcher.flush()
+}
+
+// Close 实现 Logger 接口
+func (l *LokiLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口
+func (l *LokiLogger) Shutdown(ctx context.Context) (int, error) {
+	return l.batcher.shutdown(ctx)
+}
+
+// Stats 返回运行统计，写入耗时为单批推送耗时（含重试）
+func (l *LokiLogger) Stats() LoggerStats {
+	return l.batcher.loggerStats()
+}
+
+// DeliveryStats 返回投递统计
+func (l *LokiLogger) DeliveryStats() DeliveryStats {
+	stats := l.sender.deliveryStats()
+	stats.BatchStats = l.batcher.batchStats()
+	return stats
+}
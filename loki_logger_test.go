@@ -0,0 +1,136 @@
+package reqlogmid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lokiStandIn 模拟 Loki push 接口，记录收到的请求，前 throttle 次返回 429
+type lokiStandIn struct {
+	mu       sync.Mutex
+	throttle int
+	tenants  []string
+	pushes   []lokiPush
+}
+
+func (s *lokiStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if s.throttle > 0 {
+		s.throttle--
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+	var push lokiPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.tenants = append(s.tenants, r.Header.Get("X-Scope-OrgID"))
+	s.pushes = append(s.pushes, push)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestLokiLoggerPush(t *testing.T) {
+	loki := &lokiStandIn{throttle: 1}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	l, err := NewLokiLogger(LokiConfig{
+		URL:      srv.URL + "/loki/api/v1/push",
+		TenantID: "team-a",
+		Service:  "api",
+		Labels:   map[string]string{"env": "test"},
+		Retry:    &RetryConfig{MaxAttempts: 2, MaxBackoff: 10 * time.Millisecond},
+		Batch:    BatchConfig{BatchSize: 10, FlushInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []LogEntry{
+		{Method: "GET", Path: "/b", StatusCode: 200, Timestamp: base.Add(2 * time.Second).Format(DefaultTimeFormat)},
+		{Method: "GET", Path: "/a", StatusCode: 201, Timestamp: base.Format(DefaultTimeFormat)},
+		{Method: "POST", Path: "/c", StatusCode: 500, Timestamp: base.Add(time.Second).Format(DefaultTimeFormat)},
+	}
+	for i := range entries {
+		if err := l.Write(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Flush()
+
+	loki.mu.Lock()
+	defer loki.mu.Unlock()
+	if len(loki.pushes) != 1 || !reflect.DeepEqual(loki.tenants, []string{"team-a"}) {
+		t.Fatalf("got %d pushes from tenants %v, want 1 from team-a", len(loki.pushes), loki.tenants)
+	}
+	if stats := l.DeliveryStats(); stats.Requests != 2 || stats.Retries != 1 || stats.Batches != 1 {
+		t.Errorf("delivery stats = %+v", stats)
+	}
+
+	streams := loki.pushes[0].Streams
+	if len(streams) != 2 {
+		t.Fatalf("got %d streams, want 2", len(streams))
+	}
+	wantGet := map[string]string{"env": "test", "service": "api", "method": "GET", "status_class": "2xx"}
+	if !reflect.DeepEqual(streams[0].Stream, wantGet) {
+		t.Errorf("first stream labels = %v, want %v", streams[0].Stream, wantGet)
+	}
+	if streams[1].Stream["method"] != "POST" || streams[1].Stream["status_class"] != "5xx" {
+		t.Errorf("second stream labels = %v", streams[1].Stream)
+	}
+
+	// 同一流内按时间升序，日志行为 LogEntry 的 JSON
+	get := streams[0].Values
+	if len(get) != 2 || get[0][0] != "1767323045000000000" || get[1][0] != "1767323047000000000" {
+		t.Fatalf("GET stream values = %v", get)
+	}
+	var line LogEntry
+	if err := json.Unmarshal([]byte(get[0][1]), &line); err != nil || line.Path != "/a" {
+		t.Errorf("first line = %q (%v)", get[0][1], err)
+	}
+}
+
+func TestLokiLoggerRejectsUnknownLabel(t *testing.T) {
+	if _, err := NewLokiLogger(LokiConfig{URL: "http://loki", LabelFields: []string{"path"}}); err == nil {
+		t.Error("NewLokiLogger accepted high-cardinality label field")
+	}
+	if _, err := NewLokiLogger(LokiConfig{}); err == nil {
+		t.Error("NewLokiLogger accepted empty url")
+	}
+}
+
+func TestLokiEncodeUsesRequestTime(t *testing.T) {
+	l, err := NewLokiLogger(LokiConfig{URL: "http://loki", Batch: BatchConfig{FlushInterval: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// 自定义 TimeFormat 的 Timestamp 无法解析，时间戳取中间件记录的请求时间
+	requestTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	body, err := l.encode([]*LogEntry{{Method: "GET", Path: "/", StatusCode: 200, Timestamp: "02/01/2026 03:04:05", RequestTime: requestTime}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var push lokiPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		t.Fatal(err)
+	}
+	if len(push.Streams) != 1 || push.Streams[0].Values[0][0] != "1767323045000000000" {
+		t.Errorf("push = %s", body)
+	}
+}
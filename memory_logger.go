@@ -0,0 +1,264 @@
+package reqlogmid
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryLogger 在内存环形缓冲区中保留最近 N 条日志，无需任何存储
+// 查询接口与 DBLogger 一致，可直接作为管理后台的数据源，适合小型服务和测试
+type MemoryLogger struct {
+	mu     sync.RWMutex
+	buf    []DBLogEntry
+	start  int // 最旧一条的位置
+	count  int
+	nextID int64
+	closed bool
+	stats  writeStats
+}
+
+// NewMemoryLogger 创建内存日志输出器，capacity 为保留条数，默认 1000
+func NewMemoryLogger(capacity int) *MemoryLogger {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryLogger{
+		buf:   make([]DBLogEntry, capacity),
+		stats: writeStats{latency: NewHistogram(nil)},
+	}
+}
+
+// Write 实现 Logger 接口，缓冲区满时覆盖最旧的日志
+func (l *MemoryLogger) Write(entry *LogEntry) error {
+	start := time.Now()
+	customFields, _ := json.Marshal(entry.CustomFields)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("logger is closed")
+	}
+
+	l.nextID++
+	record := DBLogEntry{
+		ID:           l.nextID,
+		Method:       entry.Method,
+		Path:         entry.Path,
+		Route:        entry.Route,
+		Query:        entry.Query,
+		ClientIP:     entry.ClientIP,
+		UserAgent:    entry.UserAgent,
+		StatusCode:   entry.StatusCode,
+		Duration:     entry.Duration,
+		Timestamp:    entry.Timestamp,
+		Error:        entry.Error,
+		CustomFields: string(customFields),
+		CreatedAt:    start,
+	}
+
+	if l.count < len(l.buf) {
+		l.buf[(l.start+l.count)%len(l.buf)] = record
+		l.count++
+	} else {
+		l.buf[l.start] = record
+		l.start = (l.start + 1) % len(l.buf)
+	}
+	l.stats.record(start, nil)
+	return nil
+}
+
+// at 返回第 i 新的日志（0 为最新），调用方需持有锁
+func (l *MemoryLogger) at(i int) *DBLogEntry {
+	return &l.buf[(l.start+l.count-1-i)%len(l.buf)]
+}
+
+// Len 返回当前保留的日志条数
+func (l *MemoryLogger) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.count
+}
+
+// QueryLogs 按条件分页查询日志，按时间倒序
+func (l *MemoryLogger) QueryLogs(offset, limit int, filter LogFilter) ([]DBLogEntry, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var entries []DBLogEntry
+	skipped := 0
+	for i := 0; i < l.count && len(entries) < limit; i++ {
+		e := l.at(i)
+		if !match(e) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		entries = append(entries, *e)
+	}
+	return entries, nil
+}
+
+// QueryLogsByCursor 按条件做键集分页查询，cursor 为 nil 时返回第一页（最新）
+func (l *MemoryLogger) QueryLogsByCursor(cursor *LogCursor, limit int, filter LogFilter) (*LogPage, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// 与 DBLogger 一致：按翻页方向遍历，多取一条判断是否还有更多
+	var entries []DBLogEntry
+	for i := 0; i < l.count && len(entries) <= limit; i++ {
+		idx := i
+		if cursor != nil && cursor.Backward {
+			idx = l.count - 1 - i
+		}
+		e := l.at(idx)
+		if cursor != nil {
+			if cursor.Backward && !cursor.newerThan(e) {
+				continue
+			}
+			if !cursor.Backward && !cursor.olderThan(e) {
+				continue
+			}
+		}
+		if match(e) {
+			entries = append(entries, *e)
+		}
+	}
+	return buildLogPage(entries, limit, cursor), nil
+}
+
+// CountLogs 按与 QueryLogs 相同的条件统计日志数量
+func (l *MemoryLogger) CountLogs(filter LogFilter) (int64, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return 0, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var count int64
+	for i := 0; i < l.count; i++ {
+		if match(l.at(i)) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// FieldKeys 在最近 sample 条日志中发现自定义字段，sample <= 0 时使用 DefaultFieldKeySample
+func (l *MemoryLogger) FieldKeys(sample int) ([]FieldKey, error) {
+	if sample <= 0 {
+		sample = DefaultFieldKeySample
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	counter := newFieldKeyCounter()
+	for i := 0; i < l.count && i < sample; i++ {
+		counter.add(l.at(i).CustomFields)
+	}
+	return counter.result(nil), nil
+}
+
+// GetLogByID 根据ID获取单条日志，不存在（或已被覆盖）时返回 nil
+func (l *MemoryLogger) GetLogByID(id int64) (*DBLogEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.count == 0 {
+		return nil, nil
+	}
+	// ID 连续递增，可直接定位
+	newest := l.at(0).ID
+	i := int(newest - id)
+	if i < 0 || i >= l.count {
+		return nil, nil
+	}
+	entry := *l.at(i)
+	if entry.ID != id {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// DeleteOldLogs 删除指定天数之前的日志
+func (l *MemoryLogger) DeleteOldLogs(days int) (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 日志按写入时间有序，从最旧的一端删除
+	var deleted int64
+	for l.count > 0 && l.buf[l.start].CreatedAt.Before(cutoff) {
+		l.buf[l.start] = DBLogEntry{}
+		l.start = (l.start + 1) % len(l.buf)
+		l.count--
+		deleted++
+	}
+	return deleted, nil
+}
+
+// GetStats 获取统计数据：今日请求数、总请求数、平均响应时间（毫秒）、错误率（百分比）
+func (l *MemoryLogger) GetStats() (int64, int64, float64, float64, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var todayCount, errCount int64
+	var totalDuration float64
+	for i := 0; i < l.count; i++ {
+		e := l.at(i)
+		if !e.CreatedAt.Before(today) {
+			todayCount++
+		}
+		if e.StatusCode >= 400 {
+			errCount++
+		}
+		totalDuration += e.Duration
+	}
+
+	total := int64(l.count)
+	if total == 0 {
+		return todayCount, 0, 0, 0, nil
+	}
+	return todayCount, total, totalDuration / float64(total), 100 * float64(errCount) / float64(total), nil
+}
+
+// Stats 返回运行统计，QueueDepth/QueueCapacity 为当前保留条数和容量
+func (l *MemoryLogger) Stats() LoggerStats {
+	l.mu.RLock()
+	stats := LoggerStats{QueueDepth: l.count, QueueCapacity: len(l.buf)}
+	l.mu.RUnlock()
+	l.stats.snapshot(&stats)
+	return stats
+}
+
+// Flush 实现 Logger 接口（同步写入，无需刷新）
+func (l *MemoryLogger) Flush() {}
+
+// Close 实现 Logger 接口，关闭后仍可查询
+func (l *MemoryLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
@@ -0,0 +1,167 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// parityEntries 同时写入 MemoryLogger 和 SQLite DBLogger 的日志，覆盖空、null 和各类型的自定义字段
+var parityEntries = []LogEntry{
+	{Method: "GET", Path: "/api/users", Route: "/api/users", ClientIP: "10.0.0.1", UserAgent: "curl/8.0", StatusCode: 200, Duration: 12,
+		CustomFields: map[string]interface{}{"tenant": "acme", "user_id": 42, "beta": true}},
+	{Method: "GET", Path: "/API/Users/1", Route: "/api/users/:id", ClientIP: "10.0.0.2", StatusCode: 404, Duration: 3},
+	{Method: "POST", Path: "/api/orders", Route: "/api/orders", Query: "a=1", ClientIP: "192.168.1.5", StatusCode: 201, Duration: 40,
+		CustomFields: map[string]interface{}{"tenant": "globex", "note": nil}},
+	{Method: "DELETE", Path: "/admin/x", ClientIP: "10.0.1.9", StatusCode: 500, Duration: 900, Error: "boom timeout",
+		CustomFields: map[string]interface{}{}},
+	{Method: "GET", Path: "/health", Route: "/health", ClientIP: "127.0.0.1", StatusCode: 200, Duration: 0.5,
+		CustomFields: map[string]interface{}{"user_id": "42"}},
+}
+
+func TestMemoryLoggerMatchesDBLogger(t *testing.T) {
+	mem := NewMemoryLogger(100)
+	db := newTestDBLogger(t)
+	start := time.Now().Add(-time.Second)
+	for i := range parityEntries {
+		e := parityEntries[i]
+		if err := mem.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+		e = parityEntries[i]
+		if err := db.Write(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 没有自定义字段的日志在数据库中为 SQL NULL，两者读出的文本都为 "null"
+	var nulls int
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM request_logs WHERE custom_fields IS NULL").Scan(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if nulls != 1 {
+		t.Errorf("%d rows with NULL custom_fields, want 1", nulls)
+	}
+
+	filters := []struct {
+		name   string
+		filter LogFilter
+	}{
+		{"all", LogFilter{}},
+		{"method", LogFilter{Methods: []string{"GET"}}},
+		{"path prefix case", LogFilter{PathPrefix: "/api/users"}},
+		{"path contains case", LogFilter{PathContains: "USERS"}},
+		{"route", LogFilter{Routes: []string{"/api/users/:id", "/health"}}},
+		{"status codes", LogFilter{StatusCodes: []int{200, 500}}},
+		{"status class", LogFilter{StatusClasses: []int{4, 5}}},
+		{"status range", LogFilter{MinStatus: 200, MaxStatus: 299}},
+		{"duration", LogFilter{MinDuration: 3, MaxDuration: 40}},
+		{"client ip", LogFilter{ClientIPs: []string{"10.0.0.1", "127.0.0.1"}}},
+		{"time range", LogFilter{StartTime: start, EndTime: time.Now().Add(time.Second)}},
+		{"time after", LogFilter{StartTime: time.Now().Add(time.Minute)}},
+		{"field exists", LogFilter{Fields: []FieldPredicate{{Key: "tenant", Op: FieldExists}}}},
+		{"field exists json null", LogFilter{Fields: []FieldPredicate{{Key: "note", Op: FieldExists}}}},
+		{"field equals", LogFilter{Fields: []FieldPredicate{{Key: "tenant", Value: "acme"}}}},
+		{"field equals number and string", LogFilter{Fields: []FieldPredicate{{Key: "user_id", Value: "42"}}}},
+		{"field equals bool", LogFilter{Fields: []FieldPredicate{{Key: "beta", Value: "true"}}}},
+		{"field equals null", LogFilter{Fields: []FieldPredicate{{Key: "note", Value: "null"}}}},
+		{"field in", LogFilter{Fields: []FieldPredicate{{Key: "tenant", Op: FieldIn, Values: []string{"acme", "globex"}}}}},
+		{"field numeric", LogFilter{Fields: []FieldPredicate{{Key: "user_id", Op: FieldGt, Value: "10"}}}},
+		{"field missing", LogFilter{Fields: []FieldPredicate{{Key: "absent", Op: FieldExists}}}},
+		{"search error", LogFilter{Query: "BOOM"}},
+		{"search field value", LogFilter{Query: "acme"}},
+		{"search query string", LogFilter{Query: "a=1"}},
+		{"search null", LogFilter{Query: "null"}},
+		{"search braces", LogFilter{Query: "{}"}},
+	}
+	for _, tt := range filters {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := db.QueryLogs(0, 100, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := mem.QueryLogs(0, 100, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPaths, wantPaths := logPaths(got), logPaths(want); gotPaths != wantPaths {
+				t.Errorf("memory = %s, sqlite = %s", gotPaths, wantPaths)
+			}
+			for i := range got {
+				if i < len(want) && got[i].CustomFields != want[i].CustomFields {
+					t.Errorf("%s custom_fields: memory = %q, sqlite = %q", got[i].Path, got[i].CustomFields, want[i].CustomFields)
+				}
+			}
+
+			memCount, _ := mem.CountLogs(tt.filter)
+			dbCount, err := db.CountLogs(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if memCount != dbCount {
+				t.Errorf("count: memory = %d, sqlite = %d", memCount, dbCount)
+			}
+		})
+	}
+}
+
+// logPaths 按顺序列出日志路径，便于比较结果集
+func logPaths(logs []DBLogEntry) string {
+	paths := make([]string, len(logs))
+	for i, l := range logs {
+		paths[i] = l.Path
+	}
+	return fmt.Sprint(paths)
+}
+
+func TestMemoryLoggerRingBuffer(t *testing.T) {
+	l := NewMemoryLogger(3)
+	for i := 1; i <= 5; i++ {
+		if err := l.Write(&LogEntry{Method: "GET", Path: fmt.Sprintf("/%d", i), StatusCode: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, err := l.QueryLogs(0, 10, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logPaths(logs); got != "[/5 /4 /3]" {
+		t.Errorf("retained %s, want [/5 /4 /3]", got)
+	}
+
+	// 被覆盖的日志按 ID 查不到，保留的日志可以
+	tests := []struct {
+		id   int64
+		want string
+	}{
+		{1, ""},
+		{2, ""},
+		{3, "/3"},
+		{5, "/5"},
+		{6, ""},
+	}
+	for _, tt := range tests {
+		entry, err := l.GetLogByID(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if entry != nil {
+			got = entry.Path
+		}
+		if got != tt.want {
+			t.Errorf("GetLogByID(%d) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(&LogEntry{Path: "/6"}); err == nil {
+		t.Error("write after close succeeded")
+	}
+	if n := l.Len(); n != 3 {
+		t.Errorf("Len after close = %d, want 3", n)
+	}
+}
@@ -0,0 +1,273 @@
+package reqlogmid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RequestMetrics 中间件请求指标：按方法、路由、状态码计数，按方法、路由统计耗时直方图
+type RequestMetrics struct {
+	mu        sync.RWMutex
+	buckets   []float64
+	counts    map[requestKey]uint64
+	durations map[routeKey]*Histogram
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	status int
+}
+
+// NewRequestMetrics 创建请求指标收集器，buckets 为耗时分桶上界（毫秒），为空时使用 DefaultLatencyBuckets
+func NewRequestMetrics(buckets []float64) *RequestMetrics {
+	return &RequestMetrics{
+		buckets:   buckets,
+		counts:    make(map[requestKey]uint64),
+		durations: make(map[routeKey]*Histogram),
+	}
+}
+
+// Observe 记录一次请求
+// route 为路由模板（如 /users/:id），避免按实际路径产生过多标签
+func (m *RequestMetrics) Observe(method, route string, status int, durationMs float64) {
+	if route == "" {
+		route = "unmatched"
+	}
+	rk := routeKey{method: method, route: route}
+
+	m.mu.Lock()
+	m.counts[requestKey{routeKey: rk, status: status}]++
+	h, ok := m.durations[rk]
+	if !ok {
+		h = NewHistogram(m.buckets)
+		m.durations[rk] = h
+	}
+	m.mu.Unlock()
+
+	h.Observe(durationMs)
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出请求指标
+func (m *RequestMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.RLock()
+	counts := make([]requestKey, 0, len(m.counts))
+	for k := range m.counts {
+		counts = append(counts, k)
+	}
+	values := make(map[requestKey]uint64, len(m.counts))
+	for k, v := range m.counts {
+		values[k] = v
+	}
+	routes := make([]routeKey, 0, len(m.durations))
+	histograms := make(map[routeKey]HistogramSnapshot, len(m.durations))
+	for k, h := range m.durations {
+		routes = append(routes, k)
+		histograms[k] = h.Snapshot()
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].routeKey != counts[j].routeKey {
+			return counts[i].routeKey.less(counts[j].routeKey)
+		}
+		return counts[i].status < counts[j].status
+	})
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+
+	pw := NewPrometheusWriter(w)
+	pw.Header("reqlog_http_requests_total", "counter", "Total number of HTTP requests handled by the middleware.")
+	for _, k := range counts {
+		pw.Sample("reqlog_http_requests_total", float64(values[k]),
+			"method", k.method, "route", k.route, "status", strconv.Itoa(k.status))
+	}
+	pw.Header("reqlog_http_request_duration_seconds", "histogram", "HTTP request latency observed by the middleware.")
+	for _, k := range routes {
+		pw.Histogram("reqlog_http_request_duration_seconds", histograms[k], 0.001,
+			"method", k.method, "route", k.route)
+	}
+	return pw.Flush()
+}
+
+func (k routeKey) less(o routeKey) bool {
+	if k.route != o.route {
+		return k.route < o.route
+	}
+	return k.method < o.method
+}
+
+// WriteLoggerPrometheus 以 Prometheus 文本格式输出日志输出器统计
+// loggers 的键作为 logger 标签值区分不同输出器
+func WriteLoggerPrometheus(w io.Writer, loggers map[string]LoggerStats) error {
+	names := make([]string, 0, len(loggers))
+	for name := range loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pw := NewPrometheusWriter(w)
+	series := func(metric, typ, help string, value func(LoggerStats) float64) {
+		pw.Header(metric, typ, help)
+		for _, name := range names {
+			pw.Sample(metric, value(loggers[name]), "logger", name)
+		}
+	}
+	series("reqlog_logger_queue_depth", "gauge", "Number of log entries waiting in the async buffer.",
+		func(s LoggerStats) float64 { return float64(s.QueueDepth) })
+	series("reqlog_logger_queue_capacity", "gauge", "Capacity of the async buffer.",
+		func(s LoggerStats) float64 { return float64(s.QueueCapacity) })
+	series("reqlog_logger_entries_written_total", "counter", "Log entries written successfully.",
+		func(s LoggerStats) float64 { return float64(s.Written) })
+	series("reqlog_logger_entries_failed_total", "counter", "Log entries that failed to be written.",
+		func(s LoggerStats) float64 { return float64(s.Failed) })
+	series("reqlog_logger_entries_spilled_total", "counter", "Log entries spilled to the fallback logger.",
+		func(s LoggerStats) float64 { return float64(s.Backpressure.Spilled) })
+
+	pw.Header("reqlog_logger_entries_dropped_total", "counter", "Log entries dropped because the async buffer was full.")
+	for _, name := range names {
+		bp := loggers[name].Backpressure
+		pw.Sample("reqlog_logger_entries_dropped_total", float64(bp.DroppedNewest), "logger", name, "reason", "drop_newest")
+		pw.Sample("reqlog_logger_entries_dropped_total", float64(bp.DroppedOldest), "logger", name, "reason", "drop_oldest")
+		pw.Sample("reqlog_logger_entries_dropped_total", float64(bp.TimedOut), "logger", name, "reason", "timeout")
+		pw.Sample("reqlog_logger_entries_dropped_total", float64(bp.SpillFailed), "logger", name, "reason", "spill_failed")
+	}
+
+	pw.Header("reqlog_logger_write_duration_seconds", "histogram", "Time spent writing a single log entry.")
+	for _, name := range names {
+		pw.Histogram("reqlog_logger_write_duration_seconds", loggers[name].WriteLatency, 0.001, "logger", name)
+	}
+
+	// 磁盘暂存和熔断器只输出启用了的输出器
+	var spooled, breakers []string
+	for _, name := range names {
+		if loggers[name].Spool != nil {
+			spooled = append(spooled, name)
+		}
+		if loggers[name].Circuit != nil {
+			breakers = append(breakers, name)
+		}
+	}
+	if len(spooled) > 0 {
+		pw.Header("reqlog_logger_spool_pending", "gauge", "Log entries in the disk spool waiting to be replayed.")
+		for _, name := range spooled {
+			pw.Sample("reqlog_logger_spool_pending", float64(loggers[name].Spool.Pending), "logger", name)
+		}
+		pw.Header("reqlog_logger_spool_bytes", "gauge", "Bytes used by the disk spool.")
+		for _, name := range spooled {
+			pw.Sample("reqlog_logger_spool_bytes", float64(loggers[name].Spool.Bytes), "logger", name)
+		}
+		pw.Header("reqlog_logger_spool_entries_total", "counter", "Log entries spooled to disk, replayed from disk, or discarded by the spool.")
+		for _, name := range spooled {
+			sp := loggers[name].Spool
+			pw.Sample("reqlog_logger_spool_entries_total", float64(sp.Spooled), "logger", name, "event", "spooled")
+			pw.Sample("reqlog_logger_spool_entries_total", float64(sp.Replayed), "logger", name, "event", "replayed")
+			pw.Sample("reqlog_logger_spool_entries_total", float64(sp.Discarded), "logger", name, "event", "discarded")
+		}
+	}
+	if len(breakers) > 0 {
+		pw.Header("reqlog_logger_circuit_state", "gauge", "Write circuit breaker state, 1 for the current state.")
+		for _, name := range breakers {
+			for _, state := range []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+				value := 0.0
+				if loggers[name].Circuit.State == state.String() {
+					value = 1
+				}
+				pw.Sample("reqlog_logger_circuit_state", value, "logger", name, "state", state.String())
+			}
+		}
+		pw.Header("reqlog_logger_circuit_consecutive_failures", "gauge", "Consecutive write failures counted by the circuit breaker.")
+		for _, name := range breakers {
+			pw.Sample("reqlog_logger_circuit_consecutive_failures", float64(loggers[name].Circuit.ConsecutiveFailures), "logger", name)
+		}
+	}
+	return pw.Flush()
+}
+
+// PrometheusWriter Prometheus 文本格式（0.0.4）输出辅助
+type PrometheusWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewPrometheusWriter 创建 Prometheus 文本输出器
+func NewPrometheusWriter(w io.Writer) *PrometheusWriter {
+	return &PrometheusWriter{w: bufio.NewWriter(w)}
+}
+
+// Header 输出 HELP 和 TYPE 行
+func (p *PrometheusWriter) Header(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// Sample 输出一个样本，labels 为成对的标签名和值
+func (p *PrometheusWriter) Sample(name string, value float64, labels ...string) {
+	p.printf("%s%s %s\n", name, formatLabels(labels), formatFloat(value))
+}
+
+// Histogram 输出直方图的 _bucket、_sum、_count 样本
+// scale 用于换算单位，例如毫秒转秒传 0.001
+func (p *PrometheusWriter) Histogram(name string, snap HistogramSnapshot, scale float64, labels ...string) {
+	withLe := func(le string) []string {
+		return append(append(make([]string, 0, len(labels)+2), labels...), "le", le)
+	}
+	for i, upper := range snap.Buckets {
+		p.Sample(name+"_bucket", float64(snap.Counts[i]), withLe(formatFloat(upper*scale))...)
+	}
+	p.Sample(name+"_bucket", float64(snap.Count), withLe("+Inf")...)
+	p.Sample(name+"_sum", snap.Sum*scale, labels...)
+	p.Sample(name+"_count", float64(snap.Count), labels...)
+}
+
+// Flush 刷新输出并返回过程中遇到的第一个错误
+func (p *PrometheusWriter) Flush() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+func (p *PrometheusWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+// formatLabels 格式化标签，labels 为成对的标签名和值
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(labels[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,51 @@
+package reqlogmid
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteLoggerPrometheusSpoolAndCircuit(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteLoggerPrometheus(&buf, map[string]LoggerStats{
+		"db": {
+			Spool:   &SpoolStats{Pending: 3, Bytes: 120, Spooled: 10, Replayed: 6, Discarded: 1},
+			Circuit: &CircuitBreakerStats{State: CircuitOpen.String(), ConsecutiveFailures: 5},
+		},
+		"file": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`reqlog_logger_spool_pending{logger="db"} 3`,
+		`reqlog_logger_spool_bytes{logger="db"} 120`,
+		`reqlog_logger_spool_entries_total{logger="db",event="spooled"} 10`,
+		`reqlog_logger_spool_entries_total{logger="db",event="replayed"} 6`,
+		`reqlog_logger_spool_entries_total{logger="db",event="discarded"} 1`,
+		`reqlog_logger_circuit_state{logger="db",state="closed"} 0`,
+		`reqlog_logger_circuit_state{logger="db",state="open"} 1`,
+		`reqlog_logger_circuit_consecutive_failures{logger="db"} 5`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Contains(out, `spool_pending{logger="file"}`) || strings.Contains(out, `circuit_state{logger="file"`) {
+		t.Error("loggers without spool or breaker should not be exported")
+	}
+}
+
+func TestLoggerStatsOmitsZeroTimes(t *testing.T) {
+	data, err := json.Marshal(LoggerStats{Circuit: &CircuitBreakerStats{State: CircuitClosed.String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); strings.Contains(s, "last_error_at") || strings.Contains(s, "opened_at") {
+		t.Errorf("zero times serialised: %s", s)
+	}
+}
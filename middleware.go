@@ -2,7 +2,10 @@ package reqlogmid
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +16,15 @@ type contextKey string
 
 const logEntryKey contextKey = "req_log_entry"
 
+// Middleware 请求日志中间件
+// 需要优雅关闭时使用 NewMiddleware 创建，以便等待异步模式下尚未交给 Logger 的写入
+type Middleware struct {
+	logger Logger
+	cfg    *Config
+	// pending 跟踪异步模式下尚未交给 Logger 的写入
+	pending sync.WaitGroup
+}
+
 // RequestLogger 创建并返回请求日志中间件
 // logger 日志输出器实例
 func RequestLogger(logger Logger) gin.HandlerFunc {
@@ -23,6 +35,13 @@ func RequestLogger(logger Logger) gin.HandlerFunc {
 // logger 日志输出器实例
 // cfg 配置选项
 func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
+	return NewMiddleware(logger, cfg).Handler()
+}
+
+// NewMiddleware 创建请求日志中间件
+// logger 日志输出器实例
+// cfg 配置选项，为 nil 时使用默认配置
+func NewMiddleware(logger Logger, cfg *Config) *Middleware {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
@@ -34,8 +53,18 @@ func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
 	if cfg.BufferSize <= 0 {
 		cfg.BufferSize = 1000
 	}
+	return &Middleware{logger: logger, cfg: cfg}
+}
 
-	// 返回中间件处理函数
+// WaitPendingWrites 等待该中间件在异步模式下已发起但尚未交给 Logger 的写入完成
+// 应在 http.Server.Shutdown 之后、关闭 Logger 之前调用，确保最后一批请求的日志不丢失
+func (m *Middleware) WaitPendingWrites(ctx context.Context) error {
+	return waitGroupContext(ctx, &m.pending)
+}
+
+// Handler 返回中间件处理函数
+func (m *Middleware) Handler() gin.HandlerFunc {
+	logger, cfg := m.logger, m.cfg
 	return func(c *gin.Context) {
 		// 每次请求时读取最新配置（使用读锁保护）
 		cfg.RLock()
@@ -44,6 +73,7 @@ func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
 		customFields := cfg.CustomFields
 		async := cfg.Async
 		timeFormat := cfg.TimeFormat
+		metrics := cfg.Metrics
 		cfg.RUnlock()
 
 		// 检查是否启用
@@ -93,7 +123,13 @@ func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
 		// 获取 User-Agent
 		userAgent := c.Request.UserAgent()
 
+		// 记录请求指标
+		if metrics != nil {
+			metrics.Observe(c.Request.Method, c.FullPath(), statusCode, float64(duration)/float64(time.Millisecond))
+		}
+
 		// 创建日志条目
+		now := time.Now()
 		entry := NewLogEntry(
 			c.Request.Method,
 			c.Request.URL.Path,
@@ -101,8 +137,14 @@ func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
 			userAgent,
 			statusCode,
 			duration,
-			time.Now().Format(timeFormat),
+			now.Format(timeFormat),
 		)
+		entry.RequestTime = now
+		entry.Route = c.FullPath()
+		entry.Query = c.Request.URL.RawQuery
+		if len(c.Errors) > 0 {
+			entry.Error = strings.Join(c.Errors.Errors(), "; ")
+		}
 
 		// 添加自定义字段
 		if customFields != nil {
@@ -116,7 +158,9 @@ func RequestLoggerWithConfig(logger Logger, cfg *Config) gin.HandlerFunc {
 		c.Set(string(logEntryKey), entry)
 
 		if async {
+			m.pending.Add(1)
 			go func() {
+				defer m.pending.Done()
 				// 复制一份日志条目，避免并发访问问题
 				logCopy := *entry
 				if err := logger.Write(&logCopy); err != nil {
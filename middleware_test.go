@@ -0,0 +1,47 @@
+package reqlogmid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareWaitPendingWritesIsPerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	stuck := &blockingLogger{release: make(chan struct{})}
+	stuckMW := NewMiddleware(stuck, &Config{Enabled: true, Async: true})
+	mem := NewMemoryLogger(10)
+	memMW := NewMiddleware(mem, &Config{Enabled: true, Async: true})
+
+	for _, mw := range []*Middleware{stuckMW, memMW} {
+		r := gin.New()
+		r.Use(mw.Handler())
+		r.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	// 另一个中间件卡住的写入不影响本中间件的等待
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := memMW.WaitPendingWrites(ctx); err != nil {
+		t.Fatalf("WaitPendingWrites: %v", err)
+	}
+	if mem.Len() != 1 {
+		t.Errorf("memory logger has %d logs, want 1", mem.Len())
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	if err := stuckMW.WaitPendingWrites(short); err == nil {
+		t.Error("WaitPendingWrites on stuck middleware returned nil")
+	}
+	close(stuck.release)
+	if err := stuckMW.WaitPendingWrites(context.Background()); err != nil {
+		t.Errorf("WaitPendingWrites after release: %v", err)
+	}
+}
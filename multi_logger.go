@@ -0,0 +1,316 @@
+package reqlogmid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SinkFilter 输出目标的过滤条件，各条件之间为 AND 关系，零值表示全部放行
+type SinkFilter struct {
+	MinStatus  int      // 最小状态码（含），0 表示不限
+	MaxStatus  int      // 最大状态码（含），0 表示不限
+	Routes     []string // 路由模板或路径前缀，任一匹配即可，为空表示不限
+	SampleRate float64  // 采样率 (0, 1)，0 或大于等于 1 表示不采样
+	// Match 自定义过滤函数，为 nil 表示不限
+	Match func(entry *LogEntry) bool
+}
+
+// Allow 判断日志是否满足过滤条件
+func (f SinkFilter) Allow(entry *LogEntry) bool {
+	if f.MinStatus > 0 && entry.StatusCode < f.MinStatus {
+		return false
+	}
+	if f.MaxStatus > 0 && entry.StatusCode > f.MaxStatus {
+		return false
+	}
+	if len(f.Routes) > 0 && !matchRoutes(f.Routes, entry) {
+		return false
+	}
+	if f.Match != nil && !f.Match(entry) {
+		return false
+	}
+	if f.SampleRate > 0 && f.SampleRate < 1 && rand.Float64() >= f.SampleRate {
+		return false
+	}
+	return true
+}
+
+// matchRoutes 路由模板精确匹配，或请求路径前缀匹配
+func matchRoutes(routes []string, entry *LogEntry) bool {
+	for _, r := range routes {
+		if entry.Route == r || strings.HasPrefix(entry.Path, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink MultiLogger 的一个输出目标
+type Sink struct {
+	Name      string     // 名称，用于统计和错误信息
+	Logger    Logger     // 实际输出器
+	Filter    SinkFilter // 过滤条件
+	QueueSize int        // 独立队列大小，默认 1000，队列满时丢弃
+	// FlushTimeout Flush 等待该目标写完队列的最长时间，默认 10s
+	FlushTimeout time.Duration
+}
+
+// SinkStats 单个输出目标的统计
+type SinkStats struct {
+	QueueDepth int    `json:"queue_depth"`
+	Written    uint64 `json:"written"`
+	Failed     uint64 `json:"failed"`
+	Dropped    uint64 `json:"dropped"`  // 队列满丢弃
+	Filtered   uint64 `json:"filtered"` // 未通过过滤条件
+}
+
+// MultiLogger 将日志分发到多个输出目标
+// 每个目标拥有独立队列和写入协程，慢速或故障的目标不会影响其他目标
+type MultiLogger struct {
+	sinks  []*multiSink
+	closed bool
+	mu     sync.RWMutex
+}
+
+type multiSink struct {
+	Sink
+	ch   chan sinkItem
+	quit chan struct{} // 关闭超时时关闭，写入协程立即退出
+	wg   sync.WaitGroup
+
+	written  uint64
+	failed   uint64
+	dropped  uint64
+	filtered uint64
+}
+
+// sinkItem 队列元素，done 不为 nil 时表示刷新请求
+type sinkItem struct {
+	entry *LogEntry
+	done  chan struct{}
+}
+
+// NewMultiLogger 创建多目标日志输出器
+func NewMultiLogger(sinks ...Sink) *MultiLogger {
+	m := &MultiLogger{}
+	for i, s := range sinks {
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("sink%d", i)
+		}
+		if s.QueueSize <= 0 {
+			s.QueueSize = 1000
+		}
+		if s.FlushTimeout <= 0 {
+			s.FlushTimeout = 10 * time.Second
+		}
+		ms := &multiSink{
+			Sink: s,
+			ch:   make(chan sinkItem, s.QueueSize),
+			quit: make(chan struct{}),
+		}
+		ms.start()
+		m.sinks = append(m.sinks, ms)
+	}
+	return m
+}
+
+// start 启动目标的写入协程
+func (s *multiSink) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for item := range s.ch {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			if item.done != nil {
+				s.Logger.Flush()
+				close(item.done)
+				continue
+			}
+			if err := s.Logger.Write(item.entry); err != nil {
+				atomic.AddUint64(&s.failed, 1)
+				fmt.Fprintf(os.Stderr, "multi logger sink %s: failed to write log: %v\n", s.Name, err)
+				continue
+			}
+			atomic.AddUint64(&s.written, 1)
+		}
+	}()
+}
+
+// Write 实现 Logger 接口
+func (m *MultiLogger) Write(entry *LogEntry) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return fmt.Errorf("logger is closed")
+	}
+
+	for _, s := range m.sinks {
+		if !s.Filter.Allow(entry) {
+			atomic.AddUint64(&s.filtered, 1)
+			continue
+		}
+		// 每个目标各自持有一份副本，避免某个目标修改条目影响其他目标
+		entryCopy := *entry
+		select {
+		case s.ch <- sinkItem{entry: &entryCopy}:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+	return nil
+}
+
+// Flush 实现 Logger 接口，等待各目标队列写完并刷新
+// 每个目标最多等待其 FlushTimeout，卡住的目标不会让调用方无限阻塞
+func (m *MultiLogger) Flush() {
+	if err := m.FlushContext(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "multi logger: %v\n", err)
+	}
+}
+
+// FlushContext 与 Flush 相同，但在 ctx 取消或某个目标超过 FlushTimeout 时停止等待该目标
+// 返回未在期限内完成刷新的目标的错误
+func (m *MultiLogger) FlushContext(ctx context.Context) error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, s := range m.sinks {
+		wg.Add(1)
+		go func(s *multiSink) {
+			defer wg.Done()
+			sinkCtx, cancel := context.WithTimeout(ctx, s.FlushTimeout)
+			defer cancel()
+			if err := m.flushSink(sinkCtx, s); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("sink %s: flush: %w", s.Name, err))
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// flushSink 向目标队列投递刷新请求并等待其完成
+// 只在投递时持有读锁（保证队列未被 Shutdown 关闭），等待完成时不持锁
+func (m *MultiLogger) flushSink(ctx context.Context, s *multiSink) error {
+	done := make(chan struct{})
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return nil
+	}
+	select {
+	case s.ch <- sinkItem{done: done}:
+	case <-ctx.Done():
+		m.mu.RUnlock()
+		return ctx.Err()
+	}
+	m.mu.RUnlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-s.quit:
+		// Shutdown 超时，写入协程已放弃队列
+		return errors.New("sink shut down before flush completed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 实现 Logger 接口，关闭所有目标并汇总错误
+func (m *MultiLogger) Close() error {
+	_, err := m.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口
+// 停止接收新日志，各目标并行地在 ctx 截止前写完队列并关闭，返回所有目标未写入的条数之和
+func (m *MultiLogger) Shutdown(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return 0, nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	var (
+		mu   sync.Mutex
+		lost int
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, s := range m.sinks {
+		close(s.ch)
+	}
+	for _, s := range m.sinks {
+		wg.Add(1)
+		go func(s *multiSink) {
+			defer wg.Done()
+			var sinkLost int
+			var sinkErrs []error
+			if err := waitGroupContext(ctx, &s.wg); err != nil {
+				// 不再等待卡住的写入协程，它在当前写入返回后退出
+				close(s.quit)
+				sinkLost = len(s.ch)
+				sinkErrs = append(sinkErrs, shutdownError(sinkLost, err))
+			}
+			n, err := ShutdownLogger(ctx, s.Logger)
+			sinkLost += n
+			if err != nil {
+				sinkErrs = append(sinkErrs, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			lost += sinkLost
+			for _, err := range sinkErrs {
+				errs = append(errs, fmt.Errorf("sink %s: %w", s.Name, err))
+			}
+		}(s)
+	}
+	wg.Wait()
+	return lost, errors.Join(errs...)
+}
+
+// SinkStats 返回各目标统计，键为目标名称
+func (m *MultiLogger) SinkStats() map[string]SinkStats {
+	stats := make(map[string]SinkStats, len(m.sinks))
+	for _, s := range m.sinks {
+		stats[s.Name] = SinkStats{
+			QueueDepth: len(s.ch),
+			Written:    atomic.LoadUint64(&s.written),
+			Failed:     atomic.LoadUint64(&s.failed),
+			Dropped:    atomic.LoadUint64(&s.dropped),
+			Filtered:   atomic.LoadUint64(&s.filtered),
+		}
+	}
+	return stats
+}
+
+// LoggerStats 返回各目标中实现了 StatsProvider 的输出器统计，键为目标名称
+func (m *MultiLogger) LoggerStats() map[string]LoggerStats {
+	stats := make(map[string]LoggerStats)
+	for _, s := range m.sinks {
+		if p, ok := s.Logger.(StatsProvider); ok {
+			stats[s.Name] = p.Stats()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,53 @@
+package reqlogmid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingLogger 在 release 关闭前阻塞所有写入
+type blockingLogger struct {
+	release chan struct{}
+}
+
+func (l *blockingLogger) Write(*LogEntry) error { <-l.release; return nil }
+func (l *blockingLogger) Flush()                {}
+func (l *blockingLogger) Close() error          { return nil }
+
+func TestMultiLoggerFlushContextBoundsStuckSink(t *testing.T) {
+	stuck := &blockingLogger{release: make(chan struct{})}
+	defer close(stuck.release)
+	fast := NewMemoryLogger(10)
+	m := NewMultiLogger(
+		Sink{Name: "stuck", Logger: stuck, QueueSize: 1, FlushTimeout: 50 * time.Millisecond},
+		Sink{Name: "fast", Logger: fast},
+	)
+
+	// 第一条阻塞写入协程，第二条占满队列，刷新请求无法入队
+	for i := 0; i < 2; i++ {
+		if err := m.Write(&LogEntry{Method: "GET", Path: "/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	err := m.FlushContext(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FlushContext = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FlushContext took %v", elapsed)
+	}
+	if n := fast.Len(); n != 2 {
+		t.Errorf("fast sink has %d logs after flush, want 2", n)
+	}
+
+	// 刷新超时后不再持有锁，Shutdown 可以按自己的期限结束
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.Shutdown(ctx); err == nil {
+		t.Error("Shutdown with stuck sink returned nil error")
+	}
+}
@@ -0,0 +1,285 @@
+package reqlogmid
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// OpenSearchConfig OpenSearch / Elasticsearch 输出配置
+type OpenSearchConfig struct {
+	URL             string // 集群地址，如 http://localhost:9200，必填
+	IndexPrefix     string // 索引名前缀，默认 request-logs，索引名为 <前缀>-<日期>
+	IndexDateFormat string // 索引日期格式（Go 时间格式，按 UTC），默认 2006.01.02
+	Username        string // Basic 认证用户名
+	Password        string // Basic 认证密码
+	Headers         map[string]string
+	Gzip            bool
+	// TimeFormat 日志 Timestamp 的格式，应与中间件 Config.TimeFormat 一致，默认 DefaultTimeFormat
+	// 仅在日志没有 RequestTime（如非中间件产生的日志）时用于确定索引日期
+	TimeFormat string
+	// TemplateName 索引模板名，默认与 IndexPrefix 相同
+	TemplateName string
+	// EnsureTemplate 创建时写入索引模板，失败则返回错误
+	EnsureTemplate bool
+	Timeout        time.Duration // 单次请求超时，默认 10s
+	Retry          *RetryConfig  // 重试配置，默认 DefaultRetryConfig，同时用于重试 bulk 中被拒绝的单条
+	Batch          BatchConfig   // 攒批配置
+	Client         *http.Client  // 自定义 HTTP 客户端
+}
+
+// BulkStats bulk 写入统计
+type BulkStats struct {
+	DeliveryStats
+	ItemsRetried  uint64 `json:"items_retried"`  // 因 429/5xx 重试的单条次数
+	ItemsRejected uint64 `json:"items_rejected"` // 最终被拒绝的条数（如 mapping 冲突）
+}
+
+// OpenSearchLogger 通过 _bulk 接口将日志写入按天滚动的索引，兼容 Elasticsearch
+// bulk 响应中单条失败时，429/5xx 按退避重试，其余错误直接计为拒绝
+type OpenSearchLogger struct {
+	cfg     OpenSearchConfig
+	sender  *httpSender
+	batcher *batcher
+
+	itemsRetried  uint64
+	itemsRejected uint64
+}
+
+// NewOpenSearchLogger 创建 OpenSearch 日志输出器
+func NewOpenSearchLogger(cfg OpenSearchConfig) (*OpenSearchLogger, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("opensearch logger: url is required")
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	if cfg.IndexPrefix == "" {
+		cfg.IndexPrefix = "request-logs"
+	}
+	if cfg.IndexDateFormat == "" {
+		cfg.IndexDateFormat = "2006.01.02"
+	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = DefaultTimeFormat
+	}
+	if cfg.TemplateName == "" {
+		cfg.TemplateName = cfg.IndexPrefix
+	}
+
+	sender := newHTTPSender(cfg.Client, cfg.Timeout, cfg.Retry)
+	sender.gzip = cfg.Gzip
+	sender.headers = make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		sender.headers[k] = v
+	}
+	if cfg.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+		sender.headers["Authorization"] = "Basic " + auth
+	}
+
+	l := &OpenSearchLogger{cfg: cfg, sender: sender}
+	if cfg.EnsureTemplate {
+		ctx, cancel := context.WithTimeout(context.Background(), sender.timeout)
+		defer cancel()
+		if err := l.EnsureIndexTemplate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	l.batcher = newBatcher("opensearch logger", cfg.Batch, l.send)
+	return l, nil
+}
+
+// IndexName 返回日志所属的索引名，日期取自请求时间而非写入时间
+func (l *OpenSearchLogger) IndexName(entry *LogEntry) string {
+	return l.cfg.IndexPrefix + "-" + entryTime(entry, l.cfg.TimeFormat).UTC().Format(l.cfg.IndexDateFormat)
+}
+
+// IndexTemplate 返回 LogEntry 字段对应的索引模板
+func (l *OpenSearchLogger) IndexTemplate() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword", "ignore_above": 1024}
+	return map[string]interface{}{
+		"index_patterns": []string{l.cfg.IndexPrefix + "-*"},
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"method":      map[string]interface{}{"type": "keyword"},
+					"path":        keyword,
+					"route":       map[string]interface{}{"type": "keyword"},
+					"client_ip":   map[string]interface{}{"type": "ip"},
+					"user_agent":  map[string]interface{}{"type": "text", "fields": map[string]interface{}{"raw": keyword}},
+					"status_code": map[string]interface{}{"type": "short"},
+					"duration_ms": map[string]interface{}{"type": "double"},
+					"timestamp":   map[string]interface{}{"type": "date"},
+					"custom_fields": map[string]interface{}{
+						"type":    "object",
+						"dynamic": true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// EnsureIndexTemplate 创建或更新索引模板
+func (l *OpenSearchLogger) EnsureIndexTemplate(ctx context.Context) error {
+	data, err := json.Marshal(l.IndexTemplate())
+	if err != nil {
+		return err
+	}
+	url := l.cfg.URL + "/_index_template/" + l.cfg.TemplateName
+	if _, err := l.sender.request(ctx, http.MethodPut, url, "application/json", data); err != nil {
+		return fmt.Errorf("failed to put index template %s: %w", l.cfg.TemplateName, err)
+	}
+	return nil
+}
+
+// bulkResponse _bulk 响应
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// encode 编码为 bulk 请求体
+func (l *OpenSearchLogger) encode(entries []*LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		action := map[string]map[string]string{"index": {"_index": l.IndexName(entry)}}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// send 发送一批日志，对 bulk 响应中可重试的单条失败重新发送
+func (l *OpenSearchLogger) send(ctx context.Context, entries []*LogEntry) error {
+	maxAttempts := l.sender.retry.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	pending := entries
+	var rejected int
+	var lastReason string
+	for attempt := 1; ; attempt++ {
+		payload, err := l.encode(pending)
+		if err != nil {
+			return err
+		}
+		body, err := l.sender.post(ctx, l.cfg.URL+"/_bulk", "application/x-ndjson", payload)
+		if err != nil {
+			if rejected == 0 && len(pending) == len(entries) {
+				return err
+			}
+			return &partialBatchError{failed: rejected + len(pending), err: err}
+		}
+
+		var resp bulkResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("failed to parse bulk response: %w", err)
+		}
+		if !resp.Errors {
+			break
+		}
+
+		var retry []*LogEntry
+		for i, item := range resp.Items {
+			if i >= len(pending) {
+				break
+			}
+			for _, result := range item {
+				if result.Status < 300 {
+					continue
+				}
+				if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+					retry = append(retry, pending[i])
+					continue
+				}
+				rejected++
+				atomic.AddUint64(&l.itemsRejected, 1)
+				if result.Error != nil {
+					lastReason = result.Error.Type + ": " + result.Error.Reason
+				}
+			}
+		}
+
+		if len(retry) == 0 {
+			break
+		}
+		if attempt >= maxAttempts {
+			rejected += len(retry)
+			atomic.AddUint64(&l.itemsRejected, uint64(len(retry)))
+			lastReason = fmt.Sprintf("%d items still rejected after %d attempts", len(retry), attempt)
+			break
+		}
+
+		atomic.AddUint64(&l.itemsRetried, uint64(len(retry)))
+		timer := time.NewTimer(l.sender.retry.Backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &partialBatchError{failed: rejected + len(retry), err: ctx.Err()}
+		}
+		pending = retry
+	}
+
+	if rejected > 0 {
+		return &partialBatchError{failed: rejected, err: fmt.Errorf("bulk rejected: %s", lastReason)}
+	}
+	return nil
+}
+
+// Write 实现 Logger 接口
+func (l *OpenSearchLogger) Write(entry *LogEntry) error {
+	return l.batcher.write(entry)
+}
+
+// Flush 实现 Logger 接口，立即写入所有待发送日志
+func (l *OpenSearchLogger) Flush() {
+	l.batcher.flush()
+}
+
+// Close 实现 Logger 接口
+func (l *OpenSearchLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口
+func (l *OpenSearchLogger) Shutdown(ctx context.Context) (int, error) {
+	return l.batcher.shutdown(ctx)
+}
+
+// Stats 返回运行统计，写入耗时为单批 bulk 耗时（含重试）
+func (l *OpenSearchLogger) Stats() LoggerStats {
+	return l.batcher.loggerStats()
+}
+
+// BulkStats 返回 bulk 写入统计
+func (l *OpenSearchLogger) BulkStats() BulkStats {
+	stats := BulkStats{
+		DeliveryStats: l.sender.deliveryStats(),
+		ItemsRetried:  atomic.LoadUint64(&l.itemsRetried),
+		ItemsRejected: atomic.LoadUint64(&l.itemsRejected),
+	}
+	stats.BatchStats = l.batcher.batchStats()
+	return stats
+}
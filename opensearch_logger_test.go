@@ -0,0 +1,146 @@
+package reqlogmid
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBulk 模拟 _bulk 接口，按请求序号依次返回 statuses 中每条的状态
+type fakeBulk struct {
+	mu       sync.Mutex
+	statuses [][]int
+	paths    [][]string // 每次请求中各条日志的 path
+	indices  []string
+}
+
+func (f *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var paths []string
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		var action map[string]map[string]string
+		if err := json.Unmarshal(sc.Bytes(), &action); err != nil || !sc.Scan() {
+			http.Error(w, "bad bulk body", http.StatusBadRequest)
+			return
+		}
+		var entry LogEntry
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.indices = append(f.indices, action["index"]["_index"])
+		paths = append(paths, entry.Path)
+	}
+	n := len(f.paths)
+	f.paths = append(f.paths, paths)
+
+	var items []string
+	hasErrors := false
+	for i := range paths {
+		status := http.StatusCreated
+		if n < len(f.statuses) && i < len(f.statuses[n]) {
+			status = f.statuses[n][i]
+		}
+		errJSON := "null"
+		if status >= 300 {
+			hasErrors = true
+			errJSON = fmt.Sprintf(`{"type":"err_%d","reason":"status %d"}`, status, status)
+		}
+		items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":%s}}`, status, errJSON))
+	}
+	fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+}
+
+func newTestOpenSearchLogger(t *testing.T, h http.Handler, cfg OpenSearchConfig) *OpenSearchLogger {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	cfg.URL = srv.URL + "/"
+	if cfg.Retry == nil {
+		cfg.Retry = &RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	}
+	l, err := NewOpenSearchLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestOpenSearchBulkPartialFailures(t *testing.T) {
+	bulk := &fakeBulk{statuses: [][]int{
+		{201, 429, 400, 503},
+		{201, 429},
+		{201},
+	}}
+	l := newTestOpenSearchLogger(t, bulk, OpenSearchConfig{})
+
+	entries := []*LogEntry{{Path: "/ok"}, {Path: "/throttled"}, {Path: "/mapping"}, {Path: "/unavailable"}}
+	err := l.send(context.Background(), entries)
+	var pe *partialBatchError
+	if !errors.As(err, &pe) || pe.failed != 1 || !strings.Contains(err.Error(), "err_400") {
+		t.Fatalf("send = %v, want 1 entry rejected with err_400", err)
+	}
+
+	want := [][]string{
+		{"/ok", "/throttled", "/mapping", "/unavailable"},
+		{"/throttled", "/unavailable"},
+		{"/unavailable"},
+	}
+	if fmt.Sprint(bulk.paths) != fmt.Sprint(want) {
+		t.Errorf("bulk requests = %v, want %v", bulk.paths, want)
+	}
+	if stats := l.BulkStats(); stats.ItemsRetried != 3 || stats.ItemsRejected != 1 || stats.Requests != 3 {
+		t.Errorf("bulk stats = %+v", stats)
+	}
+}
+
+func TestOpenSearchBulkGivesUpAfterMaxAttempts(t *testing.T) {
+	bulk := &fakeBulk{statuses: [][]int{{201, 503}, {503}, {503}}}
+	l := newTestOpenSearchLogger(t, bulk, OpenSearchConfig{})
+
+	err := l.send(context.Background(), []*LogEntry{{Path: "/a"}, {Path: "/b"}})
+	var pe *partialBatchError
+	if !errors.As(err, &pe) || pe.failed != 1 {
+		t.Fatalf("send = %v, want 1 entry failed", err)
+	}
+	if len(bulk.paths) != 3 {
+		t.Errorf("sent %d bulk requests, want 3", len(bulk.paths))
+	}
+	if stats := l.BulkStats(); stats.ItemsRetried != 2 || stats.ItemsRejected != 1 {
+		t.Errorf("bulk stats = %+v", stats)
+	}
+}
+
+func TestOpenSearchIndexName(t *testing.T) {
+	l := newTestOpenSearchLogger(t, &fakeBulk{}, OpenSearchConfig{IndexPrefix: "logs", TimeFormat: "2006-01-02 15:04:05"})
+
+	cst := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name  string
+		entry LogEntry
+		want  string
+	}{
+		{"request time wins", LogEntry{RequestTime: time.Date(2026, 3, 1, 7, 0, 0, 0, cst), Timestamp: "2020-01-01 00:00:00"}, "logs-2026.02.28"},
+		{"configured time format", LogEntry{Timestamp: "2026-03-01 12:00:00"}, "logs-2026.03.01"},
+		{"default format fallback", LogEntry{Timestamp: "2026-03-02T01:00:00.000+08:00"}, "logs-2026.03.01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.IndexName(&tt.entry); got != tt.want {
+				t.Errorf("IndexName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package reqlogmid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OTLP 严重级别（SeverityNumber）
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// OTLPConfig OTLP/HTTP 日志导出配置
+type OTLPConfig struct {
+	Endpoint           string            // 日志接收地址，默认 http://localhost:4318/v1/logs
+	ServiceName        string            // 资源属性 service.name，默认 req-log-mid
+	ServiceVersion     string            // 资源属性 service.version，为空不设置
+	ResourceAttributes map[string]string // 其他资源属性，如 deployment.environment
+	Headers            map[string]string // 自定义请求头，如认证信息
+	Gzip               bool
+	Timeout            time.Duration // 单次请求超时，默认 10s
+	Retry              *RetryConfig  // 重试配置，默认 DefaultRetryConfig
+	Batch              BatchConfig   // 攒批配置
+	Client             *http.Client  // 自定义 HTTP 客户端
+}
+
+// OTLPLogger 将日志转换为 OTLP 日志记录，通过 OTLP/HTTP 导出
+// 属性遵循 HTTP 语义约定，使用 JSON 编码；暂不支持 protobuf 编码
+type OTLPLogger struct {
+	cfg      OTLPConfig
+	sender   *httpSender
+	batcher  *batcher
+	resource otlpResource
+}
+
+// NewOTLPLogger 创建 OTLP 日志导出器
+func NewOTLPLogger(cfg OTLPConfig) (*OTLPLogger, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "req-log-mid"
+	}
+
+	sender := newHTTPSender(cfg.Client, cfg.Timeout, cfg.Retry)
+	sender.headers = cfg.Headers
+	sender.gzip = cfg.Gzip
+
+	l := &OTLPLogger{cfg: cfg, sender: sender}
+	l.resource = l.buildResource()
+	l.batcher = newBatcher("otlp logger", cfg.Batch, l.send)
+	return l, nil
+}
+
+// OTLP JSON 编码结构
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 按 JSON 映射编码为字符串
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpExportResponse struct {
+	PartialSuccess *struct {
+		RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+		ErrorMessage       string      `json:"errorMessage"`
+	} `json:"partialSuccess"`
+}
+
+// buildResource 构建资源属性
+func (l *OTLPLogger) buildResource() otlpResource {
+	attrs := []otlpKeyValue{otlpString("service.name", l.cfg.ServiceName)}
+	if l.cfg.ServiceVersion != "" {
+		attrs = append(attrs, otlpString("service.version", l.cfg.ServiceVersion))
+	}
+	keys := make([]string, 0, len(l.cfg.ResourceAttributes))
+	for k := range l.cfg.ResourceAttributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, otlpString(k, l.cfg.ResourceAttributes[k]))
+	}
+	return otlpResource{Attributes: attrs}
+}
+
+// record 将单条日志转换为 OTLP 日志记录
+func (l *OTLPLogger) record(entry *LogEntry, observed string) otlpLogRecord {
+	severity, severityText := otlpSeverityInfo, "INFO"
+	switch {
+	case entry.StatusCode >= 500:
+		severity, severityText = otlpSeverityError, "ERROR"
+	case entry.StatusCode >= 400:
+		severity, severityText = otlpSeverityWarn, "WARN"
+	}
+
+	attrs := []otlpKeyValue{
+		otlpString("http.request.method", entry.Method),
+		otlpString("url.path", entry.Path),
+		otlpInt("http.response.status_code", int64(entry.StatusCode)),
+		// 语义约定中 http.server.request.duration 是指标名而非日志属性，耗时使用自定义命名空间的属性
+		otlpDouble("reqlog.duration_ms", entry.Duration),
+	}
+	if entry.Route != "" {
+		attrs = append(attrs, otlpString("http.route", entry.Route))
+	}
+	if entry.ClientIP != "" {
+		attrs = append(attrs, otlpString("client.address", entry.ClientIP))
+	}
+	if entry.UserAgent != "" {
+		attrs = append(attrs, otlpString("user_agent.original", entry.UserAgent))
+	}
+
+	keys := make([]string, 0, len(entry.CustomFields))
+	for k := range entry.CustomFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttribute(k, entry.CustomFields[k]))
+	}
+
+	body := fmt.Sprintf("%s %s %d", entry.Method, entry.Path, entry.StatusCode)
+	return otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(entryTime(entry, "").UnixNano(), 10),
+		ObservedTimeUnixNano: observed,
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 otlpAnyValue{StringValue: &body},
+		Attributes:           attrs,
+	}
+}
+
+// encode 编码为导出请求体
+func (l *OTLPLogger) encode(entries []*LogEntry) ([]byte, error) {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+	records := make([]otlpLogRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, l.record(entry, observed))
+	}
+
+	req := otlpExportRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: l.resource,
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: "github.com/zxyao/req-log-mid"},
+				LogRecords: records,
+			}},
+		}},
+	}
+	return json.Marshal(req)
+}
+
+// send 导出一批日志，处理部分成功响应
+func (l *OTLPLogger) send(ctx context.Context, entries []*LogEntry) error {
+	payload, err := l.encode(entries)
+	if err != nil {
+		return err
+	}
+	body, err := l.sender.post(ctx, l.cfg.Endpoint, "application/json", payload)
+	if err != nil {
+		return err
+	}
+
+	var resp otlpExportResponse
+	if len(body) == 0 || json.Unmarshal(body, &resp) != nil || resp.PartialSuccess == nil {
+		return nil
+	}
+	rejected, _ := resp.PartialSuccess.RejectedLogRecords.Int64()
+	if rejected <= 0 {
+		return nil
+	}
+	if rejected > int64(len(entries)) {
+		rejected = int64(len(entries))
+	}
+	return &partialBatchError{
+		failed: int(rejected),
+		err:    fmt.Errorf("otlp partial success: %s", resp.PartialSuccess.ErrorMessage),
+	}
+}
+
+func otlpString(key, v string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpInt(key string, v int64) otlpKeyValue {
+	s := strconv.FormatInt(v, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpDouble(key string, v float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &v}}
+}
+
+// otlpAttribute 将自定义字段转换为属性，复合类型编码为 JSON 字符串
+func otlpAttribute(key string, v interface{}) otlpKeyValue {
+	switch val := v.(type) {
+	case string:
+		return otlpString(key, val)
+	case bool:
+		return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &val}}
+	case int:
+		return otlpInt(key, int64(val))
+	case int32:
+		return otlpInt(key, int64(val))
+	case int64:
+		return otlpInt(key, val)
+	case float32:
+		return otlpDouble(key, float64(val))
+	case float64:
+		return otlpDouble(key, val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return otlpString(key, fmt.Sprintf("%v", val))
+		}
+		return otlpString(key, string(data))
+	}
+}
+
+// Write 实现 Logger 接口
+func (l *OTLPLogger) Write(entry *LogEntry) error {
+	return l.batcher.write(entry)
+}
+
+// Flush 实现 Logger 接口，立即导出所有待发送日志
+func (l *OTLPLogger) Flush() {
+	l.batcher.flush()
+}
+
+// Close 实现 Logger 接口
+func (l *OTLPLogger) Close() error {
+	_, err := l.Shutdown(context.Background())
+	return err
+}
+
+// Shutdown 实现 Shutdowner 接口
+func (l *OTLPLogger) Shutdown(ctx context.Context) (int, error) {
+	return l.batcher.shutdown(ctx)
+}
+
+// Stats 返回运行统计，写入耗时为单批导出耗时（含重试）
+func (l *OTLPLogger) Stats() LoggerStats {
+	return l.batcher.loggerStats()
+}
+
+// DeliveryStats 返回投递统计
+func (l *OTLPLogger) DeliveryStats() DeliveryStats {
+	stats := l.sender.deliveryStats()
+	stats.BatchStats = l.batcher.batchStats()
+	return stats
+}
@@ -0,0 +1,62 @@
+package reqlogmid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOTLPRecordAttributes(t *testing.T) {
+	l, err := NewOTLPLogger(OTLPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	rec := l.record(&LogEntry{
+		Method: "GET", Path: "/users/1", Route: "/users/:id", ClientIP: "10.0.0.1",
+		StatusCode: 503, Duration: 12.5, CustomFields: map[string]interface{}{"tenant": "acme"},
+	}, "0")
+	if rec.SeverityText != "ERROR" {
+		t.Errorf("severity = %s, want ERROR", rec.SeverityText)
+	}
+
+	attrs := make(map[string]otlpAnyValue, len(rec.Attributes))
+	for _, kv := range rec.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if _, ok := attrs["http.server.request.duration"]; ok {
+		t.Error("metric name http.server.request.duration used as a log attribute")
+	}
+	if v := attrs["reqlog.duration_ms"].DoubleValue; v == nil || *v != 12.5 {
+		t.Errorf("reqlog.duration_ms = %v, want 12.5", v)
+	}
+	for key, want := range map[string]string{
+		"http.request.method": "GET",
+		"url.path":            "/users/1",
+		"http.route":          "/users/:id",
+		"client.address":      "10.0.0.1",
+		"tenant":              "acme",
+	} {
+		if v := attrs[key].StringValue; v == nil || *v != want {
+			t.Errorf("%s = %v, want %q", key, v, want)
+		}
+	}
+	if v := attrs["http.response.status_code"].IntValue; v == nil || *v != "503" {
+		t.Errorf("http.response.status_code = %v, want 503", v)
+	}
+}
+
+func TestOTLPRecordUsesRequestTime(t *testing.T) {
+	l, err := NewOTLPLogger(OTLPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// 自定义 TimeFormat 的 Timestamp 无法解析，记录时间取中间件记录的请求时间
+	requestTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := l.record(&LogEntry{Method: "GET", Path: "/", StatusCode: 200, Timestamp: "02/01/2026 03:04:05", RequestTime: requestTime}, "0")
+	if rec.TimeUnixNano != "1767323045000000000" {
+		t.Errorf("time_unix_nano = %s, want %d", rec.TimeUnixNano, requestTime.UnixNano())
+	}
+}
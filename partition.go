@@ -0,0 +1,479 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PartitionInterval 分区粒度
+type PartitionInterval string
+
+const (
+	// PartitionDaily 每天一个分区
+	PartitionDaily PartitionInterval = "day"
+	// PartitionMonthly 每月一个分区
+	PartitionMonthly PartitionInterval = "month"
+)
+
+// Validate 校验分区粒度
+func (i PartitionInterval) Validate() error {
+	switch i {
+	case PartitionDaily, PartitionMonthly:
+		return nil
+	}
+	return fmt.Errorf("unsupported partition interval %q", i)
+}
+
+// truncate 返回 t 所在分区的起点（本地时间）
+func (i PartitionInterval) truncate(t time.Time) time.Time {
+	t = t.In(time.Local)
+	if i == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// next 返回下一个分区的起点
+func (i PartitionInterval) next(t time.Time) time.Time {
+	if i == PartitionMonthly {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// layout 分区名中日期后缀的格式
+func (i PartitionInterval) layout() string {
+	if i == PartitionMonthly {
+		return "200601"
+	}
+	return "20060102"
+}
+
+// PartitionConfig 按写入时间范围分区的配置（仅 PostgreSQL）
+// 启用后 CreateTable 将日志表建为按 created_at 范围分区的表，分区名为 <表名>_pYYYYMMDD 或 <表名>_pYYYYMM，
+// 另有 <表名>_default 分区兜底；DeleteOldLogs 改为删除整个过期分区
+type PartitionConfig struct {
+	Interval            PartitionInterval // 分区粒度，默认按天
+	Premake             int               // 预先创建的未来分区数，默认按天 7 个、按月 2 个
+	Retention           time.Duration     // 保留时长，大于 0 时后台维护删除结束时间早于当前时间减 Retention 的分区
+	MaintenanceInterval time.Duration     // 后台维护（预建、过期删除）间隔，默认 1 小时
+}
+
+// partitionCopyColumns 在表之间移动日志时的列，与列的物理顺序无关
+const partitionCopyColumns = "id, method, path, route, query_string, client_ip, user_agent, status_code, duration_ms, timestamp, error_message, custom_fields, created_at"
+
+// partitionTimeFormat 分区边界的格式，DDL 中只能使用字面量
+const partitionTimeFormat = "2006-01-02 15:04:05"
+
+// EnablePartitioning 启用按时间范围分区，需在 CreateTable 之前调用
+// 同时启动后台维护协程，定期预建未来分区并删除过期分区
+func (l *DBLogger) EnablePartitioning(cfg PartitionConfig) error {
+	if l.dialect.Name() != "postgres" {
+		return fmt.Errorf("partitioning is not supported by %s", l.dialect.Name())
+	}
+	if cfg.Interval == "" {
+		cfg.Interval = PartitionDaily
+	}
+	if err := cfg.Interval.Validate(); err != nil {
+		return err
+	}
+	if cfg.Premake <= 0 {
+		cfg.Premake = 7
+		if cfg.Interval == PartitionMonthly {
+			cfg.Premake = 2
+		}
+	}
+	if cfg.MaintenanceInterval <= 0 {
+		cfg.MaintenanceInterval = time.Hour
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.partition != nil {
+		return fmt.Errorf("partitioning already enabled")
+	}
+	l.partition = &cfg
+	l.partitionQuit = make(chan struct{})
+	l.startPartitionMaintainer(cfg.MaintenanceInterval)
+	return nil
+}
+
+// startPartitionMaintainer 启动分区维护协程
+func (l *DBLogger) startPartitionMaintainer(interval time.Duration) {
+	l.partitionWg.Add(1)
+	go func() {
+		defer l.partitionWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.MaintainPartitions(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to maintain partitions: %v\n", err)
+				}
+			case <-l.partitionQuit:
+				return
+			}
+		}
+	}()
+}
+
+// partitionName 返回 start 所在分区的表名
+func (l *DBLogger) partitionName(start time.Time) string {
+	return l.tableName + "_p" + start.Format(l.partition.Interval.layout())
+}
+
+// defaultPartition 返回兜底分区的表名
+func (l *DBLogger) defaultPartition() string {
+	return l.tableName + "_default"
+}
+
+// defaultPartitionStmt 返回创建兜底分区的语句
+func (l *DBLogger) defaultPartitionStmt() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT", l.defaultPartition(), l.tableName)
+}
+
+// partitionStmt 返回创建 start 所在分区的语句
+func (l *DBLogger) partitionStmt(start time.Time) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		l.partitionName(start), l.tableName,
+		start.Format(partitionTimeFormat), l.partition.Interval.next(start).Format(partitionTimeFormat))
+}
+
+// partitionStmts 返回兜底分区及当前和预建分区的建表语句
+func (l *DBLogger) partitionStmts(now time.Time) []string {
+	stmts := []string{l.defaultPartitionStmt()}
+	start := l.partition.Interval.truncate(now)
+	for i := 0; i <= l.partition.Premake; i++ {
+		stmts = append(stmts, l.partitionStmt(start))
+		start = l.partition.Interval.next(start)
+	}
+	return stmts
+}
+
+// isPartitioned 判断日志表是否已是分区表
+func (l *DBLogger) isPartitioned() (bool, error) {
+	var partitioned bool
+	err := l.db.QueryRowContext(l.writeCtx, "SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass($1))", l.tableName).Scan(&partitioned)
+	return partitioned, err
+}
+
+// relationExists 判断表是否存在
+func (l *DBLogger) relationExists(name string) (bool, error) {
+	var exists bool
+	err := l.db.QueryRowContext(l.writeCtx, "SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists)
+	return exists, err
+}
+
+// createPartitions 创建兜底分区和当前、预建分区
+// 已有分区表在启用分区之前建成普通表时返回错误，需先调用 MigrateToPartitioned
+func (l *DBLogger) createPartitions() error {
+	partitioned, err := l.isPartitioned()
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		return fmt.Errorf("table %s is not partitioned, run MigrateToPartitioned first", l.tableName)
+	}
+	if _, err := l.db.ExecContext(l.writeCtx, l.defaultPartitionStmt()); err != nil {
+		return err
+	}
+	start := l.partition.Interval.truncate(time.Now())
+	for i := 0; i <= l.partition.Premake; i++ {
+		if err := l.createPartition(start); err != nil {
+			return err
+		}
+		start = l.partition.Interval.next(start)
+	}
+	return nil
+}
+
+// createPartition 创建 start 所在分区
+// 兜底分区中已有该范围的日志时（如维护曾中断），在一个事务中摘下兜底分区、建分区、移入日志后再挂回
+func (l *DBLogger) createPartition(start time.Time) error {
+	end := l.partition.Interval.next(start)
+	from, to := start.Format(partitionTimeFormat), end.Format(partitionTimeFormat)
+
+	var stray bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE created_at >= $1 AND created_at < $2)", l.defaultPartition())
+	if err := l.db.QueryRowContext(l.writeCtx, query, from, to).Scan(&stray); err != nil {
+		return err
+	}
+	if !stray {
+		_, err := l.db.ExecContext(l.writeCtx, l.partitionStmt(start))
+		return err
+	}
+
+	tx, err := l.db.BeginTx(l.writeCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmts := []string{
+		fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", l.tableName, l.defaultPartition()),
+		l.partitionStmt(start),
+		fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE created_at >= '%s' AND created_at < '%s' RETURNING %s) INSERT INTO %s (%s) SELECT %s FROM moved",
+			l.defaultPartition(), from, to, partitionCopyColumns, l.partitionName(start), partitionCopyColumns, partitionCopyColumns),
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s DEFAULT", l.tableName, l.defaultPartition()),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// logPartition 按命名规则识别出的分区
+type logPartition struct {
+	name       string
+	start, end time.Time
+}
+
+// listPartitions 返回按命名规则识别出的分区，按起点升序；兜底分区和其他手工分区不在其中
+func (l *DBLogger) listPartitions() ([]logPartition, error) {
+	rows, err := l.db.QueryContext(l.writeCtx, `
+		SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)`, l.tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return l.parsePartitions(names), nil
+}
+
+// parsePartitions 从子表名中识别按命名规则建立的分区，按起点升序
+func (l *DBLogger) parsePartitions(names []string) []logPartition {
+	prefix := l.tableName + "_p"
+	if i := strings.LastIndex(prefix, "."); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	var partitions []logPartition
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		start, err := time.ParseInLocation(l.partition.Interval.layout(), strings.TrimPrefix(name, prefix), time.Local)
+		if err != nil {
+			continue
+		}
+		partitions = append(partitions, logPartition{name: name, start: start, end: l.partition.Interval.next(start)})
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].start.Before(partitions[j].start) })
+	return partitions
+}
+
+// MaintainPartitions 预建当前及未来的分区，配置了 Retention 时删除过期分区
+// 后台维护协程定期调用，也可由外部定时任务调用；关闭超时时进行中的维护语句被中断
+func (l *DBLogger) MaintainPartitions() error {
+	if l.partition == nil {
+		return fmt.Errorf("partitioning is not enabled")
+	}
+	if err := l.createPartitions(); err != nil {
+		return err
+	}
+	if l.partition.Retention > 0 {
+		if _, err := l.dropPartitionsBefore(time.Now().Add(-l.partition.Retention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropPartitionsBefore 删除结束时间不晚于 cutoff 的分区，并清理兜底分区中早于 cutoff 的日志，返回删除条数
+// cutoff 所在分区中更早的日志保留到该分区整体过期，避免大范围 DELETE
+func (l *DBLogger) dropPartitionsBefore(cutoff time.Time) (int64, error) {
+	partitions, err := l.listPartitions()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, p := range expiredPartitions(partitions, cutoff) {
+		var count int64
+		if err := l.db.QueryRowContext(l.writeCtx, fmt.Sprintf("SELECT COUNT(*) FROM %s", p.name)).Scan(&count); err != nil {
+			return deleted, err
+		}
+		if _, err := l.db.ExecContext(l.writeCtx, fmt.Sprintf("DROP TABLE %s", p.name)); err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE created_at < $1", l.defaultPartition())
+	result, err := l.db.ExecContext(l.writeCtx, query, cutoff.In(time.Local).Format(partitionTimeFormat))
+	if err != nil {
+		return deleted, err
+	}
+	n, _ := result.RowsAffected()
+	return deleted + n, nil
+}
+
+// expiredPartitions 返回按起点升序的分区中结束时间不晚于 cutoff 的部分
+func expiredPartitions(partitions []logPartition, cutoff time.Time) []logPartition {
+	n := 0
+	for n < len(partitions) && !partitions[n].end.After(cutoff) {
+		n++
+	}
+	return partitions[:n]
+}
+
+// MigrateToPartitioned 将已有的普通日志表迁移为分区表，需先启用分区
+// 先在一个事务中把原表及其索引改名（加 _legacy 后缀）、建分区表和覆盖原有数据的分区，并让自增 id 接续原表，此后新日志写入分区表；
+// 再按分区逐段把原表中的日志移入分区表，每段一个语句，迁移期间统计和查询只能看到已移入的部分。
+// 中断后重新调用会从剩余的日志继续，全部移完后删除已空的原表；关闭超时时进行中的迁移语句被中断。返回本次移入的条数
+func (l *DBLogger) MigrateToPartitioned() (int64, error) {
+	if l.partition == nil {
+		return 0, fmt.Errorf("partitioning is not enabled")
+	}
+	legacy := l.legacyTable()
+
+	partitioned, err := l.isPartitioned()
+	if err != nil {
+		return 0, err
+	}
+	if !partitioned {
+		exists, err := l.relationExists(l.tableName)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			if err := l.swapInPartitionedTable(legacy); err != nil {
+				return 0, err
+			}
+		}
+		if err := l.CreateTable(); err != nil {
+			return 0, err
+		}
+	}
+
+	exists, err := l.relationExists(legacy)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var moved int64
+	for {
+		var first sql.NullTime
+		if err := l.db.QueryRowContext(l.writeCtx, fmt.Sprintf("SELECT MIN(created_at) FROM %s", legacy)).Scan(&first); err != nil {
+			return moved, err
+		}
+		if !first.Valid {
+			break
+		}
+		start := l.partition.Interval.truncate(convertToLocalTime(first.Time))
+		if err := l.createPartition(start); err != nil {
+			return moved, err
+		}
+		query := fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE created_at < $1 RETURNING %s) INSERT INTO %s (%s) SELECT %s FROM moved",
+			legacy, partitionCopyColumns, l.tableName, partitionCopyColumns, partitionCopyColumns)
+		result, err := l.db.ExecContext(l.writeCtx, query, l.partition.Interval.next(start).Format(partitionTimeFormat))
+		if err != nil {
+			return moved, err
+		}
+		n, _ := result.RowsAffected()
+		moved += n
+	}
+
+	_, err = l.db.ExecContext(l.writeCtx, fmt.Sprintf("DROP TABLE %s", legacy))
+	return moved, err
+}
+
+// swapInPartitionedTable 将普通日志表及其索引加 _legacy 后缀改名，在原名下建分区表及覆盖原有数据的分区
+func (l *DBLogger) swapInPartitionedTable(legacy string) error {
+	// 原表缺少的列先补齐，移动日志时按列名对应；列已存在的错误忽略
+	for _, stmt := range l.upgradeStmts() {
+		l.db.ExecContext(l.writeCtx, stmt)
+	}
+
+	tx, err := l.db.BeginTx(l.writeCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 索引（包括主键）名在模式内唯一，改名后分区表才能使用原来的索引名
+	rows, err := tx.QueryContext(l.writeCtx, `
+		SELECT c.relname FROM pg_index x JOIN pg_class c ON c.oid = x.indexrelid
+		WHERE x.indrelid = to_regclass($1)`, l.tableName)
+	if err != nil {
+		return err
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var first sql.NullTime
+	if err := tx.QueryRowContext(l.writeCtx, fmt.Sprintf("SELECT MIN(created_at) FROM %s", l.tableName)).Scan(&first); err != nil {
+		return err
+	}
+
+	stmts := []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", l.tableName, withLegacySuffix(l.tableName))}
+	for _, name := range indexes {
+		stmts = append(stmts, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", name, withLegacySuffix(name)))
+	}
+	stmts = append(stmts, l.createTableStmt())
+	stmts = append(stmts, l.partitionStmts(time.Now())...)
+	if first.Valid {
+		now := l.partition.Interval.truncate(time.Now())
+		for start := l.partition.Interval.truncate(convertToLocalTime(first.Time)); start.Before(now); start = l.partition.Interval.next(start) {
+			stmts = append(stmts, l.partitionStmt(start))
+		}
+	}
+	stmts = append(stmts, fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT COALESCE(MAX(id), 0) + 1 FROM %s), false)",
+		l.tableName, legacy))
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(l.writeCtx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// legacyTable 返回迁移时原表改名后的表名，保留模式名
+func (l *DBLogger) legacyTable() string {
+	name := withLegacySuffix(l.tableName)
+	if i := strings.LastIndex(l.tableName, "."); i >= 0 {
+		name = l.tableName[:i+1] + name
+	}
+	return name
+}
+
+// withLegacySuffix 为名称（去掉模式名）加 _legacy 后缀，超出 PostgreSQL 标识符长度上限（63 字节）时截断原名
+func withLegacySuffix(name string) string {
+	const suffix = "_legacy"
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+	return name + suffix
+}
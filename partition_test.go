@@ -0,0 +1,184 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPartitionedLogger 构造只用于生成分区名和语句的 DBLogger，不连接数据库
+func newPartitionedLogger(table string, interval PartitionInterval, premake int) *DBLogger {
+	return &DBLogger{tableName: table, partition: &PartitionConfig{Interval: interval, Premake: premake}}
+}
+
+func TestPartitionName(t *testing.T) {
+	setLocal(t, "America/New_York")
+	tests := []struct {
+		table    string
+		interval PartitionInterval
+		t        time.Time
+		want     string
+	}{
+		{"request_logs", PartitionDaily, time.Date(2026, 10, 18, 23, 59, 0, 0, time.Local), "request_logs_p20261018"},
+		{"request_logs", PartitionDaily, time.Date(2026, 3, 8, 3, 30, 0, 0, time.Local), "request_logs_p20260308"},
+		{"request_logs", PartitionMonthly, time.Date(2026, 1, 31, 12, 0, 0, 0, time.Local), "request_logs_p202601"},
+		{"logs.request_logs", PartitionDaily, time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local), "logs.request_logs_p20261018"},
+	}
+	for _, tt := range tests {
+		l := newPartitionedLogger(tt.table, tt.interval, 0)
+		if got := l.partitionName(tt.interval.truncate(tt.t)); got != tt.want {
+			t.Errorf("partitionName(%s, %s) = %s, want %s", tt.interval, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestPartitionStmts(t *testing.T) {
+	setLocal(t, "America/New_York")
+	tests := []struct {
+		name     string
+		interval PartitionInterval
+		premake  int
+		now      time.Time
+		want     []string
+	}{
+		{"daily", PartitionDaily, 2, time.Date(2026, 10, 18, 15, 4, 5, 0, time.Local), []string{
+			"request_logs_default PARTITION OF request_logs DEFAULT",
+			"request_logs_p20261018 PARTITION OF request_logs FOR VALUES FROM ('2026-10-18 00:00:00') TO ('2026-10-19 00:00:00')",
+			"request_logs_p20261019 PARTITION OF request_logs FOR VALUES FROM ('2026-10-19 00:00:00') TO ('2026-10-20 00:00:00')",
+			"request_logs_p20261020 PARTITION OF request_logs FOR VALUES FROM ('2026-10-20 00:00:00') TO ('2026-10-21 00:00:00')",
+		}},
+		// 夏令时开始的一天只有 23 小时，分区边界仍是本地零点
+		{"daily across DST", PartitionDaily, 1, time.Date(2026, 3, 7, 20, 0, 0, 0, time.Local), []string{
+			"request_logs_default PARTITION OF request_logs DEFAULT",
+			"request_logs_p20260307 PARTITION OF request_logs FOR VALUES FROM ('2026-03-07 00:00:00') TO ('2026-03-08 00:00:00')",
+			"request_logs_p20260308 PARTITION OF request_logs FOR VALUES FROM ('2026-03-08 00:00:00') TO ('2026-03-09 00:00:00')",
+		}},
+		{"monthly across year end", PartitionMonthly, 1, time.Date(2026, 12, 31, 23, 0, 0, 0, time.Local), []string{
+			"request_logs_default PARTITION OF request_logs DEFAULT",
+			"request_logs_p202612 PARTITION OF request_logs FOR VALUES FROM ('2026-12-01 00:00:00') TO ('2027-01-01 00:00:00')",
+			"request_logs_p202701 PARTITION OF request_logs FOR VALUES FROM ('2027-01-01 00:00:00') TO ('2027-02-01 00:00:00')",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts := newPartitionedLogger("request_logs", tt.interval, tt.premake).partitionStmts(tt.now)
+			if len(stmts) != len(tt.want) {
+				t.Fatalf("got %d statements, want %d: %q", len(stmts), len(tt.want), stmts)
+			}
+			for i, stmt := range stmts {
+				if want := "CREATE TABLE IF NOT EXISTS " + tt.want[i]; stmt != want {
+					t.Errorf("stmt %d = %q, want %q", i, stmt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePartitions(t *testing.T) {
+	setLocal(t, "UTC")
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+	tests := []struct {
+		name     string
+		table    string
+		interval PartitionInterval
+		names    []string
+		want     []logPartition
+	}{
+		{"daily sorted", "request_logs", PartitionDaily,
+			[]string{"request_logs_p20261017", "request_logs_default", "request_logs_p20261015"},
+			[]logPartition{{"request_logs_p20261015", day(15), day(16)}, {"request_logs_p20261017", day(17), day(18)}}},
+		{"skips foreign and malformed names", "request_logs", PartitionDaily,
+			[]string{"request_logs_p2026101", "request_logs_p20261399", "request_logs_p202610", "other_logs_p20261016", "request_logs_p20261016_old", "request_logs_p20261016"},
+			[]logPartition{{"request_logs_p20261016", day(16), day(17)}}},
+		{"monthly", "request_logs", PartitionMonthly,
+			[]string{"request_logs_p202611", "request_logs_p20261016", "request_logs_p202610"},
+			[]logPartition{{"request_logs_p202610", day(1), time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)},
+				{"request_logs_p202611", time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local), time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)}}},
+		// pg_class.relname 不带模式名
+		{"schema-qualified table", "logs.request_logs", PartitionDaily,
+			[]string{"request_logs_p20261018", "logs.request_logs_p20261017"},
+			[]logPartition{{"request_logs_p20261018", day(18), day(19)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newPartitionedLogger(tt.table, tt.interval, 0).parsePartitions(tt.names)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parsePartitions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithLegacySuffix(t *testing.T) {
+	long := strings.Repeat("x", 60)
+	fits := strings.Repeat("y", 63-len("_legacy"))
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"request_logs", "request_logs_legacy"},
+		{"idx_request_logs_created_at", "idx_request_logs_created_at_legacy"},
+		{"logs.request_logs", "request_logs_legacy"},
+		{fits, fits + "_legacy"},
+		{long, long[:56] + "_legacy"},
+		{"logs." + long, long[:56] + "_legacy"},
+	}
+	for _, tt := range tests {
+		got := withLegacySuffix(tt.name)
+		if got != tt.want {
+			t.Errorf("withLegacySuffix(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > 63 {
+			t.Errorf("withLegacySuffix(%q) is %d bytes, over the identifier limit", tt.name, len(got))
+		}
+	}
+
+	if got := newPartitionedLogger("logs.request_logs", PartitionDaily, 0).legacyTable(); got != "logs.request_logs_legacy" {
+		t.Errorf("legacyTable = %q, want logs.request_logs_legacy", got)
+	}
+}
+
+func TestExpiredPartitions(t *testing.T) {
+	setLocal(t, "America/New_York")
+	daily := newPartitionedLogger("request_logs", PartitionDaily, 0).parsePartitions([]string{
+		"request_logs_p20261014", "request_logs_p20261015", "request_logs_p20261016", "request_logs_p20261017", "request_logs_p20261018",
+	})
+	monthly := newPartitionedLogger("request_logs", PartitionMonthly, 0).parsePartitions([]string{
+		"request_logs_p202608", "request_logs_p202609", "request_logs_p202610",
+	})
+	names := func(partitions []logPartition) string {
+		var s []string
+		for _, p := range partitions {
+			s = append(s, p.name)
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		name       string
+		partitions []logPartition
+		now        time.Time
+		days       int
+		want       string
+	}{
+		// 截止时间所在的分区保留到整体过期
+		{"cutoff inside a partition", daily, time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local), 3, "request_logs_p20261014"},
+		{"cutoff on a boundary", daily, time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local), 3, "request_logs_p20261014"},
+		{"zero days keeps today", daily, time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local),
+			0, "request_logs_p20261014,request_logs_p20261015,request_logs_p20261016,request_logs_p20261017"},
+		{"nothing expired", daily, time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local), 10, ""},
+		{"monthly", monthly, time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local), 30, "request_logs_p202608"},
+		// AddDate 按本地日历后退，跨夏令时结束仍落在同一时刻
+		{"across DST end", daily, time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local), 18, "request_logs_p20261014,request_logs_p20261015"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 与 DeleteOldLogs 传给 dropPartitionsBefore 的截止时间相同
+			cutoff := tt.now.AddDate(0, 0, -tt.days)
+			if got := names(expiredPartitions(tt.partitions, cutoff)); got != tt.want {
+				t.Errorf("expired before %s = [%s], want [%s]", cutoff, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package reqlogmid
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开时拒绝写入返回的错误
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// RetryConfig 重试配置
+type RetryConfig struct {
+	MaxAttempts    int           // 最大尝试次数（含首次），小于等于 1 表示不重试
+	InitialBackoff time.Duration // 首次重试前等待时间
+	MaxBackoff     time.Duration // 最大等待时间
+	Multiplier     float64       // 退避倍数
+	Jitter         float64       // 抖动比例，0~1
+
+	// Retryable 判断错误是否值得重试，默认 IsTransientError
+	// 驱动有自己的连接错误类型（如 MySQL 的 ErrInvalidConn）时可在此补充
+	Retryable func(error) bool
+}
+
+// IsTransientError 判断错误是否为连接中断、网络错误或超时等暂时性错误
+// 约束冲突、值超长、语法错误等数据或语句本身的错误重试也不会成功，返回 false
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryable 按配置判断错误是否值得重试
+func (c RetryConfig) retryable(err error) bool {
+	if c.Retryable != nil {
+		return c.Retryable(err)
+	}
+	return IsTransientError(err)
+}
+
+// DefaultRetryConfig 返回默认重试配置
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff 返回第 attempt 次重试（从 1 开始）前的等待时间
+func (c RetryConfig) Backoff(attempt int) time.Duration {
+	if c.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := c.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(c.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if c.MaxBackoff > 0 && backoff >= float64(c.MaxBackoff) {
+			backoff = float64(c.MaxBackoff)
+			break
+		}
+	}
+
+	if c.Jitter > 0 {
+		backoff += backoff * c.Jitter * (rand.Float64()*2 - 1)
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	return time.Duration(backoff)
+}
+
+// Do 按重试配置执行 fn，直到成功、遇到不可重试的错误或达到最大尝试次数
+func (c RetryConfig) Do(fn func() error) error {
+	return c.DoContext(context.Background(), fn)
+}
+
+// DoContext 同 Do，ctx 结束时中断退避等待，返回最后一次的错误
+// fn 返回 ErrCircuitOpen 或 Retryable 判定不可重试的错误时立即返回
+func (c RetryConfig) DoContext(ctx context.Context, fn func() error) error {
+	attempts := c.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil || errors.Is(err, ErrCircuitOpen) || !c.retryable(err) {
+			return err
+		}
+		if attempt < attempts {
+			timer := time.NewTimer(c.Backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	// CircuitClosed 正常放行
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 熔断中，拒绝所有请求
+	CircuitOpen
+	// CircuitHalfOpen 半开，放行一次探测请求
+	CircuitHalfOpen
+)
+
+// String 返回状态名称
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 连续失败多少次后打开，默认 5
+	OpenTimeout      time.Duration // 打开后多久进入半开状态，默认 30s
+}
+
+// DefaultCircuitBreakerConfig 返回默认熔断器配置
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// CircuitBreakerStats 熔断器状态快照
+type CircuitBreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"` // 熔断器关闭时为 nil
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker 连续失败达到阈值后短路写入，避免持续冲击故障中的数据库
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	cfg      CircuitBreakerConfig
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  error
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	return &CircuitBreaker{cfg: cfg}
+}
+
+// Allow 判断是否放行本次请求
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		// 半开状态只放行一个探测请求
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success 记录一次成功，熔断器恢复为关闭状态
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure 记录一次失败，达到阈值或探测失败时打开熔断器
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastErr = err
+	b.probing = false
+	if b.state == CircuitHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// State 返回当前状态
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Stats 返回状态快照
+func (b *CircuitBreaker) Stats() CircuitBreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := CircuitBreakerStats{
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	if b.lastErr != nil {
+		stats.LastError = b.lastErr.Error()
+	}
+	return stats
+}
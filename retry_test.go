@@ -0,0 +1,87 @@
+package reqlogmid
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"wrapped bad conn", fmt.Errorf("insert: %w", driver.ErrBadConn), true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"net op error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"canceled", context.Canceled, false},
+		{"constraint", errors.New("UNIQUE constraint failed: request_logs.id"), false},
+		{"circuit open", ErrCircuitOpen, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("%s: IsTransientError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDoContext(t *testing.T) {
+	permanent := errors.New("value too long")
+	tests := []struct {
+		name     string
+		cfg      RetryConfig
+		errs     []error // 依次返回的错误，用完后返回 nil
+		want     error
+		attempts int
+	}{
+		{"success", RetryConfig{MaxAttempts: 3}, nil, nil, 1},
+		{"transient then success", RetryConfig{MaxAttempts: 3}, []error{driver.ErrBadConn, driver.ErrBadConn}, nil, 3},
+		{"transient exhausts attempts", RetryConfig{MaxAttempts: 2}, []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, driver.ErrBadConn, 2},
+		{"permanent fails fast", RetryConfig{MaxAttempts: 3}, []error{permanent}, permanent, 1},
+		{"circuit open fails fast", RetryConfig{MaxAttempts: 3}, []error{ErrCircuitOpen}, ErrCircuitOpen, 1},
+		{"custom classifier", RetryConfig{MaxAttempts: 3, Retryable: func(err error) bool { return err == permanent }}, []error{permanent}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := tt.cfg.DoContext(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) || attempts != tt.attempts {
+				t.Errorf("got %v after %d attempts, want %v after %d", err, attempts, tt.want, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryDoContextInterruptsBackoff(t *testing.T) {
+	cfg := RetryConfig{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	attempts := 0
+	err := cfg.DoContext(ctx, func() error {
+		attempts++
+		return driver.ErrBadConn
+	})
+	if !errors.Is(err, driver.ErrBadConn) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want bad conn after 1", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("backoff not interrupted, took %v", elapsed)
+	}
+}
@@ -0,0 +1,766 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RollupConfig 预聚合汇总表配置
+// 启用后后台协程按分钟、小时将日志汇总到 <表名>_rollup_1m 和 <表名>_rollup_1h（按路由、方法、状态码类别分组），
+// 耗时分位数、时间序列和按路由 / 方法的排行在时间跨度较长时读取汇总表，最近尚未汇总的部分仍读原始日志
+type RollupConfig struct {
+	Interval        time.Duration // 后台汇总间隔，默认 1 分钟
+	Lag             time.Duration // 只汇总早于当前时间减 Lag 的分钟，等待进行中的写入提交，默认 1 分钟
+	MinuteRetention time.Duration // 分钟汇总保留时长，更早的只保留小时汇总，默认 7 天，负数表示不清理
+	MinQueryRange   time.Duration // 时间跨度不小于此值（或不限开始时间）的统计查询才读汇总表，默认 1 小时
+	ReadOnly        bool          // 只读取汇总表、不运行后台汇总，多实例部署时由其他实例汇总
+}
+
+// rollupLevel 汇总粒度
+type rollupLevel struct {
+	suffix   string         // 表名后缀
+	interval SeriesInterval // 分桶粒度
+	chunk    time.Duration  // 单个事务汇总的时间跨度
+}
+
+var (
+	rollupMinute = rollupLevel{suffix: "_rollup_1m", interval: SeriesMinute, chunk: time.Hour}
+	rollupHour   = rollupLevel{suffix: "_rollup_1h", interval: SeriesHour, chunk: 24 * time.Hour}
+)
+
+// rollupColumns 汇总表的列，与 queryRollupRows 扫描的顺序一致（bucket_start 以 MinuteKey 文本读取）
+const rollupColumns = "route, method, status_class, request_count, error_count, duration_sum, duration_max, duration_sketch"
+
+// statusClassExpr 状态码类别表达式，1 到 5，其他状态码为 0
+var statusClassExpr = func() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for class := 5; class >= 1; class-- {
+		fmt.Fprintf(&b, " WHEN status_code >= %d AND status_code < %d THEN %d", class*100, class*100+100, class)
+	}
+	b.WriteString(" ELSE 0 END")
+	return b.String()
+}()
+
+// latencySketch 耗时分布，各桶为 approxPercentileBuckets 对应区间的（非累计）计数，最后一项为超出最大上界的计数
+// 可直接相加合并，存储为稀疏文本 "下标:计数,..."
+type latencySketch []uint64
+
+func newLatencySketch() latencySketch {
+	return make(latencySketch, len(approxPercentileBuckets)+1)
+}
+
+// sketchFromCumulative 由累计直方图和总数生成
+func sketchFromCumulative(cum []uint64, count int64) latencySketch {
+	s := newLatencySketch()
+	var prev uint64
+	for i, n := range cum {
+		s[i] = n - prev
+		prev = n
+	}
+	s[len(cum)] = uint64(count) - prev
+	return s
+}
+
+// merge 累加另一个分布
+func (s latencySketch) merge(o latencySketch) {
+	for i := range o {
+		s[i] += o[i]
+	}
+}
+
+// cumulative 返回 approxPercentileBuckets 上的累计计数
+func (s latencySketch) cumulative() []uint64 {
+	cum := make([]uint64, len(approxPercentileBuckets))
+	var total uint64
+	for i := range cum {
+		total += s[i]
+		cum[i] = total
+	}
+	return cum
+}
+
+// encode 编码为稀疏文本
+func (s latencySketch) encode() string {
+	var parts []string
+	for i, n := range s {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d:%d", i, n))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseLatencySketch 解析 encode 生成的文本
+func parseLatencySketch(text string) (latencySketch, error) {
+	s := newLatencySketch()
+	if text == "" {
+		return s, nil
+	}
+	for _, part := range strings.Split(text, ",") {
+		idx, count, ok := strings.Cut(part, ":")
+		i, err1 := strconv.Atoi(idx)
+		n, err2 := strconv.ParseUint(count, 10, 64)
+		if !ok || err1 != nil || err2 != nil || i < 0 || i >= len(s) {
+			return nil, fmt.Errorf("invalid latency sketch %q", text)
+		}
+		s[i] = n
+	}
+	return s, nil
+}
+
+// rollupRow 汇总表中的一行，也用于按分钟聚合的原始日志
+type rollupRow struct {
+	bucket      time.Time
+	route       string
+	method      string
+	statusClass int
+	count       int64
+	errors      int64
+	sum         float64
+	max         float64
+	sketch      latencySketch
+}
+
+// merge 累加另一行的计数和耗时
+func (r *rollupRow) merge(o *rollupRow) {
+	r.count += o.count
+	r.errors += o.errors
+	r.sum += o.sum
+	if o.max > r.max {
+		r.max = o.max
+	}
+	r.sketch.merge(o.sketch)
+}
+
+// percentile 由耗时分布估算分位数
+func (r *rollupRow) percentile(q float64) float64 {
+	snap := HistogramSnapshot{Buckets: approxPercentileBuckets, Counts: r.sketch.cumulative(), Count: uint64(r.count), Sum: r.sum}
+	return percentileFromHistogram(snap, q, r.max)
+}
+
+// latency 返回估算的耗时分位数
+func (r *rollupRow) latency() LatencyPercentiles {
+	p := LatencyPercentiles{Count: r.count, Max: r.max, Approximate: true}
+	if r.count == 0 {
+		return p
+	}
+	p.Avg = r.sum / float64(r.count)
+	p.P50 = r.percentile(0.5)
+	p.P90 = r.percentile(0.9)
+	p.P95 = r.percentile(0.95)
+	p.P99 = r.percentile(0.99)
+	return p
+}
+
+// seriesBucket 转换为时间序列的分桶
+func (r *rollupRow) seriesBucket() *seriesBucket {
+	b := &seriesBucket{count: r.count, errors: r.errors, sum: r.sum, max: r.max, hist: r.sketch.cumulative()}
+	if r.statusClass >= 1 && r.statusClass <= 5 {
+		b.classes[r.statusClass-1] = r.count
+	}
+	return b
+}
+
+// mergeRollupRows 将行合并到 interval 粒度的分桶
+func mergeRollupRows(rows []rollupRow, interval SeriesInterval) []rollupRow {
+	type key struct {
+		bucket        time.Time
+		route, method string
+		statusClass   int
+	}
+	index := make(map[key]int)
+	var merged []rollupRow
+	for i := range rows {
+		r := &rows[i]
+		k := key{interval.Truncate(r.bucket, time.Local), r.route, r.method, r.statusClass}
+		j, ok := index[k]
+		if !ok {
+			j = len(merged)
+			index[k] = j
+			merged = append(merged, rollupRow{bucket: k.bucket, route: k.route, method: k.method, statusClass: k.statusClass, sketch: newLatencySketch()})
+		}
+		merged[j].merge(r)
+	}
+	return merged
+}
+
+// rollupEligible 判断过滤条件是否只涉及汇总表保留的维度（方法、路由、状态码类别）和时间范围
+func rollupEligible(f LogFilter) bool {
+	return f.PathPrefix == "" && f.PathContains == "" && f.PathRegex == "" &&
+		len(f.StatusCodes) == 0 && f.MinStatus == 0 && f.MaxStatus == 0 &&
+		f.MinDuration == 0 && f.MaxDuration == 0 &&
+		len(f.ClientIPs) == 0 && f.ClientCIDR == "" &&
+		len(f.Fields) == 0 && f.Query == ""
+}
+
+// ceilInterval 返回不早于 t 的第一个分桶起点
+func ceilInterval(i SeriesInterval, t time.Time) time.Time {
+	start := i.Truncate(t, time.Local)
+	if start.Before(t) {
+		return i.next(start)
+	}
+	return start
+}
+
+// EnableRollups 启用预聚合汇总表，需在 CreateTable 之前调用以同时建表
+// 非只读模式下启动后台汇总协程，首次运行时从已有汇总的末尾（或最早的日志）开始补齐
+func (l *DBLogger) EnableRollups(cfg RollupConfig) error {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.Lag <= 0 {
+		cfg.Lag = time.Minute
+	}
+	if cfg.MinuteRetention == 0 {
+		cfg.MinuteRetention = 7 * 24 * time.Hour
+	}
+	if cfg.MinQueryRange <= 0 {
+		cfg.MinQueryRange = time.Hour
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rollup != nil {
+		return fmt.Errorf("rollups already enabled")
+	}
+	l.rollup = &cfg
+	if !cfg.ReadOnly {
+		l.rollupQuit = make(chan struct{})
+		l.startRollupAggregator(cfg)
+	}
+	return nil
+}
+
+// rollupConfig 返回汇总配置，未启用时为 nil
+func (l *DBLogger) rollupConfig() *RollupConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.rollup
+}
+
+// noteRollupWrite 写入的日志早于后台汇总进度时（暂存回放、客户端补报的历史请求或提交晚于 Lag 的写入）标记回退
+// 进度只在本实例运行后台汇总时可知，ReadOnly 实例写入的历史日志要等汇总实例重新汇总该时段才计入
+func (l *DBLogger) noteRollupWrite(t time.Time) {
+	if aggregated := atomic.LoadInt64(&l.rollupAggregated); aggregated != 0 && t.UnixNano() < aggregated {
+		l.markRollupRewind(t)
+	}
+}
+
+// markRollupRewind 记录写入了请求时间为 t 的历史日志，下次后台汇总时回退进度重新汇总该时段
+func (l *DBLogger) markRollupRewind(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	n := t.UnixNano()
+	for {
+		old := atomic.LoadInt64(&l.rollupRewind)
+		if old != 0 && old <= n {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&l.rollupRewind, old, n) {
+			return
+		}
+	}
+}
+
+// rollupTable 返回汇总表名
+func (l *DBLogger) rollupTable(level rollupLevel) string {
+	return l.tableName + level.suffix
+}
+
+// createRollupStmts 返回汇总表建表语句
+func (l *DBLogger) createRollupStmts() []string {
+	var stmts []string
+	for _, level := range []rollupLevel{rollupMinute, rollupHour} {
+		stmts = append(stmts, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			bucket_start %s NOT NULL,
+			route VARCHAR(255) NOT NULL,
+			method VARCHAR(10) NOT NULL,
+			status_class INT NOT NULL,
+			request_count BIGINT NOT NULL,
+			error_count BIGINT NOT NULL,
+			duration_sum DOUBLE PRECISION NOT NULL,
+			duration_max DOUBLE PRECISION NOT NULL,
+			duration_sketch TEXT NOT NULL,
+			PRIMARY KEY (bucket_start, route, method, status_class)
+		)`, l.rollupTable(level), l.dialect.TimestampType()))
+	}
+	return stmts
+}
+
+// rollupMarks 后台汇总进度：早于 minute 的分钟和早于 hour 的小时已汇总
+type rollupMarks struct {
+	minute, hour time.Time
+}
+
+// rewind 将进度回退到 t 所在的分钟，使之后写入的历史日志被重新汇总
+// 分钟汇总已清理的时段无法重算小时汇总，最早只回退到分钟汇总保留范围内的第一个整点
+func (m *rollupMarks) rewind(cfg RollupConfig, t, now time.Time) {
+	from := SeriesMinute.Truncate(t, time.Local)
+	if cfg.MinuteRetention > 0 {
+		if earliest := ceilInterval(SeriesHour, now.Add(-cfg.MinuteRetention)); from.Before(earliest) {
+			from = earliest
+		}
+	}
+	if from.Before(m.minute) {
+		m.minute = from
+	}
+	if hour := SeriesHour.Truncate(m.minute, time.Local); hour.Before(m.hour) {
+		m.hour = hour
+	}
+}
+
+// startRollupAggregator 启动后台汇总协程，首次汇总在一个间隔之后，留出 CreateTable 建表的时间
+func (l *DBLogger) startRollupAggregator(cfg RollupConfig) {
+	l.rollupWg.Add(1)
+	go func() {
+		defer l.rollupWg.Done()
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		var marks rollupMarks
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.aggregateRollups(cfg, &marks); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to aggregate rollups: %v\n", err)
+				}
+			case <-l.rollupQuit:
+				return
+			}
+		}
+	}()
+}
+
+// aggregateRollups 将新完成的分钟汇总到分钟表、新完成的小时汇总到小时表，并清理过期的分钟汇总
+// 每个时间段在一个事务中先删除再写入，重复执行（包括多个实例同时执行）结果不变
+func (l *DBLogger) aggregateRollups(cfg RollupConfig, marks *rollupMarks) error {
+	if marks.minute.IsZero() {
+		resumed, err := l.resumeRollupMarks(cfg)
+		if err != nil {
+			return err
+		}
+		*marks = resumed
+		l.advanceRollupAggregated(marks.minute)
+	}
+	if rewind := atomic.SwapInt64(&l.rollupRewind, 0); rewind != 0 {
+		marks.rewind(cfg, time.Unix(0, rewind), time.Now())
+	}
+
+	minuteUpto := rollupMinute.interval.Truncate(time.Now().Add(-cfg.Lag), time.Local)
+	for marks.minute.Before(minuteUpto) {
+		to := minTime(marks.minute.Add(rollupMinute.chunk), minuteUpto)
+		// 先公布进度再读原始日志：读取之后提交的写入一定能看到新进度并标记回退
+		l.advanceRollupAggregated(to)
+		rows, err := l.aggregateRaw(LogFilter{StartTime: marks.minute}, to)
+		if err != nil {
+			return err
+		}
+		if err := l.replaceRollups(rollupMinute, marks.minute, to, rows); err != nil {
+			return err
+		}
+		marks.minute = to
+		if l.rollupStopping() {
+			return nil
+		}
+	}
+
+	hourUpto := rollupHour.interval.Truncate(marks.minute, time.Local)
+	for marks.hour.Before(hourUpto) {
+		to := minTime(marks.hour.Add(rollupHour.chunk), hourUpto)
+		rows, err := l.queryRollupRows(rollupMinute, LogFilter{}, marks.hour, to)
+		if err != nil {
+			return err
+		}
+		if err := l.replaceRollups(rollupHour, marks.hour, to, mergeRollupRows(rows, rollupHour.interval)); err != nil {
+			return err
+		}
+		marks.hour = to
+		if l.rollupStopping() {
+			return nil
+		}
+	}
+
+	if cfg.MinuteRetention > 0 {
+		// 尚未汇总到小时表的分钟不清理
+		cutoff := SeriesHour.Truncate(minTime(time.Now().Add(-cfg.MinuteRetention), marks.hour), time.Local)
+		query := fmt.Sprintf("DELETE FROM %s WHERE bucket_start < ?", l.rollupTable(rollupMinute))
+		if _, err := l.db.ExecContext(l.writeCtx, Rebind(l.dialect, query), cutoff.Format(filterTimeFormat)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advanceRollupAggregated 将已读取原始日志的进度推进到 t，回退后重新汇总时不后退
+func (l *DBLogger) advanceRollupAggregated(t time.Time) {
+	n := t.UnixNano()
+	for {
+		old := atomic.LoadInt64(&l.rollupAggregated)
+		if old >= n || atomic.CompareAndSwapInt64(&l.rollupAggregated, old, n) {
+			return
+		}
+	}
+}
+
+// rollupStopping 判断是否正在关闭
+func (l *DBLogger) rollupStopping() bool {
+	select {
+	case <-l.rollupQuit:
+		return true
+	default:
+		return false
+	}
+}
+
+// resumeRollupMarks 由已有汇总推断进度：从最后一个已汇总分桶之后继续，没有汇总时从最早的日志开始
+func (l *DBLogger) resumeRollupMarks(cfg RollupConfig) (rollupMarks, error) {
+	cov, err := l.rollupCoverage()
+	if err != nil {
+		return rollupMarks{}, err
+	}
+
+	var marks rollupMarks
+	switch {
+	case !cov.minuteEnd.IsZero() || !cov.hourEnd.IsZero():
+		marks.minute = cov.minuteEnd
+		if cov.hourEnd.After(marks.minute) {
+			marks.minute = cov.hourEnd
+		}
+	default:
+		var first sql.NullString
+		query := fmt.Sprintf("SELECT %s FROM %s", l.dialect.MinuteKey("MIN(created_at)"), l.tableName)
+		if err := l.db.QueryRow(query).Scan(&first); err != nil {
+			return rollupMarks{}, err
+		}
+		if first.Valid {
+			if marks.minute, err = parseMinuteKey(first.String); err != nil {
+				return rollupMarks{}, err
+			}
+		} else {
+			marks.minute = SeriesMinute.Truncate(time.Now().Add(-cfg.Lag), time.Local)
+		}
+	}
+
+	switch {
+	case !cov.hourEnd.IsZero():
+		marks.hour = cov.hourEnd
+	case !cov.minuteStart.IsZero():
+		marks.hour = SeriesHour.Truncate(cov.minuteStart, time.Local)
+	default:
+		marks.hour = SeriesHour.Truncate(marks.minute, time.Local)
+	}
+	return marks, nil
+}
+
+// rollupCoverage 汇总表覆盖的时间范围
+// 分钟表覆盖 [minuteStart, minuteEnd)，小时表覆盖 hourEnd 之前；汇总按时间顺序写入，末尾之后没有流量的分钟读原始日志结果相同
+type rollupCoverage struct {
+	minuteStart, minuteEnd, hourEnd time.Time
+}
+
+// rollupCoverage 查询汇总表的首尾分桶
+func (l *DBLogger) rollupCoverage() (rollupCoverage, error) {
+	var cov rollupCoverage
+	var first, last, lastHour sql.NullString
+	query := fmt.Sprintf("SELECT %s, %s FROM %s",
+		l.dialect.MinuteKey("MIN(bucket_start)"), l.dialect.MinuteKey("MAX(bucket_start)"), l.rollupTable(rollupMinute))
+	if err := l.db.QueryRow(query).Scan(&first, &last); err != nil {
+		return cov, err
+	}
+	query = fmt.Sprintf("SELECT %s FROM %s", l.dialect.MinuteKey("MAX(bucket_start)"), l.rollupTable(rollupHour))
+	if err := l.db.QueryRow(query).Scan(&lastHour); err != nil {
+		return cov, err
+	}
+
+	var err error
+	if first.Valid {
+		if cov.minuteStart, err = parseMinuteKey(first.String); err != nil {
+			return cov, err
+		}
+		if cov.minuteEnd, err = parseMinuteKey(last.String); err != nil {
+			return cov, err
+		}
+		cov.minuteEnd = SeriesMinute.next(cov.minuteEnd)
+	}
+	if lastHour.Valid {
+		if cov.hourEnd, err = parseMinuteKey(lastHour.String); err != nil {
+			return cov, err
+		}
+		cov.hourEnd = SeriesHour.next(cov.hourEnd)
+	}
+	return cov, nil
+}
+
+// aggregateRaw 按分钟、路由、方法、状态码类别聚合原始日志，before 不为零时只取早于 before 的日志
+func (l *DBLogger) aggregateRaw(filter LogFilter, before time.Time) ([]rollupRow, error) {
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return nil, err
+	}
+	if !before.IsZero() {
+		if where == "" {
+			where = " WHERE created_at < ?"
+		} else {
+			where += " AND created_at < ?"
+		}
+		args = append(args, before.In(time.Local).Format(filterTimeFormat))
+	}
+
+	keys := []string{l.dialect.MinuteKey("created_at"), "COALESCE(route, '')", "method", statusClassExpr}
+	cols := append([]string{}, keys...)
+	cols = append(cols,
+		"COUNT(*)",
+		"SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END)",
+		"COALESCE(SUM(duration_ms), 0)",
+		"COALESCE(MAX(duration_ms), 0)",
+	)
+	bucketArgs := make([]interface{}, len(approxPercentileBuckets))
+	for i, b := range approxPercentileBuckets {
+		cols = append(cols, "SUM(CASE WHEN duration_ms <= ? THEN 1 ELSE 0 END)")
+		bucketArgs[i] = b
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s GROUP BY %s",
+		strings.Join(cols, ", "), l.tableName, where, strings.Join(keys, ", "))
+	rows, err := l.db.Query(Rebind(l.dialect, query), append(bucketArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []rollupRow
+	for rows.Next() {
+		var minute sql.NullString
+		var r rollupRow
+		hist := make([]uint64, len(approxPercentileBuckets))
+		dest := []interface{}{&minute, &r.route, &r.method, &r.statusClass, &r.count, &r.errors, &r.sum, &r.max}
+		for i := range hist {
+			dest = append(dest, &hist[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if r.bucket, err = parseMinuteKey(minute.String); err != nil {
+			return nil, err
+		}
+		r.sketch = sketchFromCumulative(hist, r.count)
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// queryRollupRows 读取汇总表中 [from, to) 内满足条件的行，from 为零表示不限
+// 过滤条件只使用方法、路由和状态码类别
+func (l *DBLogger) queryRollupRows(level rollupLevel, filter LogFilter, from, to time.Time) ([]rollupRow, error) {
+	var conds []string
+	var args []interface{}
+	if len(filter.Methods) > 0 {
+		conds = append(conds, fmt.Sprintf("method IN (%s)", placeholders(len(filter.Methods))))
+		args = append(args, stringArgs(filter.Methods)...)
+	}
+	if len(filter.Routes) > 0 {
+		conds = append(conds, fmt.Sprintf("route IN (%s)", placeholders(len(filter.Routes))))
+		args = append(args, stringArgs(filter.Routes)...)
+	}
+	if len(filter.StatusClasses) > 0 {
+		conds = append(conds, fmt.Sprintf("status_class IN (%s)", placeholders(len(filter.StatusClasses))))
+		for _, class := range filter.StatusClasses {
+			args = append(args, class)
+		}
+	}
+	if !from.IsZero() {
+		conds = append(conds, "bucket_start >= ?")
+		args = append(args, from.In(time.Local).Format(filterTimeFormat))
+	}
+	conds = append(conds, "bucket_start < ?")
+	args = append(args, to.In(time.Local).Format(filterTimeFormat))
+
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s",
+		l.dialect.MinuteKey("bucket_start"), rollupColumns, l.rollupTable(level), strings.Join(conds, " AND "))
+	rows, err := l.db.Query(Rebind(l.dialect, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []rollupRow
+	for rows.Next() {
+		var minute, sketch string
+		var r rollupRow
+		if err := rows.Scan(&minute, &r.route, &r.method, &r.statusClass, &r.count, &r.errors, &r.sum, &r.max, &sketch); err != nil {
+			return nil, err
+		}
+		if r.bucket, err = parseMinuteKey(minute); err != nil {
+			return nil, err
+		}
+		if r.sketch, err = parseLatencySketch(sketch); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// replaceRollups 在一个事务中用 rows 替换汇总表 [from, to) 内的行
+func (l *DBLogger) replaceRollups(level rollupLevel, from, to time.Time, rows []rollupRow) error {
+	table := l.rollupTable(level)
+	tx, err := l.db.BeginTx(l.writeCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE bucket_start >= ? AND bucket_start < ?", table)
+	if _, err := tx.Exec(Rebind(l.dialect, query), from.In(time.Local).Format(filterTimeFormat), to.In(time.Local).Format(filterTimeFormat)); err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		query = fmt.Sprintf("INSERT INTO %s (bucket_start, %s) VALUES (%s)", table, rollupColumns, placeholders(9))
+		stmt, err := tx.Prepare(Rebind(l.dialect, query))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for i := range rows {
+			r := &rows[i]
+			if _, err := stmt.Exec(r.bucket.In(time.Local).Format(filterTimeFormat), r.route, r.method, r.statusClass,
+				r.count, r.errors, r.sum, r.max, r.sketch.encode()); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// rollupRows 组合小时汇总、分钟汇总和原始日志，返回满足条件的聚合行
+// 未启用汇总、过滤条件涉及汇总表没有的维度或时间跨度小于 MinQueryRange 时 ok 为 false，调用方直接查询原始日志
+// 两端不足一分钟的部分、分钟汇总已清理的部分和尚未汇总的最近数据读原始日志；hourOK 为 false 时不使用小时汇总
+func (l *DBLogger) rollupRows(filter LogFilter, hourOK bool) (rows []rollupRow, ok bool, err error) {
+	cfg := l.rollupConfig()
+	if cfg == nil || !rollupEligible(filter) {
+		return nil, false, nil
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, false, err
+	}
+	start, end := filter.StartTime, filter.EndTime
+	if !start.IsZero() {
+		until := end
+		if until.IsZero() {
+			until = time.Now()
+		}
+		if until.Sub(start) < cfg.MinQueryRange {
+			return nil, false, nil
+		}
+	}
+
+	cov, err := l.rollupCoverage()
+	if err != nil {
+		return nil, false, err
+	}
+	if cov.minuteEnd.IsZero() {
+		return nil, false, nil
+	}
+
+	// 汇总覆盖 [lo, hi)，零值 lo 表示不限
+	lo := start
+	if !lo.IsZero() {
+		lo = ceilInterval(SeriesMinute, start)
+	}
+	hi := cov.minuteEnd
+	if !end.IsZero() {
+		hi = minTime(hi, SeriesMinute.Truncate(end, time.Local))
+	}
+	if !lo.Before(hi) {
+		return nil, false, nil
+	}
+
+	add := func(r []rollupRow, err error) error {
+		rows = append(rows, r...)
+		return err
+	}
+	raw := func(from, before time.Time) error {
+		f := filter
+		f.StartTime, f.EndTime = from, time.Time{}
+		return add(l.aggregateRaw(f, before))
+	}
+
+	type span struct{ from, to time.Time }
+	minutes := []span{{lo, hi}}
+	if hourOK && !cov.hourEnd.IsZero() {
+		hlo := lo
+		if !hlo.IsZero() {
+			hlo = ceilInterval(SeriesHour, lo)
+		}
+		hhi := minTime(SeriesHour.Truncate(hi, time.Local), cov.hourEnd)
+		if hlo.Before(hhi) {
+			if err := add(l.queryRollupRows(rollupHour, filter, hlo, hhi)); err != nil {
+				return nil, false, err
+			}
+			minutes = []span{{lo, hlo}, {hhi, hi}}
+		}
+	}
+	for _, s := range minutes {
+		if !s.from.Before(s.to) {
+			continue
+		}
+		// 分钟汇总开始之前（已清理或尚无流量）读原始日志
+		if s.from.Before(cov.minuteStart) {
+			if err := raw(s.from, minTime(s.to, cov.minuteStart)); err != nil {
+				return nil, false, err
+			}
+			if !cov.minuteStart.Before(s.to) {
+				continue
+			}
+			s.from = cov.minuteStart
+		}
+		if err := add(l.queryRollupRows(rollupMinute, filter, s.from, s.to)); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if !start.IsZero() && start.Before(lo) {
+		if err := raw(start, lo); err != nil {
+			return nil, false, err
+		}
+	}
+	f := filter
+	f.StartTime = hi
+	if err := add(l.aggregateRaw(f, time.Time{})); err != nil {
+		return nil, false, err
+	}
+	return rows, true, nil
+}
+
+// hourAligned 判断 loc 的整点在时间范围内是否与本地时区的整点一致，一致时小时汇总可合并到 loc 的小时和天
+func hourAligned(loc *time.Location, start, end time.Time) bool {
+	if loc == nil {
+		return true
+	}
+	for _, t := range []time.Time{start, end, time.Now()} {
+		if t.IsZero() {
+			continue
+		}
+		_, target := t.In(loc).Zone()
+		_, local := t.In(time.Local).Zone()
+		if (target-local)%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,202 @@
+package reqlogmid
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRollupMarksRewind(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.Local)
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, time.Local) }
+	cfg := RollupConfig{MinuteRetention: 7 * 24 * time.Hour}
+
+	tests := []struct {
+		name       string
+		cfg        RollupConfig
+		t          time.Time
+		wantMinute time.Time
+		wantHour   time.Time
+	}{
+		{"not yet aggregated", cfg, at(10, 12, 20), at(10, 12, 0), at(10, 12, 0)},
+		{"same hour", cfg, at(10, 12, 5), at(10, 12, 0), at(10, 12, 0)},
+		{"earlier hour", cfg, at(10, 9, 41), at(10, 9, 41), at(10, 9, 0)},
+		{"clamped to minute retention", cfg, at(1, 8, 15), at(3, 13, 0), at(3, 13, 0)},
+		{"no retention", RollupConfig{MinuteRetention: -1}, at(1, 8, 15), at(1, 8, 15), at(1, 8, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marks := rollupMarks{minute: at(10, 12, 0), hour: at(10, 12, 0)}
+			marks.rewind(tt.cfg, tt.t.Add(17*time.Second), now)
+			if !marks.minute.Equal(tt.wantMinute) || !marks.hour.Equal(tt.wantHour) {
+				t.Errorf("marks = %v / %v, want %v / %v", marks.minute, marks.hour, tt.wantMinute, tt.wantHour)
+			}
+		})
+	}
+}
+
+func TestRollupsIncludeLateEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(l *DBLogger, e *LogEntry) error
+	}{
+		// 暂存回放写入的历史日志
+		{"replay", func(l *DBLogger, e *LogEntry) error { return l.replayEntry(e) }},
+		// 直接写入的历史日志，如客户端补报的请求
+		{"write", func(l *DBLogger, e *LogEntry) error { return l.Write(e) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestDBLogger(t)
+			cfg := RollupConfig{Interval: time.Hour, ReadOnly: true}
+			if err := l.EnableRollups(cfg); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.CreateTable(); err != nil {
+				t.Fatal(err)
+			}
+			cfg = *l.rollupConfig()
+
+			past := time.Now().Add(-3 * time.Hour).Truncate(time.Minute)
+			if err := l.Write(&LogEntry{Method: "GET", Path: "/", Route: "/", StatusCode: 200, Duration: 5, RequestTime: past}); err != nil {
+				t.Fatal(err)
+			}
+
+			countRollups := func(level rollupLevel) int64 {
+				t.Helper()
+				rows, err := l.queryRollupRows(level, LogFilter{}, time.Time{}, time.Now().Add(time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var n int64
+				for _, r := range rows {
+					n += r.count
+				}
+				return n
+			}
+
+			var marks rollupMarks
+			if err := l.aggregateRollups(cfg, &marks); err != nil {
+				t.Fatalf("aggregateRollups: %v", err)
+			}
+			if got := countRollups(rollupMinute); got != 1 {
+				t.Fatalf("minute rollups count %d requests, want 1", got)
+			}
+
+			// 历史日志落在已汇总的时段
+			late := &LogEntry{Method: "GET", Path: "/", Route: "/", StatusCode: 500, Duration: 7, RequestTime: past.Add(30 * time.Second)}
+			if err := tt.write(l, late); err != nil {
+				t.Fatal(err)
+			}
+			if err := l.aggregateRollups(cfg, &marks); err != nil {
+				t.Fatalf("aggregateRollups: %v", err)
+			}
+			if got := countRollups(rollupMinute); got != 2 {
+				t.Errorf("minute rollups count %d requests after late write, want 2", got)
+			}
+			if got := countRollups(rollupHour); got != 2 {
+				t.Errorf("hour rollups count %d requests after late write, want 2", got)
+			}
+		})
+	}
+}
+
+func TestDBLoggerGetStatsFromRollups(t *testing.T) {
+	now := time.Now()
+	times := []time.Time{
+		now.Add(-50 * time.Hour),
+		now.Add(-26 * time.Hour),
+		now.Add(-3 * time.Hour),
+		now.Add(-2 * time.Hour),
+		now.Add(-10 * time.Minute),
+		now,
+	}
+	midnight := SeriesDay.Truncate(now, time.Local)
+	var wantToday int64
+	for _, at := range times {
+		if !at.Before(midnight) {
+			wantToday++
+		}
+	}
+
+	raw := newTestDBLogger(t)
+	l := newTestDBLogger(t)
+	cfg := RollupConfig{Interval: time.Hour, ReadOnly: true}
+	if err := l.EnableRollups(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	cfg = *l.rollupConfig()
+	for i, at := range times {
+		status := 200
+		if i%3 == 0 {
+			status = 502
+		}
+		for _, s := range []*DBLogger{raw, l} {
+			if err := s.Write(&LogEntry{Method: "GET", Path: "/", Route: "/", StatusCode: status, Duration: float64(10 * (i + 1)), RequestTime: at}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var marks rollupMarks
+	if err := l.aggregateRollups(cfg, &marks); err != nil {
+		t.Fatalf("aggregateRollups: %v", err)
+	}
+
+	// 汇总加尚未汇总的原始日志与只读原始日志的结果一致
+	type stats struct {
+		today, total   int64
+		avg, errorRate float64
+	}
+	getStats := func(l *DBLogger) stats {
+		t.Helper()
+		var s stats
+		var err error
+		if s.today, s.total, s.avg, s.errorRate, err = l.GetStats(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	want := stats{wantToday, 6, 35, 100.0 * 2 / 6}
+	if got := getStats(raw); got != want {
+		t.Errorf("raw GetStats = %+v, want %+v", got, want)
+	}
+	if got := getStats(l); got != want {
+		t.Errorf("rollup GetStats = %+v, want %+v", got, want)
+	}
+
+	// 删除已汇总时段中的一条原始日志，总数仍读汇总说明没有扫描原始表
+	if _, err := l.DB().Exec("DELETE FROM request_logs WHERE duration_ms = 30"); err != nil {
+		t.Fatal(err)
+	}
+	if got := getStats(l); got.total != 6 {
+		t.Errorf("total after deleting an aggregated raw row = %d, want 6 from rollups", got.total)
+	}
+
+	// 早于最早原始日志的汇总不计入，与 DeleteOldLogs 的结果一致
+	if _, err := l.DeleteOldLogs(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := getStats(l); got.total != 5 {
+		t.Errorf("total after DeleteOldLogs = %d, want 5", got.total)
+	}
+}
+
+func TestEnableRollupsConcurrentWithQueries(t *testing.T) {
+	l := newTestDBLogger(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// 汇总表尚未创建时查询可能报错，这里只关心读取配置没有数据竞争
+		for i := 0; i < 20; i++ {
+			l.LatencyPercentiles(LogFilter{})
+		}
+	}()
+	if err := l.EnableRollups(RollupConfig{ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,316 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SeriesInterval 时间序列的分桶粒度
+type SeriesInterval string
+
+const (
+	// SeriesMinute 按分钟分桶
+	SeriesMinute SeriesInterval = "minute"
+	// SeriesHour 按小时分桶
+	SeriesHour SeriesInterval = "hour"
+	// SeriesDay 按天分桶（请求时区的零点）
+	SeriesDay SeriesInterval = "day"
+)
+
+// MaxSeriesPoints 单次时间序列查询允许的最大分桶数
+const MaxSeriesPoints = 2000
+
+// ErrSeriesRangeTooLarge 时间范围按所选粒度分桶后超过 MaxSeriesPoints
+var ErrSeriesRangeTooLarge = errors.New("time range too large for series interval")
+
+// Validate 校验分桶粒度
+func (i SeriesInterval) Validate() error {
+	switch i {
+	case SeriesMinute, SeriesHour, SeriesDay:
+		return nil
+	}
+	return fmt.Errorf("unsupported series interval %q", i)
+}
+
+// Truncate 返回 t 在 loc 时区下所在分桶的起点
+// 按日历字段截断，半小时时区和夏令时切换日也能对齐到当地整点、零点
+func (i SeriesInterval) Truncate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch i {
+	case SeriesMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case SeriesHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next 返回下一个分桶的起点
+func (i SeriesInterval) next(t time.Time) time.Time {
+	switch i {
+	case SeriesMinute:
+		return t.Add(time.Minute)
+	case SeriesHour:
+		return t.Add(time.Hour)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// TimeSeriesPoint 时间序列中的一个分桶
+type TimeSeriesPoint struct {
+	Time          time.Time          `json:"time"`           // 分桶起点（请求的时区）
+	Count         int64              `json:"count"`          // 请求数
+	Errors        int64              `json:"errors"`         // 状态码 >= 400 的请求数
+	StatusClasses map[string]int64   `json:"status_classes"` // 按状态码类别计数：1xx 到 5xx
+	Latency       LatencyPercentiles `json:"latency"`        // 耗时分位数
+}
+
+// seriesBucket 聚合中的分桶，耗时以累计直方图保存，便于合并
+type seriesBucket struct {
+	count, errors int64
+	classes       [5]int64
+	sum, max      float64
+	hist          []uint64
+	durations     []float64 // 内存存储保留原始耗时以精确计算
+}
+
+// seriesAccumulator 将按分钟（或逐条）的聚合结果合并到目标粒度的分桶
+type seriesAccumulator struct {
+	interval SeriesInterval
+	loc      *time.Location
+	buckets  map[time.Time]*seriesBucket
+}
+
+func newSeriesAccumulator(interval SeriesInterval, loc *time.Location) *seriesAccumulator {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &seriesAccumulator{interval: interval, loc: loc, buckets: make(map[time.Time]*seriesBucket)}
+}
+
+func (a *seriesAccumulator) bucket(t time.Time) *seriesBucket {
+	key := a.interval.Truncate(t, a.loc)
+	b, ok := a.buckets[key]
+	if !ok {
+		b = &seriesBucket{}
+		a.buckets[key] = b
+	}
+	return b
+}
+
+// addEntry 累加一条日志
+func (a *seriesAccumulator) addEntry(e *DBLogEntry) {
+	b := a.bucket(e.CreatedAt)
+	b.count++
+	if e.StatusCode >= 400 {
+		b.errors++
+	}
+	if class := e.StatusCode / 100; class >= 1 && class <= 5 {
+		b.classes[class-1]++
+	}
+	b.sum += e.Duration
+	if e.Duration > b.max {
+		b.max = e.Duration
+	}
+	b.durations = append(b.durations, e.Duration)
+}
+
+// merge 合并一个预聚合的分桶（如数据库按分钟聚合的一行）
+func (a *seriesAccumulator) merge(t time.Time, src *seriesBucket) {
+	b := a.bucket(t)
+	b.count += src.count
+	b.errors += src.errors
+	for i := range b.classes {
+		b.classes[i] += src.classes[i]
+	}
+	b.sum += src.sum
+	if src.max > b.max {
+		b.max = src.max
+	}
+	if b.hist == nil {
+		b.hist = make([]uint64, len(src.hist))
+	}
+	for i := range src.hist {
+		b.hist[i] += src.hist[i]
+	}
+}
+
+// points 生成按时间升序的结果，start 和 end 都不为零时补齐空分桶
+func (a *seriesAccumulator) points(start, end time.Time) []TimeSeriesPoint {
+	var times []time.Time
+	if !start.IsZero() && !end.IsZero() {
+		for t := a.interval.Truncate(start, a.loc); !t.After(end) && len(times) < MaxSeriesPoints; t = a.interval.next(t) {
+			times = append(times, t)
+		}
+	} else {
+		for t := range a.buckets {
+			times = append(times, t)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	}
+
+	points := make([]TimeSeriesPoint, 0, len(times))
+	for _, t := range times {
+		p := TimeSeriesPoint{Time: t, StatusClasses: make(map[string]int64, 5)}
+		b := a.buckets[t]
+		if b == nil {
+			b = &seriesBucket{}
+		}
+		p.Count, p.Errors = b.count, b.errors
+		for i, n := range b.classes {
+			p.StatusClasses[fmt.Sprintf("%dxx", i+1)] = n
+		}
+		switch {
+		case b.durations != nil:
+			p.Latency = exactLatencyPercentiles(b.durations)
+		case b.count > 0:
+			snap := HistogramSnapshot{Buckets: approxPercentileBuckets, Counts: b.hist, Count: uint64(b.count), Sum: b.sum}
+			p.Latency = LatencyPercentiles{
+				Count:       b.count,
+				Avg:         b.sum / float64(b.count),
+				P50:         percentileFromHistogram(snap, 0.5, b.max),
+				P90:         percentileFromHistogram(snap, 0.9, b.max),
+				P95:         percentileFromHistogram(snap, 0.95, b.max),
+				P99:         percentileFromHistogram(snap, 0.99, b.max),
+				Max:         b.max,
+				Approximate: true,
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// seriesRangeError 检查时间范围按 interval 分桶后是否超过 MaxSeriesPoints
+func seriesRangeError(filter LogFilter, interval SeriesInterval) error {
+	if filter.StartTime.IsZero() || filter.EndTime.IsZero() {
+		return nil
+	}
+	step := map[SeriesInterval]time.Duration{SeriesMinute: time.Minute, SeriesHour: time.Hour, SeriesDay: 24 * time.Hour}[interval]
+	if filter.EndTime.Sub(filter.StartTime)/step >= MaxSeriesPoints {
+		return fmt.Errorf("%w %s: at most %d points", ErrSeriesRangeTooLarge, interval, MaxSeriesPoints)
+	}
+	return nil
+}
+
+// minuteKeyFormat Dialect.MinuteKey 返回的文本格式
+const minuteKeyFormat = "2006-01-02 15:04"
+
+// parseMinuteKey 解析 Dialect.MinuteKey 返回的文本，数据库中为本地时间
+func parseMinuteKey(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(minuteKeyFormat, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected minute key %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// TimeSeries 实现 TimeSeriesProvider 接口
+// 数据库按本地时间的分钟聚合（含细粒度耗时直方图），再在 Go 中合并到目标时区和粒度，分位数为估算值
+// 可使用汇总表时读取汇总；按小时、天分桶且目标时区与本地时区整点对齐时使用小时汇总
+func (l *DBLogger) TimeSeries(filter LogFilter, interval SeriesInterval, loc *time.Location) ([]TimeSeriesPoint, error) {
+	if err := interval.Validate(); err != nil {
+		return nil, err
+	}
+	if err := seriesRangeError(filter, interval); err != nil {
+		return nil, err
+	}
+
+	hourOK := interval != SeriesMinute && hourAligned(loc, filter.StartTime, filter.EndTime)
+	rollups, ok, err := l.rollupRows(filter, hourOK)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		acc := newSeriesAccumulator(interval, loc)
+		for i := range rollups {
+			acc.merge(rollups[i].bucket, rollups[i].seriesBucket())
+		}
+		return acc.points(filter.StartTime, filter.EndTime), nil
+	}
+
+	where, args, err := filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := []string{
+		"COUNT(*)",
+		"SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END)",
+	}
+	for class := 1; class <= 5; class++ {
+		cols = append(cols, fmt.Sprintf("SUM(CASE WHEN status_code >= %d AND status_code < %d THEN 1 ELSE 0 END)", class*100, class*100+100))
+	}
+	cols = append(cols, "COALESCE(SUM(duration_ms), 0)", "COALESCE(MAX(duration_ms), 0)")
+	bucketArgs := make([]interface{}, len(approxPercentileBuckets))
+	for i, b := range approxPercentileBuckets {
+		cols = append(cols, "SUM(CASE WHEN duration_ms <= ? THEN 1 ELSE 0 END)")
+		bucketArgs[i] = b
+	}
+
+	key := l.dialect.MinuteKey("created_at")
+	query := fmt.Sprintf("SELECT %s, %s FROM %s%s GROUP BY %s ORDER BY %s",
+		key, strings.Join(cols, ", "), l.tableName, where, key, key)
+	rows, err := l.db.Query(Rebind(l.dialect, query), append(bucketArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	acc := newSeriesAccumulator(interval, loc)
+	for rows.Next() {
+		var minute sql.NullString
+		b := &seriesBucket{hist: make([]uint64, len(approxPercentileBuckets))}
+		dest := []interface{}{&minute, &b.count, &b.errors}
+		for i := range b.classes {
+			dest = append(dest, &b.classes[i])
+		}
+		dest = append(dest, &b.sum, &b.max)
+		for i := range b.hist {
+			dest = append(dest, &b.hist[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		t, err := parseMinuteKey(minute.String)
+		if err != nil {
+			return nil, err
+		}
+		acc.merge(t, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return acc.points(filter.StartTime, filter.EndTime), nil
+}
+
+// TimeSeries 实现 TimeSeriesProvider 接口（分位数精确计算）
+func (l *MemoryLogger) TimeSeries(filter LogFilter, interval SeriesInterval, loc *time.Location) ([]TimeSeriesPoint, error) {
+	if err := interval.Validate(); err != nil {
+		return nil, err
+	}
+	if err := seriesRangeError(filter, interval); err != nil {
+		return nil, err
+	}
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	acc := newSeriesAccumulator(interval, loc)
+	for i := 0; i < l.count; i++ {
+		if e := l.at(i); match(e) {
+			acc.addEntry(e)
+		}
+	}
+	return acc.points(filter.StartTime, filter.EndTime), nil
+}
@@ -0,0 +1,155 @@
+package reqlogmid
+
+import (
+	"testing"
+	"time"
+)
+
+// setLocal 在测试期间把 time.Local 设为 name 指定的时区，日志按本地时间文本写入，时区影响分钟键的解析
+func setLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	orig := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = orig })
+	return loc
+}
+
+// seriesCounts 将时间序列转为 分桶起点（RFC3339，按 loc 表示）-> 请求数
+func seriesCounts(points []TimeSeriesPoint) map[string]int64 {
+	counts := make(map[string]int64, len(points))
+	for _, p := range points {
+		counts[p.Time.Format(time.RFC3339)] = p.Count
+	}
+	return counts
+}
+
+func TestDBLoggerTimeSeriesAcrossDST(t *testing.T) {
+	ny := setLocal(t, "America/New_York")
+	l := newTestDBLogger(t)
+
+	// 2026-03-08 02:00 EST 拨快到 03:00 EDT，当天只有 23 小时
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	entries := []struct {
+		at     string
+		status int
+	}{
+		{"2026-03-07T12:00:00Z", 200},
+		{"2026-03-08T06:30:00Z", 200}, // 01:30 EST
+		{"2026-03-08T07:10:00Z", 500}, // 03:10 EDT
+		{"2026-03-08T07:50:00Z", 404}, // 03:50 EDT
+		{"2026-03-09T12:00:00Z", 200},
+	}
+	for _, e := range entries {
+		err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: e.status, Duration: 10, RequestTime: at(e.at)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("hour", func(t *testing.T) {
+		filter := LogFilter{StartTime: at("2026-03-08T06:00:00Z"), EndTime: at("2026-03-08T07:59:59Z")}
+		points, err := l.TimeSeries(filter, SeriesHour, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 补齐空分桶时跳过不存在的 02:00
+		if got := seriesCounts(points); len(points) != 2 || got["2026-03-08T01:00:00-05:00"] != 1 || got["2026-03-08T03:00:00-04:00"] != 2 {
+			t.Fatalf("points = %v, want 01:00 EST = 1 and 03:00 EDT = 2", got)
+		}
+		p := points[1]
+		if p.Errors != 2 || p.StatusClasses["4xx"] != 1 || p.StatusClasses["5xx"] != 1 || !p.Latency.Approximate {
+			t.Errorf("03:00 point = %+v", p)
+		}
+	})
+
+	t.Run("day", func(t *testing.T) {
+		filter := LogFilter{StartTime: at("2026-03-07T05:00:00Z"), EndTime: at("2026-03-10T03:59:59Z")}
+		points, err := l.TimeSeries(filter, SeriesDay, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := seriesCounts(points)
+		want := map[string]int64{
+			"2026-03-07T00:00:00-05:00": 1,
+			"2026-03-08T00:00:00-05:00": 3,
+			"2026-03-09T00:00:00-04:00": 1,
+		}
+		if len(got) != len(want) {
+			t.Fatalf("points = %v, want %v", got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("day %s count = %d, want %d (all %v)", k, got[k], v, got)
+			}
+		}
+	})
+}
+
+func TestDBLoggerTimeSeriesInOtherLocation(t *testing.T) {
+	setLocal(t, "UTC")
+	l := newTestDBLogger(t)
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	for _, at := range []time.Time{
+		time.Date(2026, 3, 2, 10, 20, 0, 0, time.UTC), // 15:50 IST，3 月 2 日 02:20 PST
+		time.Date(2026, 3, 2, 10, 40, 0, 0, time.UTC), // 16:10 IST
+		time.Date(2026, 3, 2, 5, 0, 0, 0, time.UTC),   // 10:30 IST，3 月 1 日 21:00 PST
+	} {
+		if err := l.Write(&LogEntry{Method: "GET", Path: "/", ClientIP: "127.0.0.1", StatusCode: 200, RequestTime: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		interval SeriesInterval
+		loc      *time.Location
+		want     map[string]int64
+	}{
+		// 半小时时区：小时分桶对齐到当地整点，即 UTC 的半点
+		{"half-hour offset", SeriesHour, kolkata, map[string]int64{
+			"2026-03-02T10:00:00+05:30": 1,
+			"2026-03-02T15:00:00+05:30": 1,
+			"2026-03-02T16:00:00+05:30": 1,
+		}},
+		{"day west of local", SeriesDay, la, map[string]int64{
+			"2026-03-01T00:00:00-08:00": 1,
+			"2026-03-02T00:00:00-08:00": 2,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := l.TimeSeries(LogFilter{}, tt.interval, tt.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := seriesCounts(points)
+			if len(got) != len(tt.want) {
+				t.Fatalf("points = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s count = %d, want %d (all %v)", k, got[k], v, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package reqlogmid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Shutdowner 支持带截止时间优雅关闭的日志输出器
+type Shutdowner interface {
+	// Shutdown 停止接收新日志，在 ctx 截止前写完缓冲区中的日志并释放资源
+	// 返回因截止时间到达而未能写入的日志条数
+	Shutdown(ctx context.Context) (int, error)
+}
+
+// ShutdownLogger 优雅关闭任意 Logger
+// 实现了 Shutdowner 的直接调用 Shutdown，否则在 ctx 截止前执行 Flush 和 Close
+func ShutdownLogger(ctx context.Context, logger Logger) (int, error) {
+	if s, ok := logger.(Shutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		logger.Flush()
+		done <- logger.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return 0, err
+	case <-ctx.Done():
+		return 0, fmt.Errorf("shutdown logger: %w", ctx.Err())
+	}
+}
+
+// waitGroupContext 等待 wg 完成或 ctx 结束
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdownError 截止时间到达时返回的错误
+func shutdownError(lost int, err error) error {
+	return fmt.Errorf("shutdown deadline exceeded, %d log entries lost: %w", lost, err)
+}
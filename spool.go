@@ -0,0 +1,431 @@
+package reqlogmid
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spoolSegmentExt = ".spool"
+	spoolTempExt    = ".tmp"
+)
+
+// ErrDiscardEntry 回放函数返回此错误表示该条日志无法写入（如数据非法），应丢弃而非重试
+var ErrDiscardEntry = errors.New("discard log entry")
+
+// SpoolConfig 磁盘暂存配置
+type SpoolConfig struct {
+	Dir            string        // 暂存目录
+	SegmentSize    int64         // 单个分段文件大小上限，默认 4MB
+	MaxSize        int64         // 暂存总大小上限，超出后丢弃新日志，默认 256MB
+	ReplayInterval time.Duration // 数据库恢复检测间隔，默认 5s
+	// SyncInterval 追加写入后 fsync 当前分段的最长间隔，默认 1s，为负数时每次追加都 fsync
+	// 分段封存（轮转、回放、关闭）时总会 fsync
+	SyncInterval time.Duration
+}
+
+// SpoolStats 磁盘暂存统计
+type SpoolStats struct {
+	Pending   int64  `json:"pending"`   // 待回放条数
+	Bytes     int64  `json:"bytes"`     // 暂存占用字节数
+	Segments  int    `json:"segments"`  // 分段文件数
+	Spooled   uint64 `json:"spooled"`   // 累计写入暂存条数
+	Replayed  uint64 `json:"replayed"`  // 累计回放成功条数
+	Discarded uint64 `json:"discarded"` // 累计丢弃条数（暂存已满或回放时数据无效）
+}
+
+// Spool 基于分段追加文件的磁盘预写暂存
+// 数据库不可用时暂存日志，恢复后按写入顺序回放，保证至少一次投递
+type Spool struct {
+	cfg SpoolConfig
+
+	mu       sync.Mutex
+	segments []string // 已封存待回放的分段，按序号升序
+	current  *os.File
+	curName  string
+	curSize  int64
+	lastSync time.Time
+	total    int64
+	pending  int64
+	nextSeq  uint64
+	closed   bool
+	replayMu sync.Mutex
+
+	spooled   uint64
+	replayed  uint64
+	discarded uint64
+}
+
+// OpenSpool 打开（或创建）磁盘暂存目录，已有的分段会被保留等待回放
+func OpenSpool(cfg SpoolConfig) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("spool dir is required")
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 4 << 20
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 256 << 20
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = 5 * time.Second
+	}
+	if cfg.SyncInterval == 0 {
+		cfg.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+
+	s := &Spool{cfg: cfg}
+
+	// 清理回放重写分段时中断遗留的临时文件，原分段未被替换
+	if tmps, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+spoolSegmentExt+spoolTempExt)); err == nil {
+		for _, name := range tmps {
+			os.Remove(name)
+		}
+	}
+
+	names, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+spoolSegmentExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool segments: %w", err)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		lines, err := countLines(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool segment %s: %w", name, err)
+		}
+		s.segments = append(s.segments, name)
+		s.total += info.Size()
+		s.pending += lines
+		if seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), spoolSegmentExt), 10, 64); err == nil && seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+
+	return s, nil
+}
+
+// Append 将日志追加到暂存，超出总大小上限时丢弃并返回错误
+func (s *Spool) Append(entry *LogEntry) error {
+	// 未记录请求时间的日志以写入暂存的时间代替，回放时不会落到回放时刻
+	requestTime := entry.RequestTime
+	if requestTime.IsZero() {
+		requestTime = time.Now()
+	}
+	data, err := json.Marshal(spoolRecord{spoolEntry: (*spoolEntry)(entry), RequestTime: requestTime})
+	if err != nil {
+		atomic.AddUint64(&s.discarded, 1)
+		return fmt.Errorf("failed to marshal log entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		atomic.AddUint64(&s.discarded, 1)
+		return fmt.Errorf("spool is closed")
+	}
+	if s.total+int64(len(data)) > s.cfg.MaxSize {
+		atomic.AddUint64(&s.discarded, 1)
+		return fmt.Errorf("spool is full, dropping entry: %s %s", entry.Method, entry.Path)
+	}
+
+	if s.current == nil || s.curSize+int64(len(data)) > s.cfg.SegmentSize {
+		if err := s.rotate(); err != nil {
+			atomic.AddUint64(&s.discarded, 1)
+			return err
+		}
+	}
+
+	n, err := s.current.Write(data)
+	s.curSize += int64(n)
+	s.total += int64(n)
+	if err != nil {
+		atomic.AddUint64(&s.discarded, 1)
+		return fmt.Errorf("failed to write spool: %w", err)
+	}
+	s.pending++
+	atomic.AddUint64(&s.spooled, 1)
+
+	if s.cfg.SyncInterval < 0 || time.Since(s.lastSync) >= s.cfg.SyncInterval {
+		if err := s.current.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool: %w", err)
+		}
+		s.lastSync = time.Now()
+	}
+	return nil
+}
+
+// rotate 封存当前分段并创建新分段，调用方需持有 mu
+func (s *Spool) rotate() error {
+	s.seal()
+	name := filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d%s", s.nextSeq, spoolSegmentExt))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+	// 新分段的目录项落盘，否则掉电后整个分段可能丢失
+	if err := syncDir(s.cfg.Dir); err != nil {
+		f.Close()
+		os.Remove(name)
+		return fmt.Errorf("failed to sync spool dir: %w", err)
+	}
+	s.nextSeq++
+	s.current = f
+	s.curName = name
+	s.curSize = 0
+	s.lastSync = time.Now()
+	return nil
+}
+
+// seal 关闭当前分段并加入待回放列表，调用方需持有 mu
+func (s *Spool) seal() {
+	if s.current == nil {
+		return
+	}
+	s.current.Sync()
+	s.current.Close()
+	if s.curSize > 0 {
+		s.segments = append(s.segments, s.curName)
+	} else {
+		os.Remove(s.curName)
+	}
+	s.current = nil
+	s.curName = ""
+	s.curSize = 0
+}
+
+// Pending 返回待回放的日志条数
+func (s *Spool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Replay 按写入顺序回放暂存的日志
+// fn 返回错误时停止回放，未回放的日志保留在暂存中；fn 返回 ErrDiscardEntry 时丢弃该条继续回放
+func (s *Spool) Replay(fn func(*LogEntry) error) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	s.seal()
+	segments := append([]string(nil), s.segments...)
+	s.mu.Unlock()
+
+	replayed := 0
+	for _, name := range segments {
+		n, err := s.replaySegment(name, fn)
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// replaySegment 回放单个分段，全部成功后删除该分段
+// 部分成功时将剩余内容重写回分段，避免重复回放
+func (s *Spool) replaySegment(name string, fn func(*LogEntry) error) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+
+	var (
+		lines    [][]byte
+		replayed int
+		done     int64
+		fnErr    error
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		lines = append(lines, line)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read spool segment: %w", err)
+	}
+
+	i := 0
+	for ; i < len(lines); i++ {
+		done += int64(len(lines[i]) + 1)
+		var entry LogEntry
+		if err := unmarshalSpoolRecord(lines[i], &entry); err != nil {
+			atomic.AddUint64(&s.discarded, 1)
+			continue
+		}
+		if err := fn(&entry); err != nil {
+			if errors.Is(err, ErrDiscardEntry) {
+				atomic.AddUint64(&s.discarded, 1)
+				continue
+			}
+			done -= int64(len(lines[i]) + 1)
+			fnErr = err
+			break
+		}
+		replayed++
+		atomic.AddUint64(&s.replayed, 1)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total -= done
+	s.pending -= int64(i)
+	if fnErr == nil {
+		os.Remove(name)
+		for j, seg := range s.segments {
+			if seg == name {
+				s.segments = append(s.segments[:j], s.segments[j+1:]...)
+				break
+			}
+		}
+		return replayed, nil
+	}
+
+	// 重写剩余内容
+	var rest []byte
+	for _, line := range lines[i:] {
+		rest = append(rest, line...)
+		rest = append(rest, '\n')
+	}
+	if err := replaceFile(name, rest); err != nil {
+		return replayed, fmt.Errorf("failed to rewrite spool segment: %w", err)
+	}
+	return replayed, fnErr
+}
+
+// replaceFile 原子地替换文件内容：写入临时文件并 fsync 后改名覆盖，再 fsync 目录
+// 中途崩溃时原文件保持完整
+func replaceFile(name string, data []byte) error {
+	tmp := name + spoolTempExt
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// syncDir fsync 目录，使其中新建、改名的目录项落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// spoolEntry 与 LogEntry 字段相同但没有 MarshalJSON 方法，便于嵌入 spoolRecord
+type spoolEntry LogEntry
+
+// spoolRecord 暂存文件中的一行：日志字段之外附带请求时间，回放时据此写入 created_at
+type spoolRecord struct {
+	*spoolEntry
+	RequestTime time.Time `json:"request_time"`
+}
+
+// unmarshalSpoolRecord 解析暂存文件中的一行，兼容不带 request_time 的旧格式
+func unmarshalSpoolRecord(data []byte, entry *LogEntry) error {
+	rec := spoolRecord{spoolEntry: (*spoolEntry)(entry)}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	entry.RequestTime = rec.RequestTime
+	return nil
+}
+
+// Stats 返回暂存统计
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	segments := len(s.segments)
+	if s.current != nil {
+		segments++
+	}
+	stats := SpoolStats{
+		Pending:  s.pending,
+		Bytes:    s.total,
+		Segments: segments,
+	}
+	s.mu.Unlock()
+
+	stats.Spooled = atomic.LoadUint64(&s.spooled)
+	stats.Replayed = atomic.LoadUint64(&s.replayed)
+	stats.Discarded = atomic.LoadUint64(&s.discarded)
+	return stats
+}
+
+// Close 关闭暂存，未回放的日志保留在磁盘上，下次打开时继续回放
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.seal()
+	return nil
+}
+
+// countLines 统计文件行数
+func countLines(name string) (int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var n int64
+	reader := bufio.NewReader(f)
+	buf := make([]byte, 32*1024)
+	for {
+		c, err := reader.Read(buf)
+		for _, b := range buf[:c] {
+			if b == '\n' {
+				n++
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,107 @@
+package reqlogmid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpoolReplayKeepsRequestTimeAndRemainder(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(SpoolConfig{Dir: dir, SyncInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		entry := &LogEntry{Method: "GET", Path: "/", StatusCode: 200 + i, RequestTime: base.Add(time.Duration(i) * time.Minute)}
+		if err := s.Append(entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	// 回放两条后失败，剩余三条保留
+	errDown := errors.New("database down")
+	var got []LogEntry
+	n, err := s.Replay(func(e *LogEntry) error {
+		if len(got) == 2 {
+			return errDown
+		}
+		got = append(got, *e)
+		return nil
+	})
+	if !errors.Is(err, errDown) || n != 2 {
+		t.Fatalf("Replay = %d, %v; want 2, %v", n, err, errDown)
+	}
+	if s.Pending() != 3 {
+		t.Errorf("Pending = %d, want 3", s.Pending())
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*"+spoolTempExt)); len(tmps) != 0 {
+		t.Errorf("temporary files left behind: %v", tmps)
+	}
+	s.Close()
+
+	// 重新打开后回放剩余日志
+	s, err = OpenSpool(SpoolConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Pending() != 3 {
+		t.Fatalf("Pending after reopen = %d, want 3", s.Pending())
+	}
+	if _, err := s.Replay(func(e *LogEntry) error {
+		got = append(got, *e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("replayed %d entries, want 5", len(got))
+	}
+	for i, e := range got {
+		want := base.Add(time.Duration(i) * time.Minute)
+		if e.StatusCode != 200+i || !e.RequestTime.Equal(want) {
+			t.Errorf("entry %d = status %d at %v, want status %d at %v", i, e.StatusCode, e.RequestTime, 200+i, want)
+		}
+	}
+	if stats := s.Stats(); stats.Pending != 0 || stats.Bytes != 0 {
+		t.Errorf("stats after replay = %+v", stats)
+	}
+}
+
+func TestSpoolReadsLegacySegments(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"method":"POST","path":"/old","client_ip":"","user_agent":"","status_code":201,"duration_ms":1,"timestamp":"x"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000000"+spoolSegmentExt), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 上次重写中断遗留的临时文件应被清理
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000000"+spoolSegmentExt+spoolTempExt), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := OpenSpool(SpoolConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var got []LogEntry
+	if _, err := s.Replay(func(e *LogEntry) error {
+		got = append(got, *e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != "/old" || !got[0].RequestTime.IsZero() {
+		t.Errorf("replayed %+v", got)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*"+spoolTempExt)); len(tmps) != 0 {
+		t.Errorf("temporary files left behind: %v", tmps)
+	}
+}
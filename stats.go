@@ -0,0 +1,153 @@
+package reqlogmid
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets 默认耗时直方图分桶上界（毫秒）
+var DefaultLatencyBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Histogram 固定分桶直方图，并发安全
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram 创建直方图，buckets 为分桶上界，为空时使用 DefaultLatencyBuckets
+func NewHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Histogram{
+		buckets: b,
+		counts:  make([]uint64, len(b)),
+	}
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// Snapshot 返回直方图快照，Counts 为累计计数（与 Prometheus le 语义一致）
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snap := HistogramSnapshot{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  make([]uint64, len(h.counts)),
+		Sum:     h.sum,
+		Count:   h.count,
+	}
+	var cumulative uint64
+	for i, c := range h.counts {
+		cumulative += c
+		snap.Counts[i] = cumulative
+	}
+	return snap
+}
+
+// HistogramSnapshot 直方图快照
+type HistogramSnapshot struct {
+	Buckets []float64 `json:"buckets"` // 分桶上界
+	Counts  []uint64  `json:"counts"`  // 小于等于对应上界的累计计数
+	Sum     float64   `json:"sum"`
+	Count   uint64    `json:"count"`
+}
+
+// LoggerStats 日志输出器运行统计
+type LoggerStats struct {
+	QueueDepth    int               `json:"queue_depth"`    // 缓冲区中待写入条数
+	QueueCapacity int               `json:"queue_capacity"` // 缓冲区容量
+	Written       uint64            `json:"written"`        // 写入成功条数
+	Failed        uint64            `json:"failed"`         // 写入失败条数
+	Dropped       uint64            `json:"dropped"`        // 缓冲区满丢弃条数
+	LastError     string            `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time        `json:"last_error_at,omitempty"` // 没有错误时为 nil
+	WriteLatency  HistogramSnapshot `json:"write_latency_ms"`        // 单条写入耗时（毫秒）
+	Backpressure  BackpressureStats `json:"backpressure"`
+
+	Spool   *SpoolStats          `json:"spool,omitempty"`   // 磁盘暂存统计，未启用暂存时为 nil
+	Circuit *CircuitBreakerStats `json:"circuit,omitempty"` // 写入熔断器状态，没有熔断器的输出器为 nil
+}
+
+// StatsProvider 可提供运行统计的日志输出器
+type StatsProvider interface {
+	Stats() LoggerStats
+}
+
+// writeStats 写入计数与耗时统计
+type writeStats struct {
+	written uint64
+	failed  uint64
+	latency *Histogram
+
+	mu          sync.Mutex
+	lastErr     error
+	lastErrorAt time.Time
+}
+
+// record 记录一次写入结果
+func (s *writeStats) record(start time.Time, err error) {
+	s.recordN(start, 1, err)
+}
+
+// recordN 记录一次批量写入结果，n 为本批条数
+func (s *writeStats) recordN(start time.Time, n int, err error) {
+	if err != nil {
+		s.recordBatch(start, 0, n, err)
+		return
+	}
+	s.recordBatch(start, n, 0, nil)
+}
+
+// recordBatch 记录一次批量写入结果，允许部分成功
+func (s *writeStats) recordBatch(start time.Time, written, failed int, err error) {
+	if s.latency == nil {
+		return
+	}
+	s.latency.Observe(float64(time.Since(start)) / float64(time.Millisecond))
+	atomic.AddUint64(&s.written, uint64(written))
+	if failed == 0 && err == nil {
+		return
+	}
+	atomic.AddUint64(&s.failed, uint64(failed))
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.lastErr = err
+	s.lastErrorAt = time.Now()
+	s.mu.Unlock()
+}
+
+// snapshot 填充 LoggerStats 中的写入统计部分
+func (s *writeStats) snapshot(stats *LoggerStats) {
+	stats.Written = atomic.LoadUint64(&s.written)
+	stats.Failed = atomic.LoadUint64(&s.failed)
+	if s.latency != nil {
+		stats.WriteLatency = s.latency.Snapshot()
+	}
+	s.mu.Lock()
+	if s.lastErr != nil {
+		stats.LastError = s.lastErr.Error()
+		at := s.lastErrorAt
+		stats.LastErrorAt = &at
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,313 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog 严重级别（RFC 5424）
+const (
+	SyslogSeverityError         = 3
+	SyslogSeverityWarning       = 4
+	SyslogSeverityNotice        = 5
+	SyslogSeverityInformational = 6
+)
+
+// SyslogFacilityLocal0 默认 facility（local0）
+const SyslogFacilityLocal0 = 16
+
+// SyslogConfig syslog 输出配置
+type SyslogConfig struct {
+	Network      string        // unix（默认，本地 /dev/log）、udp、tcp
+	Address      string        // 地址，unix 默认 /dev/log
+	Facility     int           // facility，默认 local0 (16)
+	AppName      string        // APP-NAME，默认 req-log-mid
+	Hostname     string        // HOSTNAME，默认 os.Hostname()
+	SDID         string        // 结构化数据 ID，默认 request@32473
+	DialTimeout  time.Duration // 连接超时，默认 5s
+	WriteTimeout time.Duration // 写超时，默认 5s
+	// Severity 自定义状态码到严重级别的映射，为 nil 时 5xx 为 error、4xx 为 warning、其余为 informational
+	Severity func(statusCode int) int
+}
+
+// SyslogLogger 以 RFC 5424 格式输出到 syslog
+// 支持本地 Unix socket、UDP 和 TCP（octet-counting 分帧），写入失败时自动重连
+type SyslogLogger struct {
+	mu      sync.Mutex
+	cfg     SyslogConfig
+	conn    net.Conn
+	network string // 实际使用的网络类型（unix 可能回退为 unixgram 或 unix）
+	stream  bool   // 流式传输需要分帧
+	pid     string
+	closed  bool
+	stats   writeStats
+}
+
+// NewSyslogLogger 创建 syslog 日志输出器并建立连接
+func NewSyslogLogger(cfg SyslogConfig) (*SyslogLogger, error) {
+	if cfg.Network == "" {
+		cfg.Network = "unix"
+	}
+	if cfg.Address == "" && cfg.Network == "unix" {
+		cfg.Address = "/dev/log"
+	}
+	if cfg.Facility <= 0 {
+		cfg.Facility = SyslogFacilityLocal0
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "req-log-mid"
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.SDID == "" {
+		cfg.SDID = "request@32473"
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 5 * time.Second
+	}
+	if cfg.Severity == nil {
+		cfg.Severity = DefaultSyslogSeverity
+	}
+
+	l := &SyslogLogger{
+		cfg:   cfg,
+		pid:   strconv.Itoa(os.Getpid()),
+		stats: writeStats{latency: NewHistogram(nil)},
+	}
+	if err := l.connect(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DefaultSyslogSeverity 默认状态码到严重级别的映射
+func DefaultSyslogSeverity(statusCode int) int {
+	switch {
+	case statusCode >= 500:
+		return SyslogSeverityError
+	case statusCode >= 400:
+		return SyslogSeverityWarning
+	default:
+		return SyslogSeverityInformational
+	}
+}
+
+// connect 建立连接，调用方需持有 mu（构造时除外）
+func (l *SyslogLogger) connect() error {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	networks := []string{l.cfg.Network}
+	if l.cfg.Network == "unix" {
+		// 本地 syslog 一般为数据报 socket，失败再尝试流式
+		networks = []string{"unixgram", "unix"}
+	}
+
+	var lastErr error
+	for _, network := range networks {
+		conn, err := net.DialTimeout(network, l.cfg.Address, l.cfg.DialTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		l.conn = conn
+		l.network = network
+		l.stream = network == "tcp" || network == "unix"
+		return nil
+	}
+	return fmt.Errorf("failed to connect syslog %s %s: %w", l.cfg.Network, l.cfg.Address, lastErr)
+}
+
+// Write 实现 Logger 接口
+func (l *SyslogLogger) Write(entry *LogEntry) (err error) {
+	msg := l.Format(entry)
+	start := time.Now()
+	defer func() { l.stats.record(start, err) }()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("logger is closed")
+	}
+
+	if err = l.send(msg); err == nil {
+		return nil
+	}
+
+	// 写入失败，重连后重试一次
+	if cerr := l.connect(); cerr != nil {
+		return fmt.Errorf("%v; %w", err, cerr)
+	}
+	return l.send(msg)
+}
+
+// send 发送一条消息，流式传输使用 octet-counting 分帧（RFC 6587）
+func (l *SyslogLogger) send(msg string) error {
+	if l.conn == nil {
+		return fmt.Errorf("syslog not connected")
+	}
+	if l.stream {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	l.conn.SetWriteDeadline(time.Now().Add(l.cfg.WriteTimeout))
+	_, err := l.conn.Write([]byte(msg))
+	return err
+}
+
+// Format 将日志格式化为 RFC 5424 消息（不含分帧）
+func (l *SyslogLogger) Format(entry *LogEntry) string {
+	pri := l.cfg.Facility*8 + l.cfg.Severity(entry.StatusCode)
+	timestamp := entryTime(entry, "").Format("2006-01-02T15:04:05.000000Z07:00")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<%d>1 %s %s %s %s %s ",
+		pri,
+		timestamp,
+		syslogHeaderField(l.cfg.Hostname, 255),
+		syslogHeaderField(l.cfg.AppName, 48),
+		l.pid,
+		"request",
+	)
+
+	// 结构化数据
+	sb.WriteByte('[')
+	sb.WriteString(l.cfg.SDID)
+	writeSDParam(&sb, "method", entry.Method)
+	writeSDParam(&sb, "path", entry.Path)
+	if entry.Route != "" {
+		writeSDParam(&sb, "route", entry.Route)
+	}
+	writeSDParam(&sb, "status", strconv.Itoa(entry.StatusCode))
+	writeSDParam(&sb, "duration_ms", strconv.FormatFloat(entry.Duration, 'f', -1, 64))
+	writeSDParam(&sb, "client_ip", entry.ClientIP)
+	if entry.UserAgent != "" {
+		writeSDParam(&sb, "user_agent", entry.UserAgent)
+	}
+	sb.WriteByte(']')
+
+	if len(entry.CustomFields) > 0 {
+		keys := make([]string, 0, len(entry.CustomFields))
+		for k := range entry.CustomFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		sdid := "fields"
+		if i := strings.IndexByte(l.cfg.SDID, '@'); i >= 0 {
+			sdid += l.cfg.SDID[i:]
+		}
+		sb.WriteByte('[')
+		sb.WriteString(sdid)
+		for _, k := range keys {
+			writeSDParam(&sb, sdParamName(k), fmt.Sprintf("%v", entry.CustomFields[k]))
+		}
+		sb.WriteByte(']')
+	}
+
+	fmt.Fprintf(&sb, " %s %s %d %s", entry.Method, entry.Path, entry.StatusCode, formatLatency(entry.Duration))
+	return sb.String()
+}
+
+// Stats 返回运行统计
+func (l *SyslogLogger) Stats() LoggerStats {
+	var stats LoggerStats
+	l.stats.snapshot(&stats)
+	return stats
+}
+
+// Flush 实现 Logger 接口（同步输出，无需刷新）
+func (l *SyslogLogger) Flush() {}
+
+// Close 实现 Logger 接口
+func (l *SyslogLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.conn != nil {
+		return l.conn.Close()
+	}
+	return nil
+}
+
+// parseEntryTime 解析日志时间，失败时使用当前时间
+func parseEntryTime(s string) time.Time {
+	for _, layout := range []string{DefaultTimeFormat, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// entryTime 返回日志的请求时间：优先使用中间件记录的 RequestTime，
+// 否则按 layout（为空时同 parseEntryTime）解析 Timestamp，失败时使用当前时间
+func entryTime(entry *LogEntry, layout string) time.Time {
+	if !entry.RequestTime.IsZero() {
+		return entry.RequestTime
+	}
+	if layout != "" {
+		if t, err := time.Parse(layout, entry.Timestamp); err == nil {
+			return t
+		}
+	}
+	return parseEntryTime(entry.Timestamp)
+}
+
+// syslogHeaderField 头部字段只允许可打印 ASCII，空值用 - 表示
+func syslogHeaderField(s string, max int) string {
+	var sb strings.Builder
+	for i := 0; i < len(s) && sb.Len() < max; i++ {
+		if s[i] > 32 && s[i] < 127 {
+			sb.WriteByte(s[i])
+		}
+	}
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
+
+// sdParamName 结构化数据参数名只允许可打印 ASCII 且不含 = ] " 空格，最长 32
+func sdParamName(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s) && sb.Len() < 32; i++ {
+		c := s[i]
+		if c > 32 && c < 127 && c != '=' && c != ']' && c != '"' {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
+
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// writeSDParam 写入结构化数据参数，值中的 \ " ] 需要转义
+func writeSDParam(sb *strings.Builder, name, value string) {
+	sb.WriteByte(' ')
+	sb.WriteString(name)
+	sb.WriteString(`="`)
+	sb.WriteString(sdValueEscaper.Replace(value))
+	sb.WriteByte('"')
+}
@@ -0,0 +1,145 @@
+package reqlogmid
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogLoggerUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	l, err := NewSyslogLogger(SyslogConfig{Network: "udp", Address: pc.LocalAddr().String(), Hostname: "host", AppName: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tests := []struct {
+		entry LogEntry
+		want  []string
+	}{
+		{
+			LogEntry{Method: "GET", Path: "/ok", ClientIP: "10.0.0.1", StatusCode: 200, Duration: 1.5, Timestamp: "2026-01-02T03:04:05.000+08:00"},
+			[]string{"<134>1 2026-01-02T03:04:05.000000+08:00 host app ", ` request [request@32473 method="GET" path="/ok" status="200" duration_ms="1.5" client_ip="10.0.0.1"]`, " GET /ok 200 "},
+		},
+		{
+			LogEntry{Method: "POST", Path: "/bad", StatusCode: 404, CustomFields: map[string]interface{}{"a b": `x"]\`}},
+			[]string{"<132>1 ", `[fields@32473 a_b="x\"\]\\"]`},
+		},
+		{
+			LogEntry{Method: "PUT", Path: "/err", StatusCode: 503},
+			[]string{"<131>1 "},
+		},
+		{
+			// 自定义 TimeFormat 的 Timestamp 无法解析，使用中间件记录的请求时间
+			LogEntry{Method: "GET", Path: "/custom", StatusCode: 200, Timestamp: "02/01/2026 03:04:05",
+				RequestTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 8*3600))},
+			[]string{"<134>1 2026-01-02T03:04:05.000000+08:00 host app "},
+		},
+	}
+	buf := make([]byte, 4096)
+	for _, tt := range tests {
+		if err := l.Write(&tt.entry); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read datagram: %v", err)
+		}
+		msg := string(buf[:n])
+		for _, want := range tt.want {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message %q missing %q", msg, want)
+			}
+		}
+	}
+	if stats := l.Stats(); stats.Written != 4 || stats.Failed != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+// readFrame 读取一条 octet-counting 分帧的消息
+func readFrame(r *bufio.Reader) (string, error) {
+	size, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogLoggerTCPFramingAndReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	l, err := NewSyslogLogger(SyslogConfig{Network: "tcp", Address: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	first := <-conns
+	if err := l.Write(&LogEntry{Method: "GET", Path: "/one", StatusCode: 200}); err != nil {
+		t.Fatal(err)
+	}
+	first.SetReadDeadline(time.Now().Add(time.Second))
+	msg, err := readFrame(bufio.NewReader(first))
+	if err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if !strings.HasPrefix(msg, "<134>1 ") || !strings.HasSuffix(msg, " GET /one 200 0s") {
+		t.Errorf("frame = %q", msg)
+	}
+
+	// 服务端断开后，写入失败应触发重连并在新连接上送达
+	first.Close()
+	var second net.Conn
+	for i := 0; i < 20 && second == nil; i++ {
+		l.Write(&LogEntry{Method: "GET", Path: "/two", StatusCode: 200})
+		select {
+		case second = <-conns:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if second == nil {
+		t.Fatal("logger did not reconnect")
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	msg, err = readFrame(bufio.NewReader(second))
+	if err != nil {
+		t.Fatalf("read frame after reconnect: %v", err)
+	}
+	if !strings.Contains(msg, " GET /two 200 ") {
+		t.Errorf("frame after reconnect = %q", msg)
+	}
+}
@@ -0,0 +1,369 @@
+package reqlogmid
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TopDimension 排行的分组维度，自定义字段使用 "field.<key>"
+type TopDimension string
+
+const (
+	TopRoute     TopDimension = "route"      // 路由模板
+	TopPath      TopDimension = "path"       // 请求路径
+	TopClientIP  TopDimension = "client_ip"  // 客户端 IP
+	TopMethod    TopDimension = "method"     // 请求方法
+	TopStatus    TopDimension = "status"     // 状态码
+	TopUserAgent TopDimension = "user_agent" // User-Agent
+)
+
+// topFieldPrefix 自定义字段维度前缀
+const topFieldPrefix = "field."
+
+// TopField 返回按自定义字段分组的维度
+func TopField(key string) TopDimension {
+	return TopDimension(topFieldPrefix + key)
+}
+
+// topColumns 内置维度对应的列（或表达式）
+var topColumns = map[TopDimension]string{
+	TopRoute:     "COALESCE(route, '')",
+	TopPath:      "path",
+	TopClientIP:  "client_ip",
+	TopMethod:    "method",
+	TopStatus:    "status_code",
+	TopUserAgent: "COALESCE(user_agent, '')",
+}
+
+// fieldKey 返回自定义字段维度的字段名，内置维度返回空串
+func (d TopDimension) fieldKey() string {
+	if _, ok := topColumns[d]; ok {
+		return ""
+	}
+	return strings.TrimPrefix(string(d), topFieldPrefix)
+}
+
+// Validate 校验维度
+func (d TopDimension) Validate() error {
+	if _, ok := topColumns[d]; ok {
+		return nil
+	}
+	if !strings.HasPrefix(string(d), topFieldPrefix) {
+		return fmt.Errorf("unsupported top dimension %q", d)
+	}
+	return ValidateFieldKey(d.fieldKey())
+}
+
+// expr 返回维度的分组表达式
+func (d TopDimension) expr(dialect Dialect) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	if col, ok := topColumns[d]; ok {
+		return col, nil
+	}
+	return dialect.JSONText("custom_fields", d.fieldKey()), nil
+}
+
+// key 返回日志在该维度上的取值，与 SQL 表达式一致；自定义字段缺失时记为空串（对应 SQL 的 NULL 分组）
+func (d TopDimension) key(e *DBLogEntry) string {
+	switch d {
+	case TopRoute:
+		return e.Route
+	case TopPath:
+		return e.Path
+	case TopClientIP:
+		return e.ClientIP
+	case TopMethod:
+		return e.Method
+	case TopStatus:
+		return strconv.Itoa(e.StatusCode)
+	case TopUserAgent:
+		return e.UserAgent
+	}
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal([]byte(e.CustomFields), &fields)
+	if v, ok := fields[d.fieldKey()]; ok {
+		return jsonText(v)
+	}
+	return ""
+}
+
+// TopOrder 排行依据
+type TopOrder string
+
+const (
+	TopByCount         TopOrder = "count"          // 请求数
+	TopByErrors        TopOrder = "errors"         // 错误数（状态码 >= 400）
+	TopByErrorRate     TopOrder = "error_rate"     // 错误率
+	TopByTotalDuration TopOrder = "total_duration" // 总耗时
+	TopByP95           TopOrder = "p95"            // p95 耗时
+)
+
+// Validate 校验排行依据
+func (o TopOrder) Validate() error {
+	switch o {
+	case TopByCount, TopByErrors, TopByErrorRate, TopByTotalDuration, TopByP95:
+		return nil
+	}
+	return fmt.Errorf("unsupported top order %q", o)
+}
+
+// topCandidateLimit 方言不支持分位数且按 p95 排行时，先按请求数取的候选分组数
+const topCandidateLimit = 1000
+
+// TopQuery 排行查询
+type TopQuery struct {
+	By       TopDimension // 分组维度
+	OrderBy  TopOrder     // 排行依据，默认 TopByCount
+	Limit    int          // 返回条数，默认 10
+	MinCount int64        // 请求数少于此值的分组不参与排行，避免按错误率排行时被零星请求占据
+	Filter   LogFilter    // 过滤条件，通常包含时间范围
+}
+
+// TopItem 排行中的一项
+type TopItem struct {
+	Key           string  `json:"key"`
+	Count         int64   `json:"count"`
+	Errors        int64   `json:"errors"`
+	ErrorRate     float64 `json:"error_rate"` // 百分比
+	TotalDuration float64 `json:"total_duration_ms"`
+	AvgDuration   float64 `json:"avg_duration_ms"`
+	P95           float64 `json:"p95_ms"`
+	Approximate   bool    `json:"approximate"` // 为 true 时 P95 由直方图估算
+}
+
+// normalize 填充默认值并校验
+func (q *TopQuery) normalize() error {
+	if q.OrderBy == "" {
+		q.OrderBy = TopByCount
+	}
+	if err := q.OrderBy.Validate(); err != nil {
+		return err
+	}
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+	return nil
+}
+
+// less 判断 a 是否应排在 b 之前
+func (o TopOrder) less(a, b *TopItem) bool {
+	var x, y float64
+	switch o {
+	case TopByErrors:
+		x, y = float64(a.Errors), float64(b.Errors)
+	case TopByErrorRate:
+		x, y = a.ErrorRate, b.ErrorRate
+	case TopByTotalDuration:
+		x, y = a.TotalDuration, b.TotalDuration
+	case TopByP95:
+		x, y = a.P95, b.P95
+	default:
+		x, y = float64(a.Count), float64(b.Count)
+	}
+	if x != y {
+		return x > y
+	}
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Key < b.Key
+}
+
+// sortTopItems 按排行依据排序并截取前 limit 项
+func sortTopItems(items []TopItem, order TopOrder, limit int) []TopItem {
+	sort.Slice(items, func(i, j int) bool { return order.less(&items[i], &items[j]) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// finish 由计数和耗时计算派生字段
+func (item *TopItem) finish() {
+	if item.Count > 0 {
+		item.ErrorRate = 100 * float64(item.Errors) / float64(item.Count)
+		item.AvgDuration = item.TotalDuration / float64(item.Count)
+	}
+}
+
+// Top 按维度分组排行
+// 方言支持 percentile_cont 时在数据库中排序；否则 P95 由直方图估算，按 p95 排行时先按请求数取候选分组再在 Go 中排序
+// 按路由或方法排行且可使用汇总表时读取汇总
+func (l *DBLogger) Top(q TopQuery) ([]TopItem, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	if q.By == TopRoute || q.By == TopMethod {
+		rows, ok, err := l.rollupRows(q.Filter, true)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return topFromRollups(rows, q), nil
+		}
+	}
+	key, err := q.By.expr(l.dialect)
+	if err != nil {
+		return nil, err
+	}
+	where, whereArgs, err := q.Filter.whereClause(l.dialect, l.tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	errorsExpr := "SUM(CASE WHEN status_code >= 400 THEN 1 ELSE 0 END)"
+	totalExpr := "COALESCE(SUM(duration_ms), 0)"
+	cols := []string{key, "COUNT(*)", errorsExpr, totalExpr}
+	var selectArgs []interface{}
+
+	p95 := l.dialect.Percentile("duration_ms", 0.95)
+	exact := p95 != ""
+	if exact {
+		cols = append(cols, p95)
+	} else {
+		cols = append(cols, "COALESCE(MAX(duration_ms), 0)")
+		for _, b := range approxPercentileBuckets {
+			cols = append(cols, "SUM(CASE WHEN duration_ms <= ? THEN 1 ELSE 0 END)")
+			selectArgs = append(selectArgs, b)
+		}
+	}
+
+	orderExpr := map[TopOrder]string{
+		TopByCount:         "COUNT(*)",
+		TopByErrors:        errorsExpr,
+		TopByErrorRate:     errorsExpr + " * 1.0 / COUNT(*)",
+		TopByTotalDuration: totalExpr,
+		TopByP95:           p95,
+	}[q.OrderBy]
+	limit := q.Limit
+	if !exact && q.OrderBy == TopByP95 {
+		orderExpr, limit = "COUNT(*)", topCandidateLimit
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s GROUP BY %s HAVING COUNT(*) >= ? ORDER BY %s DESC, COUNT(*) DESC LIMIT ?",
+		strings.Join(cols, ", "), l.tableName, where, key, orderExpr)
+	args := append(append(selectArgs, whereArgs...), q.MinCount, limit)
+	rows, err := l.db.Query(Rebind(l.dialect, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TopItem
+	for rows.Next() {
+		var item TopItem
+		var k sql.NullString
+		dest := []interface{}{&k, &item.Count, &item.Errors, &item.TotalDuration}
+		var p95Value sql.NullFloat64
+		var maxDuration float64
+		hist := make([]uint64, len(approxPercentileBuckets))
+		if exact {
+			dest = append(dest, &p95Value)
+		} else {
+			dest = append(dest, &maxDuration)
+			for i := range hist {
+				dest = append(dest, &hist[i])
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		item.Key = k.String
+		if exact {
+			item.P95 = p95Value.Float64
+		} else {
+			snap := HistogramSnapshot{Buckets: approxPercentileBuckets, Counts: hist, Count: uint64(item.Count)}
+			item.P95 = percentileFromHistogram(snap, 0.95, maxDuration)
+			item.Approximate = true
+		}
+		item.finish()
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortTopItems(items, q.OrderBy, q.Limit), nil
+}
+
+// topFromRollups 由汇总行按路由或方法分组排行，P95 为估算值
+func topFromRollups(rows []rollupRow, q TopQuery) []TopItem {
+	groups := make(map[string]*rollupRow)
+	for i := range rows {
+		k := rows[i].route
+		if q.By == TopMethod {
+			k = rows[i].method
+		}
+		g, ok := groups[k]
+		if !ok {
+			g = &rollupRow{sketch: newLatencySketch()}
+			groups[k] = g
+		}
+		g.merge(&rows[i])
+	}
+
+	items := make([]TopItem, 0, len(groups))
+	for k, g := range groups {
+		if g.count < q.MinCount {
+			continue
+		}
+		item := TopItem{Key: k, Count: g.count, Errors: g.errors, TotalDuration: g.sum, P95: g.percentile(0.95), Approximate: true}
+		item.finish()
+		items = append(items, item)
+	}
+	return sortTopItems(items, q.OrderBy, q.Limit)
+}
+
+// Top 按维度分组排行（P95 精确计算）
+func (l *MemoryLogger) Top(q TopQuery) ([]TopItem, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	if err := q.By.Validate(); err != nil {
+		return nil, err
+	}
+	match, err := q.Filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	groups := make(map[string]*TopItem)
+	durations := make(map[string][]float64)
+	for i := 0; i < l.count; i++ {
+		e := l.at(i)
+		if !match(e) {
+			continue
+		}
+		k := q.By.key(e)
+		item, ok := groups[k]
+		if !ok {
+			item = &TopItem{Key: k}
+			groups[k] = item
+		}
+		item.Count++
+		if e.StatusCode >= 400 {
+			item.Errors++
+		}
+		item.TotalDuration += e.Duration
+		durations[k] = append(durations[k], e.Duration)
+	}
+	l.mu.RUnlock()
+
+	items := make([]TopItem, 0, len(groups))
+	for k, item := range groups {
+		if item.Count < q.MinCount {
+			continue
+		}
+		sort.Float64s(durations[k])
+		item.P95 = percentileCont(durations[k], 0.95)
+		item.finish()
+		items = append(items, *item)
+	}
+	return sortTopItems(items, q.OrderBy, q.Limit), nil
+}
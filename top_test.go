@@ -0,0 +1,157 @@
+package reqlogmid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTopEntries 向各个存储写入排行测试日志，各排行依据下的顺序互不相同：
+// /a 5 次全部成功、各 10ms；/b 3 次全部失败、各 20ms；/c 4 次 1 次失败、各 100ms；/d 1 次失败、2000ms
+func writeTopEntries(t *testing.T, stores ...Logger) {
+	t.Helper()
+	groups := []struct {
+		path     string
+		tenant   string
+		n        int
+		errors   int
+		duration float64
+	}{
+		{"/a", "acme", 5, 0, 10},
+		{"/b", "globex", 3, 3, 20},
+		{"/c", "acme", 4, 1, 100},
+		{"/d", "", 1, 1, 2000},
+	}
+	for _, g := range groups {
+		for i := 0; i < g.n; i++ {
+			status := 200
+			if i < g.errors {
+				status = 500
+			}
+			var fields map[string]interface{}
+			if g.tenant != "" {
+				fields = map[string]interface{}{"tenant": g.tenant}
+			}
+			for _, s := range stores {
+				e := LogEntry{Method: "GET", Path: g.path, Route: g.path, ClientIP: "127.0.0.1", StatusCode: status, Duration: g.duration, CustomFields: fields}
+				if err := s.Write(&e); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+// topKeys 按顺序列出排行的分组键
+func topKeys(items []TopItem) string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestDBLoggerTop(t *testing.T) {
+	db := newTestDBLogger(t)
+	mem := NewMemoryLogger(100)
+	writeTopEntries(t, db, mem)
+
+	tests := []struct {
+		name string
+		q    TopQuery
+		want string
+	}{
+		{"default order", TopQuery{By: TopPath}, "/a,/c,/b,/d"},
+		{"count", TopQuery{By: TopPath, OrderBy: TopByCount}, "/a,/c,/b,/d"},
+		{"errors tie broken by count", TopQuery{By: TopPath, OrderBy: TopByErrors}, "/b,/c,/d,/a"},
+		{"error rate", TopQuery{By: TopPath, OrderBy: TopByErrorRate}, "/b,/d,/c,/a"},
+		{"total duration", TopQuery{By: TopPath, OrderBy: TopByTotalDuration}, "/d,/c,/b,/a"},
+		{"p95", TopQuery{By: TopPath, OrderBy: TopByP95}, "/d,/c,/b,/a"},
+		{"min count", TopQuery{By: TopPath, OrderBy: TopByErrorRate, MinCount: 2}, "/b,/c,/a"},
+		{"limit", TopQuery{By: TopPath, Limit: 2}, "/a,/c"},
+		{"limit p95 candidates", TopQuery{By: TopPath, OrderBy: TopByP95, Limit: 1}, "/d"},
+		{"negative limit", TopQuery{By: TopPath, Limit: -1}, "/a,/c,/b,/d"},
+		{"status", TopQuery{By: TopStatus}, "200,500"},
+		{"field", TopQuery{By: TopField("tenant")}, "acme,globex,"},
+		{"filtered", TopQuery{By: TopRoute, Filter: LogFilter{StatusClasses: []int{5}}}, "/b,/c,/d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.Top(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := topKeys(got); keys != tt.want {
+				t.Errorf("sqlite = %s, want %s", keys, tt.want)
+			}
+			want, err := mem.Top(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := topKeys(want); keys != tt.want {
+				t.Errorf("memory = %s, want %s", keys, tt.want)
+			}
+			for i := range got {
+				if i >= len(want) {
+					break
+				}
+				g, w := got[i], want[i]
+				if g.Count != w.Count || g.Errors != w.Errors || g.TotalDuration != w.TotalDuration || g.ErrorRate != w.ErrorRate {
+					t.Errorf("%s: sqlite = %+v, memory = %+v", g.Key, g, w)
+				}
+			}
+		})
+	}
+
+	t.Run("approximate p95", func(t *testing.T) {
+		items, err := db.Top(TopQuery{By: TopPath, OrderBy: TopByP95})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// SQLite 没有 percentile_cont，P95 由直方图估算，误差不超过一个分桶
+		want := map[string]float64{"/a": 10, "/b": 20, "/c": 100, "/d": 2000}
+		for _, item := range items {
+			if !item.Approximate {
+				t.Errorf("%s: Approximate = false on sqlite", item.Key)
+			}
+			if w := want[item.Key]; item.P95 < w/1.2 || item.P95 > w*1.2 {
+				t.Errorf("%s: p95 = %v, want about %v", item.Key, item.P95, w)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := db.Top(TopQuery{By: TopPath, OrderBy: "latency"}); err == nil {
+			t.Error("unknown order accepted")
+		}
+		if _, err := db.Top(TopQuery{By: "host"}); err == nil {
+			t.Error("unknown dimension accepted")
+		}
+	})
+}
+
+func TestDBLoggerTopDefaultLimit(t *testing.T) {
+	l := newTestDBLogger(t)
+	for i := 0; i < 15; i++ {
+		if err := l.Write(&LogEntry{Method: "GET", Path: fmt.Sprintf("/%02d", i), ClientIP: "127.0.0.1", StatusCode: 200, RequestTime: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, limit := range []int{0, -5} {
+		items, err := l.Top(TopQuery{By: TopPath, Limit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 10 {
+			t.Errorf("limit %d: got %d items, want 10", limit, len(items))
+		}
+	}
+	items, err := l.Top(TopQuery{By: TopPath, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 15 {
+		t.Errorf("limit 100: got %d items, want 15", len(items))
+	}
+}